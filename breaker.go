@@ -0,0 +1,150 @@
+package rueidis
+
+import (
+	"sync"
+	"time"
+)
+
+// CircuitState is the state of the circuit breaker of a redis node.
+type CircuitState int
+
+const (
+	// CircuitClosed means the node is considered healthy and connections are dialed as usual.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen means the node is considered unhealthy and commands to it are failed fast with ErrCircuitOpen.
+	CircuitOpen
+	// CircuitHalfOpen means the cooldown has passed and a single probe is in flight to the node.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// CircuitBreakerOption configures the circuit breaker kept for each redis node.
+// The breaker trips after Threshold consecutive failures of dialing the node or of writing to and reading from
+// its pipelined connections, where a successful dial resets the count. While it is open, commands to the node
+// are failed with ErrCircuitOpen immediately instead of redialing. After the Cooldown, a single dial followed
+// by a PING is let through as a probe. The breaker is closed if the probe succeeds, otherwise it is opened again.
+type CircuitBreakerOption struct {
+	// OnStateChange, if set, is called with the address of the node whenever its breaker changes state.
+	// It is called synchronously and should not block.
+	OnStateChange func(addr string, from, to CircuitState)
+	// Threshold is the number of consecutive failures to trip the breaker. The breaker is disabled if it is zero.
+	Threshold int
+	// Cooldown is how long the breaker stays open before probing the node, the default is DefaultCircuitBreakerCooldown.
+	Cooldown time.Duration
+}
+
+// breakers keeps the breaker of each address for a client,
+// so that the state is not reset when the connection to the same address is recreated, such as after a cluster refresh.
+type breakers struct {
+	m      map[string]*breaker
+	option CircuitBreakerOption
+	mu     sync.Mutex
+}
+
+func newBreakers(option CircuitBreakerOption) *breakers {
+	return &breakers{m: make(map[string]*breaker), option: option}
+}
+
+func (b *breakers) get(addr string) *breaker {
+	if b.option.Threshold <= 0 {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	cb, ok := b.m[addr]
+	if !ok {
+		cb = newBreaker(addr, b.option)
+		b.m[addr] = cb
+	}
+	return cb
+}
+
+type breaker struct {
+	until    time.Time
+	onChange func(addr string, from, to CircuitState)
+	addr     string
+	mu       sync.Mutex
+	state    CircuitState
+	fails    int
+	limit    int
+	cooldown time.Duration
+}
+
+func newBreaker(addr string, option CircuitBreakerOption) *breaker {
+	if option.Threshold <= 0 {
+		return nil
+	}
+	if option.Cooldown <= 0 {
+		option.Cooldown = DefaultCircuitBreakerCooldown
+	}
+	return &breaker{addr: addr, onChange: option.OnStateChange, limit: option.Threshold, cooldown: option.Cooldown}
+}
+
+// allow reports whether a dial to the node can be made, and whether the dial is the probe of a half-open breaker.
+func (b *breaker) allow() (probe bool, err error) {
+	if b == nil {
+		return false, nil
+	}
+	b.mu.Lock()
+	from := b.state
+	switch b.state {
+	case CircuitOpen:
+		if time.Now().Before(b.until) {
+			err = ErrCircuitOpen
+		} else {
+			b.state = CircuitHalfOpen
+			probe = true
+		}
+	case CircuitHalfOpen:
+		err = ErrCircuitOpen
+	}
+	to := b.state
+	b.mu.Unlock()
+	b.notify(from, to)
+	return probe, err
+}
+
+// report records the result of a dial or a failure of an established connection.
+func (b *breaker) report(err error) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	from := b.state
+	if err == nil {
+		b.fails = 0
+		b.state = CircuitClosed
+	} else if b.fails++; b.state == CircuitHalfOpen || b.fails >= b.limit {
+		b.state = CircuitOpen
+		b.until = time.Now().Add(b.cooldown)
+	}
+	to := b.state
+	b.mu.Unlock()
+	b.notify(from, to)
+}
+
+func (b *breaker) State() CircuitState {
+	if b == nil {
+		return CircuitClosed
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+func (b *breaker) notify(from, to CircuitState) {
+	if from != to && b.onChange != nil {
+		b.onChange(b.addr, from, to)
+	}
+}
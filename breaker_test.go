@@ -0,0 +1,79 @@
+package rueidis
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	t.Run("Disabled", func(t *testing.T) {
+		b := newBreaker("", CircuitBreakerOption{})
+		if b != nil {
+			t.Fatalf("unexpected breaker %v", b)
+		}
+		b.report(errors.New("any"))
+		if probe, err := b.allow(); probe || err != nil {
+			t.Fatalf("unexpected allow %v %v", probe, err)
+		}
+		if s := b.State(); s != CircuitClosed {
+			t.Fatalf("unexpected state %v", s)
+		}
+	})
+	t.Run("Default Cooldown", func(t *testing.T) {
+		if b := newBreaker("", CircuitBreakerOption{Threshold: 1}); b.cooldown != DefaultCircuitBreakerCooldown {
+			t.Fatalf("unexpected cooldown %v", b.cooldown)
+		}
+	})
+	t.Run("Consecutive Failures", func(t *testing.T) {
+		b := newBreaker("", CircuitBreakerOption{Threshold: 3, Cooldown: time.Hour})
+		b.report(errors.New("any"))
+		b.report(errors.New("any"))
+		b.report(nil)
+		b.report(errors.New("any"))
+		b.report(errors.New("any"))
+		if _, err := b.allow(); err != nil || b.State() != CircuitClosed {
+			t.Fatalf("unexpected allow %v %v", err, b.State())
+		}
+		b.report(errors.New("any"))
+		if _, err := b.allow(); err != ErrCircuitOpen || b.State() != CircuitOpen {
+			t.Fatalf("unexpected allow %v %v", err, b.State())
+		}
+	})
+	t.Run("Single Probe", func(t *testing.T) {
+		b := newBreaker("", CircuitBreakerOption{Threshold: 1, Cooldown: time.Millisecond})
+		b.report(errors.New("any"))
+		time.Sleep(2 * time.Millisecond)
+		if probe, err := b.allow(); !probe || err != nil {
+			t.Fatalf("unexpected allow %v %v", probe, err)
+		}
+		if probe, err := b.allow(); probe || err != ErrCircuitOpen {
+			t.Fatalf("unexpected allow %v %v", probe, err)
+		}
+		b.report(nil)
+		if probe, err := b.allow(); probe || err != nil {
+			t.Fatalf("unexpected allow %v %v", probe, err)
+		}
+	})
+	t.Run("Per Address", func(t *testing.T) {
+		if b := newBreakers(CircuitBreakerOption{}); b.get("a") != nil {
+			t.Fatalf("unexpected breaker")
+		}
+		b := newBreakers(CircuitBreakerOption{Threshold: 1})
+		if a := b.get("a"); a == nil || a != b.get("a") || a == b.get("b") || a.addr != "a" {
+			t.Fatalf("unexpected breakers")
+		}
+	})
+	t.Run("String", func(t *testing.T) {
+		for s, exp := range map[CircuitState]string{
+			CircuitClosed:   "closed",
+			CircuitOpen:     "open",
+			CircuitHalfOpen: "half-open",
+			CircuitState(9): "unknown",
+		} {
+			if s.String() != exp {
+				t.Fatalf("unexpected string %v", s.String())
+			}
+		}
+	})
+}
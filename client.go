@@ -2,6 +2,7 @@ package rueidis
 
 import (
 	"context"
+	"errors"
 	"sync/atomic"
 	"time"
 
@@ -9,10 +10,11 @@ import (
 )
 
 type singleClient struct {
-	conn  conn
-	stop  uint32
-	cmd   cmds.Builder
-	retry bool
+	conn         conn
+	stop         uint32
+	cmd          cmds.Builder
+	retry        bool
+	retryHandler retryHandler
 }
 
 func newSingleClient(opt *ClientOption, prev conn, connFn connFn) (*singleClient, error) {
@@ -25,11 +27,11 @@ func newSingleClient(opt *ClientOption, prev conn, connFn connFn) (*singleClient
 	if err := conn.Dial(); err != nil {
 		return nil, err
 	}
-	return newSingleClientWithConn(conn, cmds.NewBuilder(cmds.NoSlot), !opt.DisableRetry), nil
+	return newSingleClientWithConn(conn, cmds.NewBuilder(cmds.NoSlot), !opt.DisableRetry, newRetryHandler(opt.RetryPolicy)), nil
 }
 
-func newSingleClientWithConn(conn conn, builder cmds.Builder, retry bool) *singleClient {
-	return &singleClient{cmd: builder, conn: conn, retry: retry}
+func newSingleClientWithConn(conn conn, builder cmds.Builder, retry bool, retryHandler retryHandler) *singleClient {
+	return &singleClient{cmd: builder, conn: conn, retry: retry, retryHandler: retryHandler}
 }
 
 func (c *singleClient) B() cmds.Builder {
@@ -37,9 +39,12 @@ func (c *singleClient) B() cmds.Builder {
 }
 
 func (c *singleClient) Do(ctx context.Context, cmd Completed) (resp RedisResult) {
+	attempts := 1
 retry:
 	resp = c.conn.Do(ctx, cmd)
-	if c.retry && cmd.IsReadOnly() && c.isRetryable(resp.NonRedisError(), ctx) {
+	if c.retry && retrySafe(cmd) && c.isRetryable(resp.NonRedisError(), ctx) &&
+		c.retryHandler.WaitOrSkipRetry(ctx, attempts, resp.Error(), cmd) {
+		attempts++
 		goto retry
 	}
 	if resp.NonRedisError() == nil { // not recycle cmds if error, since cmds may be used later in pipe. consider recycle them by pipe
@@ -52,12 +57,17 @@ func (c *singleClient) DoMulti(ctx context.Context, multi ...Completed) (resps [
 	if len(multi) == 0 {
 		return nil
 	}
+	attempts := 1
 retry:
 	resps = c.conn.DoMulti(ctx, multi...).s
-	if c.retry && allReadOnly(multi) {
-		for _, resp := range resps {
+	if c.retry && allRetrySafe(multi) {
+		for i, resp := range resps {
 			if c.isRetryable(resp.NonRedisError(), ctx) {
-				goto retry
+				if c.retryHandler.WaitOrSkipRetry(ctx, attempts, resp.Error(), multi[i]) {
+					attempts++
+					goto retry
+				}
+				break
 			}
 		}
 	}
@@ -73,12 +83,17 @@ func (c *singleClient) DoMultiCache(ctx context.Context, multi ...CacheableTTL)
 	if len(multi) == 0 {
 		return nil
 	}
+	attempts := 1
 retry:
 	resps = c.conn.DoMultiCache(ctx, multi...).s
 	if c.retry {
-		for _, resp := range resps {
+		for i, resp := range resps {
 			if c.isRetryable(resp.NonRedisError(), ctx) {
-				goto retry
+				if c.retryHandler.WaitOrSkipRetry(ctx, attempts, resp.Error(), Completed(multi[i].Cmd)) {
+					attempts++
+					goto retry
+				}
+				break
 			}
 		}
 	}
@@ -91,9 +106,12 @@ retry:
 }
 
 func (c *singleClient) DoCache(ctx context.Context, cmd Cacheable, ttl time.Duration) (resp RedisResult) {
+	attempts := 1
 retry:
 	resp = c.conn.DoCache(ctx, cmd, ttl)
-	if c.retry && c.isRetryable(resp.NonRedisError(), ctx) {
+	if c.retry && c.isRetryable(resp.NonRedisError(), ctx) &&
+		c.retryHandler.WaitOrSkipRetry(ctx, attempts, resp.Error(), Completed(cmd)) {
+		attempts++
 		goto retry
 	}
 	if err := resp.NonRedisError(); err == nil || err == ErrDoCacheAborted {
@@ -102,11 +120,26 @@ retry:
 	return resp
 }
 
+func (c *singleClient) DoStream(ctx context.Context, cmd Completed) RedisResultStream {
+	s := c.conn.DoStream(ctx, cmd)
+	cmds.PutCompleted(cmd)
+	return s
+}
+
+func (c *singleClient) DoIter(ctx context.Context, cmd Completed, fn func(elem RedisMessage) error) error {
+	err := c.conn.DoIter(ctx, cmd, fn)
+	cmds.PutCompleted(cmd)
+	return err
+}
+
 func (c *singleClient) Receive(ctx context.Context, subscribe Completed, fn func(msg PubSubMessage)) (err error) {
+	attempts := 1
 retry:
 	err = c.conn.Receive(ctx, subscribe, fn)
 	if c.retry {
-		if _, ok := err.(*RedisError); !ok && c.isRetryable(err, ctx) {
+		if _, ok := err.(*RedisError); !ok && c.isRetryable(err, ctx) &&
+			c.retryHandler.WaitOrSkipRetry(ctx, attempts, err, subscribe) {
+			attempts++
 			goto retry
 		}
 	}
@@ -117,35 +150,60 @@ retry:
 }
 
 func (c *singleClient) Dedicated(fn func(DedicatedClient) error) (err error) {
-	wire := c.conn.Acquire()
-	dsc := &dedicatedSingleClient{cmd: c.cmd, conn: c.conn, wire: wire, retry: c.retry}
+	return c.DedicatedCtx(context.Background(), fn)
+}
+
+func (c *singleClient) DedicatedCtx(ctx context.Context, fn func(DedicatedClient) error) (err error) {
+	wire, err := c.conn.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	dsc := &dedicatedSingleClient{cmd: c.cmd, conn: c.conn, wire: wire, retry: c.retry, retryHandler: c.retryHandler}
 	err = fn(dsc)
 	dsc.release()
 	return err
 }
 
 func (c *singleClient) Dedicate() (DedicatedClient, func()) {
-	wire := c.conn.Acquire()
-	dsc := &dedicatedSingleClient{cmd: c.cmd, conn: c.conn, wire: wire, retry: c.retry}
-	return dsc, dsc.release
+	client, cancel, _ := c.DedicateCtx(context.Background())
+	return client, cancel
+}
+
+func (c *singleClient) DedicateCtx(ctx context.Context) (DedicatedClient, func(), error) {
+	wire, err := c.conn.Acquire(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	dsc := &dedicatedSingleClient{cmd: c.cmd, conn: c.conn, wire: wire, retry: c.retry, retryHandler: c.retryHandler}
+	return dsc, dsc.release, nil
 }
 
 func (c *singleClient) Nodes() map[string]Client {
 	return map[string]Client{c.conn.Addr(): c}
 }
 
+func (c *singleClient) Stats() ClientStats {
+	return ClientStats{Nodes: map[string]NodeStats{c.conn.Addr(): c.conn.NodeStats()}}
+}
+
 func (c *singleClient) Close() {
 	atomic.StoreUint32(&c.stop, 1)
 	c.conn.Close()
 }
 
+func (c *singleClient) CloseWithContext(ctx context.Context) error {
+	atomic.StoreUint32(&c.stop, 1)
+	return newCloseError(ctx, c.conn.CloseWithContext(ctx))
+}
+
 type dedicatedSingleClient struct {
 	conn conn
 	wire wire
 	mark uint32
 	cmd  cmds.Builder
 
-	retry bool
+	retry        bool
+	retryHandler retryHandler
 }
 
 func (c *dedicatedSingleClient) B() cmds.Builder {
@@ -153,9 +211,12 @@ func (c *dedicatedSingleClient) B() cmds.Builder {
 }
 
 func (c *dedicatedSingleClient) Do(ctx context.Context, cmd Completed) (resp RedisResult) {
+	attempts := 1
 retry:
 	resp = c.wire.Do(ctx, cmd)
-	if c.retry && cmd.IsReadOnly() && isRetryable(resp.NonRedisError(), c.wire, ctx) {
+	if c.retry && retrySafe(cmd) && isRetryable(resp.NonRedisError(), c.wire, ctx) &&
+		c.retryHandler.WaitOrSkipRetry(ctx, attempts, resp.Error(), cmd) {
+		attempts++
 		goto retry
 	}
 	if resp.NonRedisError() == nil {
@@ -170,12 +231,16 @@ func (c *dedicatedSingleClient) DoMulti(ctx context.Context, multi ...Completed)
 	}
 	retryable := c.retry
 	if retryable {
-		retryable = allReadOnly(multi)
+		retryable = allRetrySafe(multi)
 	}
+	attempts := 1
 retry:
 	resp = c.wire.DoMulti(ctx, multi...).s
-	if retryable && anyRetryable(resp, c.wire, ctx) {
-		goto retry
+	if retryable {
+		if i := anyRetryable(resp, c.wire, ctx); i >= 0 && c.retryHandler.WaitOrSkipRetry(ctx, attempts, resp[i].Error(), multi[i]) {
+			attempts++
+			goto retry
+		}
 	}
 	for i, cmd := range multi {
 		if resp[i].NonRedisError() == nil {
@@ -186,10 +251,13 @@ retry:
 }
 
 func (c *dedicatedSingleClient) Receive(ctx context.Context, subscribe Completed, fn func(msg PubSubMessage)) (err error) {
+	attempts := 1
 retry:
 	err = c.wire.Receive(ctx, subscribe, fn)
 	if c.retry {
-		if _, ok := err.(*RedisError); !ok && isRetryable(err, c.wire, ctx) {
+		if _, ok := err.(*RedisError); !ok && isRetryable(err, c.wire, ctx) &&
+			c.retryHandler.WaitOrSkipRetry(ctx, attempts, err, subscribe) {
+			attempts++
 			goto retry
 		}
 	}
@@ -215,20 +283,43 @@ func (c *dedicatedSingleClient) release() {
 }
 
 func (c *singleClient) isRetryable(err error, ctx context.Context) bool {
-	return err != nil && atomic.LoadUint32(&c.stop) == 0 && ctx.Err() == nil
+	return err != nil && atomic.LoadUint32(&c.stop) == 0 && ctx.Err() == nil && retryableErr(err)
 }
 
 func isRetryable(err error, w wire, ctx context.Context) bool {
-	return err != nil && w.Error() == nil && ctx.Err() == nil
+	return err != nil && w.Error() == nil && ctx.Err() == nil && retryableErr(err)
+}
+
+// retryableErr reports whether the non-redis error of an attempt is worth retrying.
+// A context.DeadlineExceeded while the ctx of the caller is not done comes from the command timeout,
+// which should fail the caller instead of being retried over and over against a slow redis.
+// The ErrCircuitOpen should also fail the caller fast, as the circuit breaker is meant to.
+func retryableErr(err error) bool {
+	return !errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, ErrCircuitOpen)
 }
 
-func anyRetryable(resp []RedisResult, w wire, ctx context.Context) bool {
-	for _, r := range resp {
+// anyRetryable returns the index of the first retryable result, or -1 if there is none
+func anyRetryable(resp []RedisResult, w wire, ctx context.Context) int {
+	for i, r := range resp {
 		if isRetryable(r.NonRedisError(), w, ctx) {
-			return true
+			return i
+		}
+	}
+	return -1
+}
+
+// retrySafe checks if the cmd can be retried when network error, which are the readonly and the idempotent commands
+func retrySafe(cmd Completed) bool {
+	return cmd.IsReadOnly() || cmd.IsIdempotent()
+}
+
+func allRetrySafe(multi []Completed) bool {
+	for _, cmd := range multi {
+		if !retrySafe(cmd) {
+			return false
 		}
 	}
-	return false
+	return true
 }
 
 func allReadOnly(multi []Completed) bool {
@@ -9,6 +9,8 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/redis/rueidis/internal/cmds"
 )
 
 type mockConn struct {
@@ -17,11 +19,16 @@ type mockConn struct {
 	DoMultiFn      func(multi ...Completed) *redisresults
 	DoMultiCacheFn func(multi ...CacheableTTL) *redisresults
 	ReceiveFn      func(ctx context.Context, subscribe Completed, fn func(message PubSubMessage)) error
+	DoStreamFn     func(cmd Completed) RedisResultStream
+	DoIterFn       func(cmd Completed, fn func(elem RedisMessage) error) error
 	InfoFn         func() map[string]RedisMessage
+	NodeStatsFn    func() NodeStats
 	ErrorFn        func() error
 	CloseFn        func()
+	CloseWithCtxFn func(ctx context.Context) int
 	DialFn         func() error
 	AcquireFn      func() wire
+	AcquireCtxFn   func(ctx context.Context) (wire, error)
 	StoreFn        func(w wire)
 	OverrideFn     func(c conn)
 	AddrFn         func() string
@@ -44,11 +51,14 @@ func (m *mockConn) Dial() error {
 	return nil
 }
 
-func (m *mockConn) Acquire() wire {
+func (m *mockConn) Acquire(ctx context.Context) (wire, error) {
+	if m.AcquireCtxFn != nil {
+		return m.AcquireCtxFn(ctx)
+	}
 	if m.AcquireFn != nil {
-		return m.AcquireFn()
+		return m.AcquireFn(), nil
 	}
-	return nil
+	return nil, nil
 }
 
 func (m *mockConn) Store(w wire) {
@@ -119,6 +129,20 @@ func (m *mockConn) Receive(ctx context.Context, subscribe Completed, hdl func(me
 	return nil
 }
 
+func (m *mockConn) DoStream(ctx context.Context, cmd Completed) RedisResultStream {
+	if m.DoStreamFn != nil {
+		return m.DoStreamFn(cmd)
+	}
+	return RedisResultStream{}
+}
+
+func (m *mockConn) DoIter(ctx context.Context, cmd Completed, fn func(elem RedisMessage) error) error {
+	if m.DoIterFn != nil {
+		return m.DoIterFn(cmd, fn)
+	}
+	return nil
+}
+
 func (m *mockConn) CleanSubscriptions() {
 	panic("not implemented")
 }
@@ -138,6 +162,21 @@ func (m *mockConn) Info() map[string]RedisMessage {
 	return nil
 }
 
+func (m *mockConn) NodeStats() NodeStats {
+	if m.NodeStatsFn != nil {
+		return m.NodeStatsFn()
+	}
+	return NodeStats{}
+}
+
+func (m *mockConn) Stats() ConnStats {
+	return ConnStats{}
+}
+
+func (m *mockConn) Queued() int {
+	return 0
+}
+
 func (m *mockConn) Error() error {
 	if m.ErrorFn != nil {
 		return m.ErrorFn()
@@ -151,6 +190,13 @@ func (m *mockConn) Close() {
 	}
 }
 
+func (m *mockConn) CloseWithContext(ctx context.Context) int {
+	if m.CloseWithCtxFn != nil {
+		return m.CloseWithCtxFn(ctx)
+	}
+	return 0
+}
+
 func (m *mockConn) Addr() string {
 	if m.AddrFn != nil {
 		return m.AddrFn()
@@ -265,6 +311,53 @@ func TestSingleClient(t *testing.T) {
 		}
 	})
 
+	t.Run("Delegate DoStream", func(t *testing.T) {
+		c := client.B().Get().Key("Do").Build()
+		e := errors.New("stream")
+		m.DoStreamFn = func(cmd Completed) RedisResultStream {
+			if !reflect.DeepEqual(cmd.Commands(), c.Commands()) {
+				t.Fatalf("unexpected command %v", cmd)
+			}
+			return RedisResultStream{e: e}
+		}
+		if s := client.DoStream(context.Background(), c); s.Error() != e {
+			t.Fatalf("unexpected response %v", s.Error())
+		}
+	})
+
+	t.Run("Delegate DoIter", func(t *testing.T) {
+		c := client.B().Hgetall().Key("Do").Build()
+		m.DoIterFn = func(cmd Completed, fn func(elem RedisMessage) error) error {
+			if !reflect.DeepEqual(cmd.Commands(), c.Commands()) {
+				t.Fatalf("unexpected command %v", cmd)
+			}
+			return fn(RedisMessage{typ: '+', string: "Do"})
+		}
+		if err := client.DoIter(context.Background(), c, func(elem RedisMessage) error {
+			if v, _ := elem.ToString(); v != "Do" {
+				t.Fatalf("unexpected element %v", elem)
+			}
+			return nil
+		}); err != nil {
+			t.Fatalf("unexpected response %v", err)
+		}
+	})
+
+	t.Run("Delegate Stats", func(t *testing.T) {
+		m.NodeStatsFn = func() NodeStats {
+			return NodeStats{Dials: 1}
+		}
+		stats := client.Stats()
+		if len(stats.Nodes) != 1 {
+			t.Fatalf("unexpected stats %v", stats)
+		}
+		for _, s := range stats.Nodes {
+			if s.Dials != 1 {
+				t.Fatalf("unexpected stats %v", stats)
+			}
+		}
+	})
+
 	t.Run("Delegate Receive", func(t *testing.T) {
 		c := client.B().Subscribe().Channel("ch").Build()
 		hdl := func(message PubSubMessage) {}
@@ -299,6 +392,20 @@ func TestSingleClient(t *testing.T) {
 		}
 	})
 
+	t.Run("Delegate CloseWithContext", func(t *testing.T) {
+		m.CloseWithCtxFn = func(ctx context.Context) int { return 1 }
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		var ce *CloseError
+		if err := client.CloseWithContext(ctx); !errors.As(err, &ce) || ce.Abandoned != 1 || !errors.Is(err, context.Canceled) {
+			t.Fatalf("unexpected err %v", err)
+		}
+		m.CloseWithCtxFn = nil
+		if err := client.CloseWithContext(context.Background()); err != nil {
+			t.Fatalf("unexpected err %v", err)
+		}
+	})
+
 	t.Run("Dedicated Err", func(t *testing.T) {
 		v := errors.New("fn err")
 		if err := client.Dedicated(func(client DedicatedClient) error {
@@ -487,6 +594,52 @@ func TestSingleClient(t *testing.T) {
 			t.Fatalf("unexpected stored count %v", stored)
 		}
 	})
+
+	t.Run("DedicatedCtx Delegate", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		w := &mockWire{}
+		m.AcquireCtxFn = func(c context.Context) (wire, error) {
+			if c != ctx {
+				t.Fatalf("unexpected ctx %v", c)
+			}
+			return w, nil
+		}
+		defer func() { m.AcquireCtxFn = nil }()
+		stored := 0
+		m.StoreFn = func(ww wire) { stored++ }
+		if err := client.DedicatedCtx(ctx, func(c DedicatedClient) error {
+			return c.Do(ctx, c.B().Get().Key("a").Build()).Error()
+		}); err != nil {
+			t.Fatalf("unexpected err %v", err)
+		}
+		c, release, err := client.DedicateCtx(ctx)
+		if err != nil || c == nil {
+			t.Fatalf("unexpected dedicate %v %v", c, err)
+		}
+		c.Do(ctx, c.B().Get().Key("a").Build())
+		release()
+		if stored != 2 {
+			t.Fatalf("unexpected stored count %v", stored)
+		}
+
+		e := errors.New("exhausted")
+		m.AcquireCtxFn = func(c context.Context) (wire, error) { return nil, e }
+		if err := client.DedicatedCtx(ctx, func(c DedicatedClient) error {
+			return c.Do(ctx, c.B().Get().Key("a").Build()).Error()
+		}); err != e {
+			t.Fatalf("unexpected err %v", err)
+		}
+		if c, release, err := client.DedicateCtx(ctx); err == nil {
+			err = c.Do(ctx, c.B().Get().Key("a").Build()).Error()
+			release()
+			if err != e {
+				t.Fatalf("unexpected err %v", err)
+			}
+		} else if c != nil || release != nil || err != e {
+			t.Fatalf("unexpected dedicate %v %v", c, err)
+		}
+	})
 }
 
 func TestSingleClientRetry(t *testing.T) {
@@ -501,6 +654,76 @@ func TestSingleClientRetry(t *testing.T) {
 	})
 }
 
+func TestSingleClientRetryPolicy(t *testing.T) {
+	defer ShouldNotLeaked(SetupLeakDetection())
+	var calls, attempts int
+	m := &mockConn{
+		DoFn: func(cmd Completed) RedisResult {
+			calls++
+			return newErrResult(ErrClosing)
+		},
+		DoMultiFn: func(multi ...Completed) *redisresults {
+			calls++
+			return &redisresults{s: []RedisResult{newResult(RedisMessage{typ: '+', string: "OK"}, nil), newErrResult(ErrClosing)}}
+		},
+	}
+	client, err := newSingleClient(&ClientOption{
+		InitAddress: []string{""},
+		RetryPolicy: retryPolicyFn(func(n int, err error, cmd Completed) (bool, time.Duration) {
+			if err != ErrClosing || cmd.Commands()[1] != "b" {
+				t.Fatalf("unexpected retry %v %v", err, cmd.Commands())
+			}
+			attempts = n
+			return n < 3, time.Millisecond
+		}),
+	}, m, func(dst string, opt *ClientOption) conn { return m })
+	if err != nil {
+		t.Fatalf("unexpected err %v", err)
+	}
+	if err := client.Do(context.Background(), client.B().Get().Key("b").Build()).Error(); err != ErrClosing || calls != 3 || attempts != 3 {
+		t.Fatalf("unexpected %v %v %v", err, calls, attempts)
+	}
+	calls, attempts = 0, 0
+	if resps := client.DoMulti(context.Background(), client.B().Get().Key("a").Build(), client.B().Get().Key("b").Build()); resps[1].Error() != ErrClosing || calls != 3 || attempts != 3 {
+		t.Fatalf("unexpected %v %v %v", resps, calls, attempts)
+	}
+
+	calls = 0
+	client, err = newSingleClient(&ClientOption{
+		InitAddress: []string{""},
+		RetryPolicy: &ExponentialBackoffRetryPolicy{MaxAttempts: DefaultRetryMaxAttempts},
+	}, m, func(dst string, opt *ClientOption) conn { return m })
+	if err != nil {
+		t.Fatalf("unexpected err %v", err)
+	}
+	if err := client.Do(context.Background(), client.B().Get().Key("b").Build()).Error(); err != ErrClosing || calls != DefaultRetryMaxAttempts {
+		t.Fatalf("unexpected %v %v", err, calls)
+	}
+}
+
+func TestSingleClientCircuitOpenNotRetried(t *testing.T) {
+	defer ShouldNotLeaked(SetupLeakDetection())
+	dialErr := errors.New("any")
+	m := makeMux("addr", &ClientOption{}, func(dst string, opt *ClientOption) (net.Conn, error) {
+		return nil, dialErr
+	})
+	m.cb = newBreaker("addr", CircuitBreakerOption{Threshold: 1, Cooldown: time.Hour})
+	defer m.Close()
+	if err := m.Dial(); err != dialErr {
+		t.Fatalf("unexpected err %v", err)
+	}
+	client := newSingleClientWithConn(m, cmds.NewBuilder(cmds.NoSlot), true, newRetryHandler(nil))
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	start := time.Now()
+	if err := client.Do(ctx, client.B().Get().Key("a").Build()).Error(); err != ErrCircuitOpen {
+		t.Fatalf("unexpected err %v", err)
+	}
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Fatalf("the read-only command should fail fast, returned after %v", d)
+	}
+}
+
 //gocyclo:ignore
 func SetupClientRetry(t *testing.T, fn func(mock *mockConn) Client) {
 	setup := func() (Client, *mockConn) {
@@ -586,6 +809,17 @@ func SetupClientRetry(t *testing.T, fn func(mock *mockConn) Client) {
 		}
 	})
 
+	t.Run("Delegate Do Idempotent Retry", func(t *testing.T) {
+		c, m := setup()
+		m.DoFn = makeDoFn(
+			newErrResult(ErrClosing),
+			newResult(RedisMessage{typ: '+', string: "Do"}, nil),
+		)
+		if v, err := c.Do(context.Background(), c.B().Set().Key("Do").Value("V").Build().Idempotent()).ToString(); err != nil || v != "Do" {
+			t.Fatalf("unexpected response %v %v", v, err)
+		}
+	})
+
 	t.Run("Delegate DoMulti ReadOnly Retry", func(t *testing.T) {
 		c, m := setup()
 		m.DoMultiFn = makeDoMultiFn(
@@ -597,6 +831,27 @@ func SetupClientRetry(t *testing.T, fn func(mock *mockConn) Client) {
 		}
 	})
 
+	t.Run("Delegate DoMulti Idempotent Retry", func(t *testing.T) {
+		c, m := setup()
+		m.DoMultiFn = makeDoMultiFn(
+			[]RedisResult{newErrResult(ErrClosing), newErrResult(ErrClosing)},
+			[]RedisResult{newResult(RedisMessage{typ: '+', string: "Do"}, nil), newResult(RedisMessage{typ: '+', string: "Do"}, nil)},
+		)
+		if v, err := c.DoMulti(context.Background(), c.B().Get().Key("Do").Build(), c.B().Del().Key("Do").Build().Idempotent())[1].ToString(); err != nil || v != "Do" {
+			t.Fatalf("unexpected response %v %v", v, err)
+		}
+	})
+
+	t.Run("Delegate DoMulti Write NoRetry - not idempotent", func(t *testing.T) {
+		c, m := setup()
+		m.DoMultiFn = makeDoMultiFn(
+			[]RedisResult{newErrResult(ErrClosing), newErrResult(ErrClosing)},
+		)
+		if v, err := c.DoMulti(context.Background(), c.B().Del().Key("Do").Build().Idempotent(), c.B().Incr().Key("Do").Build())[1].ToString(); err != ErrClosing {
+			t.Fatalf("unexpected response %v %v", v, err)
+		}
+	})
+
 	t.Run("Delegate DoMulti ReadOnly NoRetry - closed", func(t *testing.T) {
 		c, m := setup()
 		m.DoMultiFn = makeDoMultiFn([]RedisResult{newErrResult(ErrClosing)})
@@ -3,9 +3,10 @@ package rueidis
 import (
 	"context"
 	"errors"
+	"math/rand"
 	"net"
-	"runtime"
 	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -69,14 +70,42 @@ var retrycachep = util.NewPool(func(capacity int) *retrycache {
 
 type clusterClient struct {
 	slots  [16384]conn
+	rslots [16384]conn
 	opt    *ClientOption
-	conns  map[string]conn
+	rOpt   *ClientOption
+	conns  map[string]connrole
 	connFn connFn
+	stopCh chan struct{}
 	sc     call
 	mu     sync.RWMutex
 	stop   uint32
 	cmd    cmds.Builder
 	retry  bool
+
+	retryHandler retryHandler
+}
+
+type connrole struct {
+	conn    conn
+	host    string // the announced hostname used as the tls server name, empty if unknown or unused
+	replica bool
+}
+
+// ReplicaSelector chooses one of the replicas of the slot to serve read-only commands.
+// It returns the index of the chosen replica, or -1 to send the commands to the primary.
+type ReplicaSelector func(slot uint16, replicas []string) int
+
+// RandomReplicaSelector chooses a replica randomly.
+func RandomReplicaSelector(slot uint16, replicas []string) int {
+	return rand.Intn(len(replicas))
+}
+
+// NewRoundRobinReplicaSelector returns a ReplicaSelector that chooses replicas in turn.
+func NewRoundRobinReplicaSelector() ReplicaSelector {
+	var i uint32
+	return func(slot uint16, replicas []string) int {
+		return int((atomic.AddUint32(&i, 1) - 1) % uint32(len(replicas)))
+	}
 }
 
 func newClusterClient(opt *ClientOption, connFn connFn) (client *clusterClient, err error) {
@@ -84,8 +113,17 @@ func newClusterClient(opt *ClientOption, connFn connFn) (client *clusterClient,
 		cmd:    cmds.NewBuilder(cmds.InitSlot),
 		opt:    opt,
 		connFn: connFn,
-		conns:  make(map[string]conn),
+		conns:  make(map[string]connrole),
+		stopCh: make(chan struct{}),
 		retry:  !opt.DisableRetry,
+
+		retryHandler: newRetryHandler(opt.RetryPolicy),
+	}
+
+	if opt.ReadFromReplicas {
+		rOpt := *opt
+		rOpt.ReplicaOnly = true
+		client.rOpt = &rOpt
 	}
 
 	if err = client.init(); err != nil {
@@ -96,6 +134,10 @@ func newClusterClient(opt *ClientOption, connFn connFn) (client *clusterClient,
 		return client, err
 	}
 
+	if opt.ClusterRefreshInterval > 0 {
+		go client.backgroundRefresh(opt.ClusterRefreshInterval)
+	}
+
 	return client, nil
 }
 
@@ -112,7 +154,7 @@ func (c *clusterClient) init() error {
 				if _, ok := c.conns[addr]; ok {
 					go cc.Close() // abort the new connection instead of closing the old one which may already been used
 				} else {
-					c.conns[addr] = cc
+					c.conns[addr] = connrole{conn: cc}
 				}
 				c.mu.Unlock()
 				results <- nil
@@ -136,34 +178,75 @@ func (c *clusterClient) refresh() (err error) {
 	return c.sc.Do(c._refresh)
 }
 
+func (c *clusterClient) backgroundRefresh(interval time.Duration) {
+	timer := time.NewTimer(jitter(interval))
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			_ = c.refresh() // errors are ignored, the next redirection or tick will try again
+			timer.Reset(jitter(interval))
+		case <-c.stopCh:
+			return
+		}
+	}
+}
+
+// jitter adds a random delay up to 10% of the interval to avoid refreshing all clients at the same time
+func jitter(interval time.Duration) time.Duration {
+	return interval + time.Duration(rand.Int63n(int64(interval)/10+1))
+}
+
 type clusterslots struct {
 	reply RedisResult
 	addr  string
+	ver   int
+}
+
+// parse returns the groups of nodes and the slots whose primary is unknown at the moment, such as those of a failing shard.
+func (s clusterslots) parse(tls bool) (groups map[string]group, missing [][2]int64) {
+	if s.ver >= 7 {
+		return parseShards(s.reply.val, s.addr, tls)
+	}
+	return parseSlots(s.reply.val, s.addr), nil
+}
+
+func getClusterTopology(cc conn) clusterslots {
+	ver := 0
+	if v, ok := cc.Info()["version"]; ok {
+		ver, _ = strconv.Atoi(strings.Split(v.string, ".")[0])
+	}
+	if ver >= 7 {
+		if reply := cc.Do(context.Background(), cmds.ShardsCmd); reply.Error() == nil {
+			return clusterslots{reply: reply, addr: cc.Addr(), ver: ver}
+		}
+		ver = 0 // fall back to CLUSTER SLOTS, since CLUSTER SHARDS may not be supported by proxies or managed services
+	}
+	return clusterslots{reply: cc.Do(context.Background(), cmds.SlotCmd), addr: cc.Addr(), ver: ver}
 }
 
 func (c *clusterClient) _refresh() (err error) {
 	var reply RedisMessage
-	var addr string
+	var result clusterslots
 
 	c.mu.RLock()
 	results := make(chan clusterslots, len(c.conns))
 	pending := make([]conn, 0, len(c.conns))
 	for _, cc := range c.conns {
-		pending = append(pending, cc)
+		pending = append(pending, cc.conn)
 	}
 	c.mu.RUnlock()
 
 	for i := 0; i < cap(results); i++ {
-		if i&3 == 0 { // batch CLUSTER SLOTS for every 4 connections
+		if i&3 == 0 { // batch CLUSTER SLOTS/SHARDS for every 4 connections
 			for j := i; j < i+4 && j < len(pending); j++ {
 				go func(c conn) {
-					results <- clusterslots{reply: c.Do(context.Background(), cmds.SlotCmd), addr: c.Addr()}
+					results <- getClusterTopology(c)
 				}(pending[j])
 			}
 		}
-		r := <-results
-		addr = r.addr
-		reply, err = r.reply.ToMessage()
+		result = <-results
+		reply, err = result.reply.ToMessage()
 		if len(reply.values) != 0 {
 			break
 		}
@@ -174,46 +257,75 @@ func (c *clusterClient) _refresh() (err error) {
 		return err
 	}
 
-	groups := parseSlots(reply, addr)
+	groups, missing := result.parse(c.opt.TLSConfig != nil)
+	if c.opt.ClusterAddressMapper != nil {
+		groups = remapGroups(groups, result.addr, c.opt.ClusterAddressMapper)
+	}
 
-	// TODO support read from replicas
-	conns := make(map[string]conn, len(groups))
-	for _, g := range groups {
-		for _, addr := range g.nodes {
-			conns[addr] = c.connFn(addr, c.opt)
+	conns := make(map[string]connrole, len(groups))
+	slots := [16384]conn{}
+	rslots := [16384]conn{}
+
+	c.mu.RLock()
+	for master, g := range groups {
+		conns[master] = c._connrole(master, g.hosts[0], false)
+		for i, addr := range g.nodes[1:] {
+			conns[addr] = c._connrole(addr, g.hosts[i+1], c.rOpt != nil)
 		}
 	}
 	// make sure InitAddress always be present
 	for _, addr := range c.opt.InitAddress {
 		if _, ok := conns[addr]; !ok {
-			conns[addr] = c.connFn(addr, c.opt)
-		}
-	}
-
-	var removes []conn
-
-	c.mu.RLock()
-	for addr, cc := range c.conns {
-		if _, ok := conns[addr]; ok {
-			conns[addr] = cc
-		} else {
-			removes = append(removes, cc)
+			conns[addr] = c._connrole(addr, "", false)
 		}
 	}
-	c.mu.RUnlock()
-
-	slots := [16384]conn{}
 	for master, g := range groups {
-		cc := conns[master]
+		cc := conns[master].conn
 		for _, slot := range g.slots {
 			for i := slot[0]; i <= slot[1]; i++ {
 				slots[i] = cc
+				rslots[i] = cc
+			}
+		}
+		if c.rOpt == nil || len(g.nodes) < 2 {
+			continue
+		}
+		replicas := g.nodes[1:]
+		for _, slot := range g.slots {
+		next:
+			for i := slot[0]; i <= slot[1]; i++ {
+				for _, r := range replicas { // keep the previous choice if it is still a replica of the slot
+					if prev := conns[r].conn; prev == c.rslots[i] {
+						rslots[i] = prev
+						continue next
+					}
+				}
+				if r := c.selectReplica(uint16(i), replicas); r >= 0 && r < len(replicas) {
+					rslots[i] = conns[replicas[r]].conn
+				}
 			}
 		}
 	}
+	if len(missing) != 0 {
+		c._keepMissing(missing, conns, &slots, &rslots)
+	}
+	changed := len(conns) != len(c.conns) || slots != c.slots || rslots != c.rslots
+	var removes []conn
+	for addr, cc := range c.conns {
+		if fresh, ok := conns[addr]; !ok || fresh.conn != cc.conn {
+			removes = append(removes, cc.conn)
+			changed = true
+		}
+	}
+	c.mu.RUnlock()
+
+	if !changed {
+		return nil
+	}
 
 	c.mu.Lock()
 	c.slots = slots
+	c.rslots = rslots
 	c.conns = conns
 	c.mu.Unlock()
 
@@ -229,8 +341,76 @@ func (c *clusterClient) _refresh() (err error) {
 	return nil
 }
 
+// _keepMissing keeps the previous nodes of the missing slots, so that commands to them still follow MOVED redirections
+// after a failover instead of failing with ErrNoSlot. It must be called with c.mu held.
+func (c *clusterClient) _keepMissing(missing [][2]int64, conns map[string]connrole, slots, rslots *[16384]conn) {
+	kept := make(map[conn]conn)
+	for _, slot := range missing {
+		for i := slot[0]; i <= slot[1]; i++ {
+			if slots[i] == nil && c.slots[i] != nil {
+				kept[c.slots[i]] = c.slots[i]
+				kept[c.rslots[i]] = c.rslots[i]
+			}
+		}
+	}
+	if len(kept) == 0 {
+		return
+	}
+	for addr, cc := range c.conns {
+		if _, ok := kept[cc.conn]; !ok {
+			continue
+		}
+		if fresh, ok := conns[addr]; ok {
+			kept[cc.conn] = fresh.conn // the node is still there, but its conn may have been replaced due to a role change
+		} else {
+			conns[addr] = cc
+		}
+	}
+	for _, slot := range missing {
+		for i := slot[0]; i <= slot[1]; i++ {
+			if slots[i] == nil && c.slots[i] != nil {
+				slots[i] = kept[c.slots[i]]
+				rslots[i] = kept[c.rslots[i]]
+			}
+		}
+	}
+}
+
+// _connrole must be called with c.mu held
+func (c *clusterClient) _connrole(addr, hostname string, replica bool) connrole {
+	opt := c.opt
+	if replica {
+		opt = c.rOpt
+	}
+	if opt.TLSConfig == nil || opt.TLSServerNameFn != nil {
+		hostname = "" // the hostname is only used to verify the node by tls
+	}
+	// a conn made by a redirection doesn't know the hostname, and it is replaced here to be verified by the hostname.
+	if cc, ok := c.conns[addr]; ok && cc.replica == replica && cc.host == hostname {
+		return cc
+	}
+	return c.newConnrole(addr, hostname, opt, replica)
+}
+
+func (c *clusterClient) newConnrole(addr, hostname string, opt *ClientOption, replica bool) connrole {
+	if hostname != "" {
+		// verify the node by its announced hostname, since it may be reached by IP
+		o := *opt
+		o.TLSServerNameFn = func(string) string { return hostname }
+		opt = &o
+	}
+	return connrole{conn: c.connFn(addr, opt), host: hostname, replica: replica}
+}
+
+func (c *clusterClient) selectReplica(slot uint16, replicas []string) int {
+	if c.opt.ReplicaSelector != nil {
+		return c.opt.ReplicaSelector(slot, replicas)
+	}
+	return RandomReplicaSelector(slot, replicas)
+}
+
 func (c *clusterClient) single() conn {
-	return c._pick(cmds.InitSlot)
+	return c._pick(cmds.InitSlot, false)
 }
 
 func (c *clusterClient) nodes() []string {
@@ -245,6 +425,7 @@ func (c *clusterClient) nodes() []string {
 
 type group struct {
 	nodes []string
+	hosts []string // the announced hostnames of nodes, empty if unknown
 	slots [][2]int64
 }
 
@@ -266,6 +447,7 @@ func parseSlots(slots RedisMessage, defaultAddr string) map[string]group {
 		if !ok {
 			g.slots = make([][2]int64, 0)
 			g.nodes = make([]string, 0, len(v.values)-2)
+			g.hosts = make([]string, 0, len(v.values)-2)
 			for i := 2; i < len(v.values); i++ {
 				var dst string
 				switch v.values[i].values[0].string {
@@ -277,6 +459,7 @@ func parseSlots(slots RedisMessage, defaultAddr string) map[string]group {
 					dst = net.JoinHostPort(v.values[i].values[0].string, strconv.FormatInt(v.values[i].values[1].integer, 10))
 				}
 				g.nodes = append(g.nodes, dst)
+				g.hosts = append(g.hosts, slotsHostname(v.values[i]))
 			}
 		}
 		g.slots = append(g.slots, [2]int64{v.values[0].integer, v.values[1].integer})
@@ -285,13 +468,102 @@ func parseSlots(slots RedisMessage, defaultAddr string) map[string]group {
 	return groups
 }
 
-func (c *clusterClient) _pick(slot uint16) (p conn) {
+// remapGroups applies the ClientOption.ClusterAddressMapper to the announced addresses.
+// defaultAddr is the address already used to reach the node, so it is kept as it is.
+func remapGroups(groups map[string]group, defaultAddr string, mapper func(string) string) map[string]group {
+	remapped := make(map[string]group, len(groups))
+	for _, g := range groups {
+		for i, addr := range g.nodes {
+			if addr != defaultAddr {
+				g.nodes[i] = mapper(addr)
+			}
+		}
+		remapped[g.nodes[0]] = g
+	}
+	return remapped
+}
+
+// slotsHostname returns the hostname in the networking metadata of a CLUSTER SLOTS node, which is available since redis 7
+func slotsHostname(node RedisMessage) string {
+	if len(node.values) < 4 {
+		return ""
+	}
+	meta := node.values[3].values
+	for i := 0; i+1 < len(meta); i += 2 {
+		if meta[i].string == "hostname" {
+			return meta[i+1].string
+		}
+	}
+	return ""
+}
+
+// parseShards - map redis shards for each redis nodes/addresses
+// defaultAddr is needed in case the node does not know its own IP
+// nodes whose health is not online are skipped, and the shard is skipped if it has no online primary,
+// in which case its slots are returned as missing.
+func parseShards(shards RedisMessage, defaultAddr string, tls bool) (groups map[string]group, missing [][2]int64) {
+	groups = make(map[string]group, len(shards.values))
+	for _, v := range shards.values {
+		shard, err := v.AsMap()
+		if err != nil {
+			continue
+		}
+		slots := shard["slots"].values
+		nodes := shard["nodes"].values
+		g := group{
+			nodes: make([]string, 0, len(nodes)),
+			hosts: make([]string, 0, len(nodes)),
+			slots: make([][2]int64, len(slots)/2),
+		}
+		for i := range g.slots {
+			g.slots[i][0], _ = slots[i*2].AsInt64()
+			g.slots[i][1], _ = slots[i*2+1].AsInt64()
+		}
+		m := -1
+		for _, n := range nodes {
+			dict, err := n.AsMap()
+			if err != nil || dict["health"].string != "online" {
+				continue
+			}
+			port := dict["port"].integer
+			if tp := dict["tls-port"].integer; tls && tp > 0 {
+				port = tp
+			}
+			var dst string
+			switch endpoint := dict["endpoint"].string; endpoint {
+			case "":
+				dst = defaultAddr
+			case "?":
+				continue
+			default:
+				dst = net.JoinHostPort(endpoint, strconv.FormatInt(port, 10))
+			}
+			if dict["role"].string == "master" {
+				m = len(g.nodes)
+			}
+			g.nodes = append(g.nodes, dst)
+			g.hosts = append(g.hosts, dict["hostname"].string)
+		}
+		if m < 0 {
+			missing = append(missing, g.slots...)
+			continue
+		}
+		g.nodes[0], g.nodes[m] = g.nodes[m], g.nodes[0]
+		g.hosts[0], g.hosts[m] = g.hosts[m], g.hosts[0]
+		groups[g.nodes[0]] = g
+	}
+	return groups, missing
+}
+
+func (c *clusterClient) _pick(slot uint16, toReplica bool) (p conn) {
 	c.mu.RLock()
 	if slot == cmds.InitSlot {
 		for _, cc := range c.conns {
-			p = cc
+			p = cc.conn
 			break
 		}
+	} else if toReplica {
+		p = c.rslots[slot]
 	} else {
 		p = c.slots[slot]
 	}
@@ -299,30 +571,35 @@ func (c *clusterClient) _pick(slot uint16) (p conn) {
 	return p
 }
 
-func (c *clusterClient) pick(slot uint16) (p conn, err error) {
-	if p = c._pick(slot); p == nil {
+func (c *clusterClient) pick(slot uint16, toReplica bool) (p conn, err error) {
+	if p = c._pick(slot, toReplica); p == nil {
 		if err := c.refresh(); err != nil {
 			return nil, err
 		}
-		if p = c._pick(slot); p == nil {
+		if p = c._pick(slot, toReplica); p == nil {
 			return nil, ErrNoSlot
 		}
 	}
 	return p, nil
 }
 
+func (c *clusterClient) toReplica(cmd Completed) bool {
+	return c.rOpt != nil && cmd.IsReadOnly()
+}
+
 func (c *clusterClient) redirectOrNew(addr string, prev conn) (p conn) {
 	c.mu.RLock()
-	p = c.conns[addr]
+	p = c.conns[addr].conn
 	c.mu.RUnlock()
 	if p != nil && prev != p {
 		return p
 	}
 	c.mu.Lock()
-	if p = c.conns[addr]; p == nil {
+	if cc := c.conns[addr]; cc.conn == nil {
+		// the hostname of a new node is unknown until the next refresh, which replaces the conn if it has one.
 		p = c.connFn(addr, c.opt)
-		c.conns[addr] = p
-	} else if prev == p {
+		c.conns[addr] = connrole{conn: p}
+	} else if p = cc.conn; prev == p {
 		// try reconnection if the MOVED redirects to the same host,
 		// because the same hostname may actually be resolved into another destination
 		// depending on the fail-over implementation. ex: AWS MemoryDB's resize process.
@@ -330,8 +607,9 @@ func (c *clusterClient) redirectOrNew(addr string, prev conn) (p conn) {
 			time.Sleep(time.Second * 5)
 			prev.Close()
 		}(prev)
-		p = c.connFn(addr, c.opt)
-		c.conns[addr] = p
+		cc = c.newConnrole(addr, cc.host, c.opt, false) // keep verifying the node by its hostname
+		p = cc.conn
+		c.conns[addr] = cc
 	}
 	c.mu.Unlock()
 	return p
@@ -349,8 +627,9 @@ func (c *clusterClient) Do(ctx context.Context, cmd Completed) (resp RedisResult
 }
 
 func (c *clusterClient) do(ctx context.Context, cmd Completed) (resp RedisResult) {
+	attempts := 1
 retry:
-	cc, err := c.pick(cmd.Slot())
+	cc, err := c.pick(cmd.Slot(), c.toReplica(cmd))
 	if err != nil {
 		return newErrResult(err)
 	}
@@ -366,8 +645,8 @@ process:
 		resultsp.Put(results)
 		goto process
 	case RedirectRetry:
-		if c.retry && cmd.IsReadOnly() {
-			runtime.Gosched()
+		if c.retry && retrySafe(cmd) && c.retryHandler.WaitOrSkipRetry(ctx, attempts, resp.Error(), cmd) {
+			attempts++
 			goto retry
 		}
 	}
@@ -387,7 +666,7 @@ func (c *clusterClient) _pickMulti(multi []Completed) (retries map[conn]*retry,
 			init = true
 			continue
 		}
-		p := c.slots[cmd.Slot()]
+		p := c._slot(cmd)
 		if p == nil {
 			return nil, 0
 		}
@@ -406,7 +685,7 @@ func (c *clusterClient) _pickMulti(multi []Completed) (retries map[conn]*retry,
 			} else if init && last != cmd.Slot() {
 				panic(panicMixCxSlot)
 			}
-			cc := c.slots[cmd.Slot()]
+			cc := c._slot(cmd)
 			re := retries[cc]
 			re.commands = append(re.commands, cmd)
 			re.cIndexes = append(re.cIndexes, i)
@@ -416,6 +695,14 @@ func (c *clusterClient) _pickMulti(multi []Completed) (retries map[conn]*retry,
 	return retries, last
 }
 
+// _slot must be called with c.mu held
+func (c *clusterClient) _slot(cmd Completed) conn {
+	if c.toReplica(cmd) {
+		return c.rslots[cmd.Slot()]
+	}
+	return c.slots[cmd.Slot()]
+}
+
 func (c *clusterClient) pickMulti(multi []Completed) (map[conn]*retry, uint16, error) {
 	conns, slot := c._pickMulti(multi)
 	if conns == nil {
@@ -449,6 +736,9 @@ func (c *clusterClient) DoMulti(ctx context.Context, multi ...Completed) []Redis
 	var wg sync.WaitGroup
 	var mu sync.Mutex
 
+	attempts := 1
+	delay := time.Duration(-1) // the longest wait required by the RetryPolicy in this round, -1 if no RedirectRetry
+
 	results := resultsp.Get(len(multi), len(multi))
 	respsfn := func(cc conn, cIndexes []int, commands []Completed, resps []RedisResult) {
 		for i, resp := range resps {
@@ -456,10 +746,18 @@ func (c *clusterClient) DoMulti(ctx context.Context, multi ...Completed) []Redis
 			cm := commands[i]
 			results.s[ii] = resp
 			addr, mode := c.shouldRefreshRetry(resp.Error(), ctx)
-			if c.retry && mode != RedirectNone && cm.IsReadOnly() {
+			if c.retry && mode != RedirectNone && retrySafe(cm) {
 				nc := cc
 				if mode != RedirectRetry {
 					nc = c.redirectOrNew(addr, cc)
+				} else if d := c.retryHandler.RetryDelay(attempts, resp.Error(), cm); d < 0 {
+					continue
+				} else {
+					mu.Lock()
+					if d > delay {
+						delay = d
+					}
+					mu.Unlock()
 				}
 				mu.Lock()
 				nr := retries[nc]
@@ -505,6 +803,11 @@ retry:
 	wg.Wait()
 
 	if len(retries) != 0 {
+		if delay >= 0 {
+			c.retryHandler.WaitForRetry(ctx, delay)
+			attempts++
+			delay = -1
+		}
 		goto retry
 	}
 
@@ -525,31 +828,33 @@ func fillErrs(n int, err error) (results []RedisResult) {
 }
 
 func (c *clusterClient) doMulti(ctx context.Context, slot uint16, multi []Completed) []RedisResult {
+	toReplica := c.rOpt != nil && allReadOnly(multi)
+	attempts := 1
 retry:
-	cc, err := c.pick(slot)
+	cc, err := c.pick(slot, toReplica)
 	if err != nil {
 		return fillErrs(len(multi), err)
 	}
 	resps := cc.DoMulti(ctx, multi...)
 process:
-	for _, resp := range resps.s {
+	for i, resp := range resps.s {
 		switch addr, mode := c.shouldRefreshRetry(resp.Error(), ctx); mode {
 		case RedirectMove:
-			if c.retry && allReadOnly(multi) {
+			if c.retry && allRetrySafe(multi) {
 				resultsp.Put(resps)
 				resps = c.redirectOrNew(addr, cc).DoMulti(ctx, multi...)
 				goto process
 			}
 		case RedirectAsk:
-			if c.retry && allReadOnly(multi) {
+			if c.retry && allRetrySafe(multi) {
 				resultsp.Put(resps)
 				resps = askingMulti(c.redirectOrNew(addr, cc), ctx, multi)
 				goto process
 			}
 		case RedirectRetry:
-			if c.retry && allReadOnly(multi) {
+			if c.retry && allRetrySafe(multi) && c.retryHandler.WaitOrSkipRetry(ctx, attempts, resp.Error(), multi[i]) {
 				resultsp.Put(resps)
-				runtime.Gosched()
+				attempts++
 				goto retry
 			}
 		}
@@ -558,8 +863,9 @@ process:
 }
 
 func (c *clusterClient) doCache(ctx context.Context, cmd Cacheable, ttl time.Duration) (resp RedisResult) {
+	attempts := 1
 retry:
-	cc, err := c.pick(cmd.Slot())
+	cc, err := c.pick(cmd.Slot(), c.rOpt != nil)
 	if err != nil {
 		return newErrResult(err)
 	}
@@ -575,8 +881,8 @@ process:
 		resultsp.Put(results)
 		goto process
 	case RedirectRetry:
-		if c.retry {
-			runtime.Gosched()
+		if c.retry && c.retryHandler.WaitOrSkipRetry(ctx, attempts, resp.Error(), Completed(cmd)) {
+			attempts++
 			goto retry
 		}
 	}
@@ -628,9 +934,14 @@ func (c *clusterClient) _pickMultiCache(multi []CacheableTTL) map[conn]*retrycac
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
+	slots := &c.slots
+	if c.rOpt != nil {
+		slots = &c.rslots
+	}
+
 	count := make(map[conn]int, len(c.conns))
 	for _, cmd := range multi {
-		p := c.slots[cmd.Cmd.Slot()]
+		p := slots[cmd.Cmd.Slot()]
 		if p == nil {
 			return nil
 		}
@@ -643,7 +954,7 @@ func (c *clusterClient) _pickMultiCache(multi []CacheableTTL) map[conn]*retrycac
 	}
 
 	for i, cmd := range multi {
-		cc := c.slots[cmd.Cmd.Slot()]
+		cc := slots[cmd.Cmd.Slot()]
 		re := retries[cc]
 		re.commands = append(re.commands, cmd)
 		re.cIndexes = append(re.cIndexes, i)
@@ -678,6 +989,9 @@ func (c *clusterClient) DoMultiCache(ctx context.Context, multi ...CacheableTTL)
 	var wg sync.WaitGroup
 	var mu sync.Mutex
 
+	attempts := 1
+	delay := time.Duration(-1) // the longest wait required by the RetryPolicy in this round, -1 if no RedirectRetry
+
 	results := resultsp.Get(len(multi), len(multi))
 	respsfn := func(cc conn, cIndexes []int, commands []CacheableTTL, resps []RedisResult) {
 		for i, resp := range resps {
@@ -689,6 +1003,14 @@ func (c *clusterClient) DoMultiCache(ctx context.Context, multi ...CacheableTTL)
 				nc := cc
 				if mode != RedirectRetry {
 					nc = c.redirectOrNew(addr, cc)
+				} else if d := c.retryHandler.RetryDelay(attempts, resp.Error(), Completed(cm.Cmd)); d < 0 {
+					continue
+				} else {
+					mu.Lock()
+					if d > delay {
+						delay = d
+					}
+					mu.Unlock()
 				}
 				mu.Lock()
 				nr := retries[nc]
@@ -732,6 +1054,11 @@ retry:
 	wg.Wait()
 
 	if len(retries) != 0 {
+		if delay >= 0 {
+			c.retryHandler.WaitForRetry(ctx, delay)
+			attempts++
+			delay = -1
+		}
 		goto retry
 	}
 
@@ -743,16 +1070,91 @@ retry:
 	return results.s
 }
 
+func (c *clusterClient) DoStream(ctx context.Context, cmd Completed) RedisResultStream {
+	cc, err := c.pick(cmd.Slot(), c.toReplica(cmd))
+	if err != nil {
+		return RedisResultStream{e: err}
+	}
+	return c.doStream(ctx, cc, cmd, 1)
+}
+
+// doStream lets the stream send the cmd again if its WriteTo fails before writing anything, by following the MOVED
+// redirection or by retrying the cmd. The ASK redirection is retried like TRYAGAIN, because the ASKING can't be sent
+// before the cmd on the dedicated connection. The cmd is not recycled since it may be sent again later.
+func (c *clusterClient) doStream(ctx context.Context, cc conn, cmd Completed, attempts int) (s RedisResultStream) {
+	redo := func(err error) (RedisResultStream, bool) {
+		switch addr, mode := c.shouldRefreshRetry(err, ctx); mode {
+		case RedirectMove:
+			return c.doStream(ctx, c.redirectOrNew(addr, cc), cmd, attempts), true
+		case RedirectAsk, RedirectRetry:
+			if c.retry && retrySafe(cmd) && c.retryHandler.WaitOrSkipRetry(ctx, attempts, err, cmd) {
+				if cc, err := c.pick(cmd.Slot(), c.toReplica(cmd)); err == nil {
+					return c.doStream(ctx, cc, cmd, attempts+1), true
+				}
+			}
+		}
+		return RedisResultStream{}, false
+	}
+	if s = cc.DoStream(ctx, cmd); s.e != nil {
+		if next, ok := redo(s.e); ok {
+			return next
+		}
+	}
+	s.redo = redo
+	return s
+}
+
+func (c *clusterClient) DoIter(ctx context.Context, cmd Completed, fn func(elem RedisMessage) error) (err error) {
+	attempts := 1
+	called := false
+	iter := func(elem RedisMessage) error {
+		called = true
+		return fn(elem)
+	}
+retry:
+	cc, err := c.pick(cmd.Slot(), c.toReplica(cmd))
+	if err != nil {
+		return err
+	}
+	err = cc.DoIter(ctx, cmd, iter)
+process:
+	if called { // the cmd can't be sent again once the fn has seen any element
+		goto ret
+	}
+	// the ASK redirection is retried like TRYAGAIN, because the ASKING can't be sent before the cmd on the dedicated connection
+	switch addr, mode := c.shouldRefreshRetry(err, ctx); mode {
+	case RedirectMove:
+		err = c.redirectOrNew(addr, cc).DoIter(ctx, cmd, iter)
+		goto process
+	case RedirectAsk, RedirectRetry:
+		if c.retry && retrySafe(cmd) && c.retryHandler.WaitOrSkipRetry(ctx, attempts, err, cmd) {
+			attempts++
+			goto retry
+		}
+	}
+ret:
+	if err == nil {
+		cmds.PutCompleted(cmd)
+	}
+	return err
+}
+
 func (c *clusterClient) Receive(ctx context.Context, subscribe Completed, fn func(msg PubSubMessage)) (err error) {
+	attempts := 1
 retry:
-	cc, err := c.pick(subscribe.Slot())
+	cc, err := c.pick(subscribe.Slot(), false)
 	if err != nil {
 		goto ret
 	}
 	err = cc.Receive(ctx, subscribe, fn)
 	if _, mode := c.shouldRefreshRetry(err, ctx); c.retry && mode != RedirectNone {
-		runtime.Gosched()
-		goto retry
+		if mode != RedirectRetry {
+			goto retry
+		}
+		if c.retryHandler.WaitOrSkipRetry(ctx, attempts, err, subscribe) {
+			attempts++
+			goto retry
+		}
 	}
 ret:
 	if err == nil {
@@ -762,49 +1164,95 @@ ret:
 }
 
 func (c *clusterClient) Dedicated(fn func(DedicatedClient) error) (err error) {
-	dcc := &dedicatedClusterClient{cmd: c.cmd, client: c, slot: cmds.NoSlot, retry: c.retry}
+	return c.DedicatedCtx(context.Background(), fn)
+}
+
+func (c *clusterClient) DedicatedCtx(ctx context.Context, fn func(DedicatedClient) error) (err error) {
+	dcc := &dedicatedClusterClient{cmd: c.cmd, client: c, ctx: ctx, slot: cmds.NoSlot, retry: c.retry, retryHandler: c.retryHandler}
 	err = fn(dcc)
 	dcc.release()
 	return err
 }
 
 func (c *clusterClient) Dedicate() (DedicatedClient, func()) {
-	dcc := &dedicatedClusterClient{cmd: c.cmd, client: c, slot: cmds.NoSlot, retry: c.retry}
-	return dcc, dcc.release
+	client, cancel, _ := c.DedicateCtx(context.Background())
+	return client, cancel
+}
+
+// DedicateCtx of the cluster client never fails because the slot is unknown until the first command,
+// so the acquisition is deferred to it. The ctx is kept until a connection is acquired, which means commands
+// fail with the ErrPoolExhausted and ctx error instead if the ctx is done by then.
+func (c *clusterClient) DedicateCtx(ctx context.Context) (DedicatedClient, func(), error) {
+	dcc := &dedicatedClusterClient{cmd: c.cmd, client: c, ctx: ctx, slot: cmds.NoSlot, retry: c.retry, retryHandler: c.retryHandler}
+	return dcc, dcc.release, nil
 }
 
 func (c *clusterClient) Nodes() map[string]Client {
 	c.mu.RLock()
 	nodes := make(map[string]Client, len(c.conns))
-	for addr, conn := range c.conns {
-		nodes[addr] = newSingleClientWithConn(conn, c.cmd, c.retry)
+	for addr, cc := range c.conns {
+		nodes[addr] = newSingleClientWithConn(cc.conn, c.cmd, c.retry, c.retryHandler)
 	}
 	c.mu.RUnlock()
 	return nodes
 }
 
+func (c *clusterClient) Stats() ClientStats {
+	c.mu.RLock()
+	stats := ClientStats{Nodes: make(map[string]NodeStats, len(c.conns))}
+	for addr, cc := range c.conns {
+		stats.Nodes[addr] = cc.conn.NodeStats()
+	}
+	c.mu.RUnlock()
+	return stats
+}
+
 func (c *clusterClient) Close() {
-	atomic.StoreUint32(&c.stop, 1)
+	if atomic.CompareAndSwapUint32(&c.stop, 0, 1) {
+		close(c.stopCh)
+	}
+	c.mu.RLock()
+	for _, cc := range c.conns {
+		go cc.conn.Close()
+	}
+	c.mu.RUnlock()
+}
+
+func (c *clusterClient) CloseWithContext(ctx context.Context) error {
+	if atomic.CompareAndSwapUint32(&c.stop, 0, 1) {
+		close(c.stopCh)
+	}
+	var abandoned atomic.Int64
+	var wg sync.WaitGroup
 	c.mu.RLock()
 	for _, cc := range c.conns {
-		go cc.Close()
+		wg.Add(1)
+		go func(cc conn) {
+			abandoned.Add(int64(cc.CloseWithContext(ctx)))
+			wg.Done()
+		}(cc.conn)
 	}
 	c.mu.RUnlock()
+	wg.Wait()
+	return newCloseError(ctx, int(abandoned.Load()))
 }
 
 func (c *clusterClient) shouldRefreshRetry(err error, ctx context.Context) (addr string, mode RedirectMode) {
 	if err != nil && atomic.LoadUint32(&c.stop) == 0 {
-		if err, ok := err.(*RedisError); ok {
-			if addr, ok = err.IsMoved(); ok {
+		if rerr, ok := err.(*RedisError); ok {
+			if addr, ok = rerr.IsMoved(); ok {
 				mode = RedirectMove
-			} else if addr, ok = err.IsAsk(); ok {
+			} else if addr, ok = rerr.IsAsk(); ok {
 				mode = RedirectAsk
-			} else if err.IsClusterDown() || err.IsTryAgain() {
+			} else if rerr.IsClusterDown() || rerr.IsTryAgain() {
 				mode = RedirectRetry
 			}
-		} else if ctx.Err() == nil {
+		} else if ctx.Err() == nil && retryableErr(err) {
 			mode = RedirectRetry
 		}
+		if (mode == RedirectMove || mode == RedirectAsk) && c.opt.ClusterAddressMapper != nil {
+			addr = c.opt.ClusterAddressMapper(addr)
+		}
 		if mode != RedirectNone {
 			go c.refresh()
 		}
@@ -817,12 +1265,15 @@ type dedicatedClusterClient struct {
 	conn   conn
 	wire   wire
 	pshks  *pshks
+	ctx    context.Context // only for the first attempt to acquire the wire
 
 	mu    sync.Mutex
 	cmd   cmds.Builder
 	slot  uint16
 	mark  bool
 	retry bool
+
+	retryHandler retryHandler
 }
 
 func (c *dedicatedClusterClient) acquire(slot uint16) (wire wire, err error) {
@@ -836,7 +1287,10 @@ func (c *dedicatedClusterClient) acquire(slot uint16) (wire wire, err error) {
 	if c.wire != nil {
 		return c.wire, nil
 	}
-	if c.conn, err = c.client.pick(c.slot); err != nil {
+	if c.conn, err = c.client.pick(c.slot, false); err == nil {
+		c.wire, err = c.conn.Acquire(c.ctx)
+	}
+	if err != nil {
 		if p := c.pshks; p != nil {
 			c.pshks = nil
 			p.close <- err
@@ -844,7 +1298,6 @@ func (c *dedicatedClusterClient) acquire(slot uint16) (wire wire, err error) {
 		}
 		return nil, err
 	}
-	c.wire = c.conn.Acquire()
 	if p := c.pshks; p != nil {
 		c.pshks = nil
 		ch := c.wire.SetPubSubHooks(p.hooks)
@@ -878,6 +1331,7 @@ func (c *dedicatedClusterClient) B() cmds.Builder {
 }
 
 func (c *dedicatedClusterClient) Do(ctx context.Context, cmd Completed) (resp RedisResult) {
+	attempts := 1
 retry:
 	if w, err := c.acquire(cmd.Slot()); err != nil {
 		resp = newErrResult(err)
@@ -885,8 +1339,8 @@ retry:
 		resp = w.Do(ctx, cmd)
 		switch _, mode := c.client.shouldRefreshRetry(resp.Error(), ctx); mode {
 		case RedirectRetry:
-			if c.retry && cmd.IsReadOnly() && w.Error() == nil {
-				runtime.Gosched()
+			if c.retry && retrySafe(cmd) && w.Error() == nil && c.retryHandler.WaitOrSkipRetry(ctx, attempts, resp.Error(), cmd) {
+				attempts++
 				goto retry
 			}
 		}
@@ -907,15 +1361,16 @@ func (c *dedicatedClusterClient) DoMulti(ctx context.Context, multi ...Completed
 	}
 	retryable := c.retry
 	if retryable {
-		retryable = allReadOnly(multi)
+		retryable = allRetrySafe(multi)
 	}
+	attempts := 1
 retry:
 	if w, err := c.acquire(slot); err == nil {
 		resp = w.DoMulti(ctx, multi...).s
-		for _, r := range resp {
+		for i, r := range resp {
 			_, mode := c.client.shouldRefreshRetry(r.Error(), ctx)
-			if mode == RedirectRetry && retryable && w.Error() == nil {
-				runtime.Gosched()
+			if mode == RedirectRetry && retryable && w.Error() == nil && c.retryHandler.WaitOrSkipRetry(ctx, attempts, r.Error(), multi[i]) {
+				attempts++
 				goto retry
 			}
 			if mode != RedirectNone {
@@ -938,11 +1393,12 @@ retry:
 
 func (c *dedicatedClusterClient) Receive(ctx context.Context, subscribe Completed, fn func(msg PubSubMessage)) (err error) {
 	var w wire
+	attempts := 1
 retry:
 	if w, err = c.acquire(subscribe.Slot()); err == nil {
 		err = w.Receive(ctx, subscribe, fn)
-		if _, mode := c.client.shouldRefreshRetry(err, ctx); c.retry && mode == RedirectRetry && w.Error() == nil {
-			runtime.Gosched()
+		if _, mode := c.client.shouldRefreshRetry(err, ctx); c.retry && mode == RedirectRetry && w.Error() == nil && c.retryHandler.WaitOrSkipRetry(ctx, attempts, err, subscribe) {
+			attempts++
 			goto retry
 		}
 	}
@@ -1,9 +1,14 @@
 package rueidis
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
+	"io"
+	"net"
 	"reflect"
 	"sort"
 	"strconv"
@@ -112,6 +117,49 @@ var singleSlotWithoutIP = newResult(RedisMessage{typ: '*', values: []RedisMessag
 	}},
 }}, nil)
 
+var shardsResp = newResult(RedisMessage{typ: '*', values: []RedisMessage{
+	{typ: '%', values: []RedisMessage{
+		{typ: '+', string: "slots"},
+		{typ: '*', values: []RedisMessage{{typ: ':', integer: 0}, {typ: ':', integer: 8192}}},
+		{typ: '+', string: "nodes"},
+		{typ: '*', values: []RedisMessage{
+			{typ: '%', values: []RedisMessage{
+				{typ: '+', string: "port"}, {typ: ':', integer: 1},
+				{typ: '+', string: "endpoint"}, {typ: '+', string: "127.0.1.1"},
+				{typ: '+', string: "hostname"}, {typ: '+', string: "node1"},
+				{typ: '+', string: "role"}, {typ: '+', string: "replica"},
+				{typ: '+', string: "health"}, {typ: '+', string: "online"},
+			}},
+			{typ: '%', values: []RedisMessage{
+				{typ: '+', string: "port"}, {typ: ':', integer: 0},
+				{typ: '+', string: "endpoint"}, {typ: '+', string: "127.0.0.1"},
+				{typ: '+', string: "role"}, {typ: '+', string: "master"},
+				{typ: '+', string: "health"}, {typ: '+', string: "online"},
+			}},
+		}},
+	}},
+	{typ: '%', values: []RedisMessage{
+		{typ: '+', string: "slots"},
+		{typ: '*', values: []RedisMessage{{typ: ':', integer: 8193}, {typ: ':', integer: 16383}}},
+		{typ: '+', string: "nodes"},
+		{typ: '*', values: []RedisMessage{
+			{typ: '%', values: []RedisMessage{
+				{typ: '+', string: "port"}, {typ: ':', integer: 0},
+				{typ: '+', string: "endpoint"}, {typ: '+', string: "127.0.2.1"},
+				{typ: '+', string: "hostname"}, {typ: '+', string: "node2"},
+				{typ: '+', string: "role"}, {typ: '+', string: "master"},
+				{typ: '+', string: "health"}, {typ: '+', string: "online"},
+			}},
+			{typ: '%', values: []RedisMessage{
+				{typ: '+', string: "port"}, {typ: ':', integer: 1},
+				{typ: '+', string: "endpoint"}, {typ: '+', string: "127.0.3.1"},
+				{typ: '+', string: "role"}, {typ: '+', string: "replica"},
+				{typ: '+', string: "health"}, {typ: '+', string: "fail"},
+			}},
+		}},
+	}},
+}}, nil)
+
 //gocyclo:ignore
 func TestClusterClientInit(t *testing.T) {
 	defer ShouldNotLeaked(SetupLeakDetection())
@@ -404,6 +452,53 @@ func TestClusterClient(t *testing.T) {
 		}
 	})
 
+	t.Run("Delegate DoStream", func(t *testing.T) {
+		c := client.B().Get().Key("Do").Build()
+		e := errors.New("stream")
+		m.DoStreamFn = func(cmd Completed) RedisResultStream {
+			if !reflect.DeepEqual(cmd.Commands(), c.Commands()) {
+				t.Fatalf("unexpected command %v", cmd)
+			}
+			return RedisResultStream{e: e}
+		}
+		if s := client.DoStream(context.Background(), c); s.Error() != e {
+			t.Fatalf("unexpected response %v", s.Error())
+		}
+	})
+
+	t.Run("Delegate DoIter", func(t *testing.T) {
+		c := client.B().Hgetall().Key("Do").Build()
+		m.DoIterFn = func(cmd Completed, fn func(elem RedisMessage) error) error {
+			if !reflect.DeepEqual(cmd.Commands(), c.Commands()) {
+				t.Fatalf("unexpected command %v", cmd)
+			}
+			return fn(RedisMessage{typ: '+', string: "Do"})
+		}
+		if err := client.DoIter(context.Background(), c, func(elem RedisMessage) error {
+			if v, _ := elem.ToString(); v != "Do" {
+				t.Fatalf("unexpected element %v", elem)
+			}
+			return nil
+		}); err != nil {
+			t.Fatalf("unexpected response %v", err)
+		}
+	})
+
+	t.Run("Delegate Stats", func(t *testing.T) {
+		m.NodeStatsFn = func() NodeStats {
+			return NodeStats{Dials: 1}
+		}
+		stats := client.Stats()
+		if len(stats.Nodes) != len(client.Nodes()) {
+			t.Fatalf("unexpected stats %v", stats)
+		}
+		for _, s := range stats.Nodes {
+			if s.Dials != 1 {
+				t.Fatalf("unexpected stats %v", stats)
+			}
+		}
+	})
+
 	t.Run("Delegate Receive", func(t *testing.T) {
 		c := client.B().Subscribe().Channel("ch").Build()
 		hdl := func(message PubSubMessage) {}
@@ -439,6 +534,20 @@ func TestClusterClient(t *testing.T) {
 		<-called
 	})
 
+	t.Run("Delegate CloseWithContext", func(t *testing.T) {
+		m.CloseWithCtxFn = func(ctx context.Context) int { return 1 }
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		var ce *CloseError
+		if err := client.CloseWithContext(ctx); !errors.As(err, &ce) || ce.Abandoned != len(client.Nodes()) || !errors.Is(err, context.Canceled) {
+			t.Fatalf("unexpected err %v", err)
+		}
+		m.CloseWithCtxFn = nil
+		if err := client.CloseWithContext(context.Background()); err != nil {
+			t.Fatalf("unexpected err %v", err)
+		}
+	})
+
 	t.Run("Dedicated Err", func(t *testing.T) {
 		v := errors.New("fn err")
 		if err := client.Dedicated(func(client DedicatedClient) error {
@@ -803,6 +912,71 @@ func TestClusterClient(t *testing.T) {
 			t.Fatalf("unexpected ret %v", ch)
 		}
 	})
+
+	t.Run("DedicatedCtx Delegate", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		w := &mockWire{}
+		m.AcquireCtxFn = func(c context.Context) (wire, error) {
+			if c != ctx {
+				t.Fatalf("unexpected ctx %v", c)
+			}
+			return w, nil
+		}
+		defer func() { m.AcquireCtxFn = nil }()
+		stored := 0
+		m.StoreFn = func(ww wire) { stored++ }
+		if err := client.DedicatedCtx(ctx, func(c DedicatedClient) error {
+			return c.Do(ctx, c.B().Get().Key("a").Build()).Error()
+		}); err != nil {
+			t.Fatalf("unexpected err %v", err)
+		}
+		c, release, err := client.DedicateCtx(ctx)
+		if err != nil || c == nil {
+			t.Fatalf("unexpected dedicate %v %v", c, err)
+		}
+		c.Do(ctx, c.B().Get().Key("a").Build())
+		release()
+		if stored != 2 {
+			t.Fatalf("unexpected stored count %v", stored)
+		}
+
+		e := errors.New("exhausted")
+		m.AcquireCtxFn = func(c context.Context) (wire, error) { return nil, e }
+		if err := client.DedicatedCtx(ctx, func(c DedicatedClient) error {
+			return c.Do(ctx, c.B().Get().Key("a").Build()).Error()
+		}); err != e {
+			t.Fatalf("unexpected err %v", err)
+		}
+		if c, release, err := client.DedicateCtx(ctx); err == nil {
+			err = c.Do(ctx, c.B().Get().Key("a").Build()).Error()
+			release()
+			if err != e {
+				t.Fatalf("unexpected err %v", err)
+			}
+		} else if c != nil || release != nil || err != e {
+			t.Fatalf("unexpected dedicate %v %v", c, err)
+		}
+
+		acquired := 0
+		m.AcquireCtxFn = func(c context.Context) (wire, error) {
+			if c != ctx {
+				t.Fatalf("unexpected ctx %v", c)
+			}
+			if acquired++; acquired == 1 {
+				return nil, e
+			}
+			return w, nil
+		}
+		if err := client.DedicatedCtx(ctx, func(c DedicatedClient) error {
+			if err := c.Do(ctx, c.B().Get().Key("a").Build()).Error(); err != e {
+				t.Fatalf("unexpected err %v", err)
+			}
+			return c.Do(ctx, c.B().Get().Key("a").Build()).Error()
+		}); err != nil || acquired != 2 {
+			t.Fatalf("unexpected err %v %v", err, acquired)
+		}
+	})
 }
 
 //gocyclo:ignore
@@ -1016,7 +1190,7 @@ func TestClusterClientErr(t *testing.T) {
 		if v, err := client.Do(context.Background(), client.B().Get().Key("a").Build()).ToString(); err != nil || v != "b" {
 			t.Fatalf("unexpected resp %v %v", v, err)
 		}
-		if atomic.LoadInt64(&check) != 6 {
+		if atomic.LoadInt64(&check) != 5 {
 			t.Fatalf("unexpected check count %v", check)
 		}
 	})
@@ -1610,6 +1784,674 @@ func TestClusterClientErr(t *testing.T) {
 	})
 }
 
+func TestClusterClientShards(t *testing.T) {
+	defer ShouldNotLeaked(SetupLeakDetection())
+	client, err := newClusterClient(&ClientOption{InitAddress: []string{"127.0.0.1:0"}}, func(dst string, opt *ClientOption) conn {
+		return &mockConn{
+			InfoFn: func() map[string]RedisMessage {
+				return map[string]RedisMessage{"version": {typ: '+', string: "7.0.0"}}
+			},
+			DoFn: func(cmd Completed) RedisResult {
+				if strings.Join(cmd.Commands(), " ") == "CLUSTER SHARDS" {
+					return shardsResp
+				}
+				t.Fatalf("unexpected command %v", cmd.Commands())
+				return RedisResult{}
+			},
+		}
+	})
+	if err != nil {
+		t.Fatalf("unexpected err %v", err)
+	}
+	defer client.Close()
+	nodes := client.Nodes()
+	if len(nodes) != 3 || nodes["127.0.0.1:0"] == nil || nodes["127.0.1.1:1"] == nil || nodes["127.0.2.1:0"] == nil {
+		t.Fatalf("unexpected nodes %v", nodes)
+	}
+	if client.slots[0] != client.conns["127.0.0.1:0"].conn || client.slots[16383] != client.conns["127.0.2.1:0"].conn {
+		t.Fatalf("unexpected slots")
+	}
+}
+
+func TestClusterClientShardsFallback(t *testing.T) {
+	defer ShouldNotLeaked(SetupLeakDetection())
+	var shards, slots int64
+	client, err := newClusterClient(&ClientOption{InitAddress: []string{"127.0.0.1:0"}}, func(dst string, opt *ClientOption) conn {
+		return &mockConn{
+			InfoFn: func() map[string]RedisMessage {
+				return map[string]RedisMessage{"version": {typ: '+', string: "7.0.0"}}
+			},
+			DoFn: func(cmd Completed) RedisResult {
+				switch strings.Join(cmd.Commands(), " ") {
+				case "CLUSTER SHARDS":
+					atomic.AddInt64(&shards, 1)
+					return newResult(RedisMessage{typ: '-', string: "ERR unknown subcommand 'SHARDS'"}, nil)
+				case "CLUSTER SLOTS":
+					atomic.AddInt64(&slots, 1)
+					return slotsResp
+				}
+				t.Fatalf("unexpected command %v", cmd.Commands())
+				return RedisResult{}
+			},
+		}
+	})
+	if err != nil {
+		t.Fatalf("unexpected err %v", err)
+	}
+	defer client.Close()
+	if atomic.LoadInt64(&shards) == 0 || atomic.LoadInt64(&slots) == 0 {
+		t.Fatalf("unexpected calls %v %v", shards, slots)
+	}
+	if nodes := client.Nodes(); len(nodes) != 2 || nodes["127.0.0.1:0"] == nil || nodes["127.0.1.1:1"] == nil {
+		t.Fatalf("unexpected nodes %v", nodes)
+	}
+	if client.slots[0] != client.conns["127.0.0.1:0"].conn || client.slots[16383] != client.conns["127.0.0.1:0"].conn {
+		t.Fatalf("unexpected slots")
+	}
+}
+
+func TestClusterClientShardsFailingPrimary(t *testing.T) {
+	defer ShouldNotLeaked(SetupLeakDetection())
+	failing := newResult(RedisMessage{typ: '*', values: []RedisMessage{
+		shardsResp.val.values[0],
+		{typ: '%', values: []RedisMessage{
+			{typ: '+', string: "slots"},
+			{typ: '*', values: []RedisMessage{{typ: ':', integer: 8193}, {typ: ':', integer: 16383}}},
+			{typ: '+', string: "nodes"},
+			{typ: '*', values: []RedisMessage{
+				{typ: '%', values: []RedisMessage{
+					{typ: '+', string: "port"}, {typ: ':', integer: 0},
+					{typ: '+', string: "endpoint"}, {typ: '+', string: "127.0.2.1"},
+					{typ: '+', string: "role"}, {typ: '+', string: "master"},
+					{typ: '+', string: "health"}, {typ: '+', string: "fail"},
+				}},
+			}},
+		}},
+	}}, nil)
+	var refreshed int64
+	client, err := newClusterClient(&ClientOption{InitAddress: []string{"127.0.0.1:0"}}, func(dst string, opt *ClientOption) conn {
+		return &mockConn{
+			InfoFn: func() map[string]RedisMessage {
+				return map[string]RedisMessage{"version": {typ: '+', string: "7.0.0"}}
+			},
+			DoFn: func(cmd Completed) RedisResult {
+				if atomic.AddInt64(&refreshed, 1) == 1 {
+					return shardsResp
+				}
+				return failing
+			},
+		}
+	})
+	if err != nil {
+		t.Fatalf("unexpected err %v", err)
+	}
+	defer client.Close()
+	prev := client.slots[16383]
+	if prev == nil || prev != client.conns["127.0.2.1:0"].conn {
+		t.Fatalf("unexpected slots")
+	}
+	if err = client.refresh(); err != nil {
+		t.Fatalf("unexpected err %v", err)
+	}
+	if client.slots[8193] != prev || client.slots[16383] != prev || client.rslots[16383] != prev {
+		t.Fatalf("the previous primary of the failing shard should be kept")
+	}
+	if nodes := client.Nodes(); len(nodes) != 3 || nodes["127.0.2.1:0"] == nil {
+		t.Fatalf("unexpected nodes %v", nodes)
+	}
+	if client.slots[0] != client.conns["127.0.0.1:0"].conn {
+		t.Fatalf("unexpected slots")
+	}
+}
+
+func TestClusterClientTLSServerName(t *testing.T) {
+	defer ShouldNotLeaked(SetupLeakDetection())
+	for _, fn := range []func(string) string{nil, func(dst string) string { return "custom-" + dst }} {
+		var mu sync.Mutex
+		names := map[string]string{}
+		client, err := newClusterClient(&ClientOption{
+			InitAddress:     []string{"127.0.0.1:0"},
+			TLSConfig:       &tls.Config{},
+			TLSServerNameFn: fn,
+		}, func(dst string, opt *ClientOption) conn {
+			mu.Lock()
+			names[dst] = tlsOption(dst, opt).TLSConfig.ServerName
+			mu.Unlock()
+			return &mockConn{
+				InfoFn: func() map[string]RedisMessage {
+					return map[string]RedisMessage{"version": {typ: '+', string: "7.0.0"}}
+				},
+				DoFn: func(cmd Completed) RedisResult {
+					return shardsResp
+				},
+			}
+		})
+		if err != nil {
+			t.Fatalf("unexpected err %v", err)
+		}
+		client.Close()
+		mu.Lock()
+		if fn == nil {
+			if names["127.0.0.1:0"] != "" || names["127.0.1.1:1"] != "node1" || names["127.0.2.1:0"] != "node2" {
+				t.Fatalf("unexpected server names %v", names)
+			}
+		} else {
+			for dst, name := range names {
+				if name != "custom-"+dst {
+					t.Fatalf("unexpected server names %v", names)
+				}
+			}
+		}
+		mu.Unlock()
+	}
+}
+
+func TestClusterClientTLSServerNameRedirect(t *testing.T) {
+	defer ShouldNotLeaked(SetupLeakDetection())
+	var mu sync.Mutex
+	var names []string
+	client, err := newClusterClient(&ClientOption{
+		InitAddress: []string{"127.0.0.1:0"},
+		TLSConfig:   &tls.Config{},
+	}, func(dst string, opt *ClientOption) conn {
+		if dst == "127.0.1.1:1" {
+			mu.Lock()
+			names = append(names, tlsOption(dst, opt).TLSConfig.ServerName)
+			mu.Unlock()
+		}
+		return &mockConn{
+			InfoFn: func() map[string]RedisMessage {
+				return map[string]RedisMessage{"version": {typ: '+', string: "7.0.0"}}
+			},
+			DoFn: func(cmd Completed) RedisResult {
+				return shardsResp
+			},
+		}
+	})
+	if err != nil {
+		t.Fatalf("unexpected err %v", err)
+	}
+	defer client.Close()
+
+	client.mu.Lock()
+	delete(client.conns, "127.0.1.1:1") // pretend the node is first reached by a redirection
+	client.mu.Unlock()
+	redirected := client.redirectOrNew("127.0.1.1:1", nil)
+	if err := client.refresh(); err != nil {
+		t.Fatalf("unexpected err %v", err)
+	}
+	client.mu.RLock()
+	cc := client.conns["127.0.1.1:1"]
+	client.mu.RUnlock()
+	if cc.conn == redirected || cc.host != "node1" {
+		t.Fatalf("the redirected conn should be replaced %v", cc)
+	}
+	mu.Lock()
+	if !reflect.DeepEqual(names, []string{"node1", "", "node1"}) {
+		t.Fatalf("unexpected server names %v", names)
+	}
+	mu.Unlock()
+
+	if p := client.redirectOrNew("127.0.1.1:1", cc.conn); p == cc.conn {
+		t.Fatalf("unexpected conn %v", p)
+	}
+	mu.Lock()
+	if names[len(names)-1] != "node1" {
+		t.Fatalf("the reconnection should keep the server name %v", names)
+	}
+	mu.Unlock()
+}
+
+func TestParseSlotsHostname(t *testing.T) {
+	node := func(ip, hostname string) RedisMessage {
+		return RedisMessage{typ: '*', values: []RedisMessage{
+			{typ: '+', string: ip},
+			{typ: ':', integer: 0},
+			{typ: '+', string: ""},
+			{typ: '%', values: []RedisMessage{{typ: '+', string: "hostname"}, {typ: '+', string: hostname}}},
+		}}
+	}
+	groups := parseSlots(RedisMessage{typ: '*', values: []RedisMessage{
+		{typ: '*', values: []RedisMessage{
+			{typ: ':', integer: 0},
+			{typ: ':', integer: 16383},
+			node("127.0.0.1", "node0"),
+			node("127.0.1.1", "node1"),
+		}},
+	}}, "")
+	if g := groups["127.0.0.1:0"]; !reflect.DeepEqual(g.hosts, []string{"node0", "node1"}) {
+		t.Fatalf("unexpected group %v", g)
+	}
+}
+
+func TestParseShards(t *testing.T) {
+	shard := func(endpoint string, port, tlsPort int64, role, health string) RedisMessage {
+		return RedisMessage{typ: '%', values: []RedisMessage{
+			{typ: '+', string: "port"}, {typ: ':', integer: port},
+			{typ: '+', string: "tls-port"}, {typ: ':', integer: tlsPort},
+			{typ: '+', string: "endpoint"}, {typ: '+', string: endpoint},
+			{typ: '+', string: "role"}, {typ: '+', string: role},
+			{typ: '+', string: "health"}, {typ: '+', string: health},
+		}}
+	}
+	shards := RedisMessage{typ: '*', values: []RedisMessage{
+		{typ: '%', values: []RedisMessage{
+			{typ: '+', string: "slots"},
+			{typ: '*', values: []RedisMessage{{typ: ':', integer: 0}, {typ: ':', integer: 1}, {typ: ':', integer: 3}, {typ: ':', integer: 4}}},
+			{typ: '+', string: "nodes"},
+			{typ: '*', values: []RedisMessage{
+				shard("", 1, 2, "master", "online"),
+				shard("?", 1, 2, "replica", "online"),
+				shard("h1", 1, 2, "replica", "loading"),
+			}},
+		}},
+		{typ: '%', values: []RedisMessage{
+			{typ: '+', string: "slots"},
+			{typ: '*', values: []RedisMessage{{typ: ':', integer: 5}, {typ: ':', integer: 6}}},
+			{typ: '+', string: "nodes"},
+			{typ: '*', values: []RedisMessage{
+				shard("h2", 1, 2, "master", "fail"),
+				shard("h3", 1, 2, "replica", "online"),
+			}},
+		}},
+	}}
+	groups, missing := parseShards(shards, "default:0", true)
+	if len(groups) != 1 {
+		t.Fatalf("unexpected groups %v", groups)
+	}
+	if !reflect.DeepEqual(missing, [][2]int64{{5, 6}}) {
+		t.Fatalf("unexpected missing slots %v", missing)
+	}
+	if g := groups["default:0"]; !reflect.DeepEqual(g.nodes, []string{"default:0"}) || !reflect.DeepEqual(g.slots, [][2]int64{{0, 1}, {3, 4}}) {
+		t.Fatalf("unexpected group %v", g)
+	}
+	groups, missing = parseShards(RedisMessage{typ: '*', values: []RedisMessage{{typ: '%', values: []RedisMessage{
+		{typ: '+', string: "slots"},
+		{typ: '*', values: []RedisMessage{{typ: ':', integer: 0}, {typ: ':', integer: 1}}},
+		{typ: '+', string: "nodes"},
+		{typ: '*', values: []RedisMessage{shard("h3", 1, 2, "replica", "online"), shard("h2", 1, 2, "master", "online")}},
+	}}}}, "", true)
+	if g := groups["h2:2"]; !reflect.DeepEqual(g.nodes, []string{"h2:2", "h3:2"}) || missing != nil {
+		t.Fatalf("unexpected group %v %v", g, missing)
+	}
+}
+
+func TestClusterClientAddressMapper(t *testing.T) {
+	defer ShouldNotLeaked(SetupLeakDetection())
+	var mu sync.Mutex
+	dials := map[string]int{}
+	client, err := newClusterClient(&ClientOption{
+		InitAddress: []string{"init:0"},
+		ClusterAddressMapper: func(announced string) string {
+			return "mapped-" + announced
+		},
+	}, func(dst string, opt *ClientOption) conn {
+		mu.Lock()
+		dials[dst]++
+		mu.Unlock()
+		return &mockConn{
+			AddrFn: func() string { return dst },
+			DoFn: func(cmd Completed) RedisResult {
+				if strings.Join(cmd.Commands(), " ") == "CLUSTER SLOTS" {
+					return slotsResp
+				}
+				if dst == "mapped-127.0.0.1:0" {
+					return newResult(RedisMessage{typ: '-', string: "MOVED 0 10.0.0.1:1"}, nil)
+				}
+				return newResult(RedisMessage{typ: '+', string: dst}, nil)
+			},
+		}
+	})
+	if err != nil {
+		t.Fatalf("unexpected err %v", err)
+	}
+	defer client.Close()
+	nodes := client.Nodes()
+	if len(nodes) != 3 || nodes["init:0"] == nil || nodes["mapped-127.0.0.1:0"] == nil || nodes["mapped-127.0.1.1:1"] == nil {
+		t.Fatalf("unexpected nodes %v", nodes)
+	}
+	if v, err := client.Do(context.Background(), client.B().Get().Key("a").Build()).ToString(); err != nil || v != "mapped-10.0.0.1:1" {
+		t.Fatalf("unexpected resp %v %v", v, err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for dst := range dials {
+		if dst != "init:0" && !strings.HasPrefix(dst, "mapped-") {
+			t.Fatalf("unexpected dial to %v", dst)
+		}
+	}
+}
+
+func TestClusterClientBackgroundRefresh(t *testing.T) {
+	defer ShouldNotLeaked(SetupLeakDetection())
+	var refreshed int64
+	client, err := newClusterClient(&ClientOption{InitAddress: []string{"127.0.0.1:0"}, ClusterRefreshInterval: 10 * time.Millisecond}, func(dst string, opt *ClientOption) conn {
+		return &mockConn{DoFn: func(cmd Completed) RedisResult {
+			if atomic.AddInt64(&refreshed, 1) <= 2 {
+				return singleSlotResp
+			}
+			return slotsMultiResp
+		}}
+	})
+	if err != nil {
+		t.Fatalf("unexpected err %v", err)
+	}
+	defer client.Close()
+	for len(client.Nodes()) != 4 {
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestClusterClientRefreshUnchanged(t *testing.T) {
+	defer ShouldNotLeaked(SetupLeakDetection())
+	var dials int64
+	client, err := newClusterClient(&ClientOption{InitAddress: []string{"127.0.0.1:0"}}, func(dst string, opt *ClientOption) conn {
+		atomic.AddInt64(&dials, 1)
+		return &mockConn{DoFn: func(cmd Completed) RedisResult { return slotsMultiResp }}
+	})
+	if err != nil {
+		t.Fatalf("unexpected err %v", err)
+	}
+	defer client.Close()
+	n := atomic.LoadInt64(&dials)
+	client.mu.RLock()
+	slots, conns := client.slots, client.conns
+	client.mu.RUnlock()
+	if err := client.refresh(); err != nil {
+		t.Fatalf("unexpected err %v", err)
+	}
+	client.mu.RLock()
+	defer client.mu.RUnlock()
+	if atomic.LoadInt64(&dials) != n || slots != client.slots || reflect.ValueOf(conns).Pointer() != reflect.ValueOf(client.conns).Pointer() {
+		t.Fatalf("unchanged topology should not replace slots and conns")
+	}
+}
+
+func TestClusterClientReadFromReplicas(t *testing.T) {
+	defer ShouldNotLeaked(SetupLeakDetection())
+	var mu sync.Mutex
+	replicaOnly := map[string]bool{}
+	client, err := newClusterClient(&ClientOption{InitAddress: []string{"127.0.0.1:0"}, ReadFromReplicas: true}, func(dst string, opt *ClientOption) conn {
+		mu.Lock()
+		replicaOnly[dst] = opt.ReplicaOnly
+		mu.Unlock()
+		return &mockConn{
+			DoFn: func(cmd Completed) RedisResult {
+				if strings.Join(cmd.Commands(), " ") == "CLUSTER SLOTS" {
+					return slotsResp
+				}
+				return newResult(RedisMessage{typ: '+', string: dst}, nil)
+			},
+			DoCacheFn: func(cmd Cacheable, ttl time.Duration) RedisResult {
+				return newResult(RedisMessage{typ: '+', string: dst}, nil)
+			},
+			DoMultiFn: func(multi ...Completed) *redisresults {
+				resps := make([]RedisResult, len(multi))
+				for i := range multi {
+					resps[i] = newResult(RedisMessage{typ: '+', string: dst}, nil)
+				}
+				return &redisresults{s: resps}
+			},
+			DoMultiCacheFn: func(multi ...CacheableTTL) *redisresults {
+				resps := make([]RedisResult, len(multi))
+				for i := range multi {
+					resps[i] = newResult(RedisMessage{typ: '+', string: dst}, nil)
+				}
+				return &redisresults{s: resps}
+			},
+		}
+	})
+	if err != nil {
+		t.Fatalf("unexpected err %v", err)
+	}
+	defer client.Close()
+
+	mu.Lock()
+	if replicaOnly["127.0.0.1:0"] || !replicaOnly["127.0.1.1:1"] {
+		t.Fatalf("unexpected replica options %v", replicaOnly)
+	}
+	mu.Unlock()
+
+	t.Run("Do read-only to replica", func(t *testing.T) {
+		if v, err := client.Do(context.Background(), client.B().Get().Key("k").Build()).ToString(); err != nil || v != "127.0.1.1:1" {
+			t.Fatalf("unexpected response %v %v", v, err)
+		}
+	})
+
+	t.Run("Do write to primary", func(t *testing.T) {
+		if v, err := client.Do(context.Background(), client.B().Set().Key("k").Value("v").Build()).ToString(); err != nil || v != "127.0.0.1:0" {
+			t.Fatalf("unexpected response %v %v", v, err)
+		}
+	})
+
+	t.Run("DoMulti read-only to replica", func(t *testing.T) {
+		for _, resp := range client.DoMulti(context.Background(), client.B().Get().Key("k1{a}").Build(), client.B().Get().Key("k2{a}").Build()) {
+			if v, err := resp.ToString(); err != nil || v != "127.0.1.1:1" {
+				t.Fatalf("unexpected response %v %v", v, err)
+			}
+		}
+	})
+
+	t.Run("DoMulti mixed", func(t *testing.T) {
+		resps := client.DoMulti(context.Background(), client.B().Get().Key("k1").Build(), client.B().Set().Key("k2").Value("v").Build())
+		if v, err := resps[0].ToString(); err != nil || v != "127.0.1.1:1" {
+			t.Fatalf("unexpected response %v %v", v, err)
+		}
+		if v, err := resps[1].ToString(); err != nil || v != "127.0.0.1:0" {
+			t.Fatalf("unexpected response %v %v", v, err)
+		}
+	})
+
+	t.Run("DoCache to replica", func(t *testing.T) {
+		if v, err := client.DoCache(context.Background(), client.B().Get().Key("k").Cache(), time.Second).ToString(); err != nil || v != "127.0.1.1:1" {
+			t.Fatalf("unexpected response %v %v", v, err)
+		}
+	})
+
+	t.Run("DoMultiCache to replica", func(t *testing.T) {
+		for _, resp := range client.DoMultiCache(context.Background(), CT(client.B().Get().Key("k1").Cache(), time.Second), CT(client.B().Get().Key("k2").Cache(), time.Second)) {
+			if v, err := resp.ToString(); err != nil || v != "127.0.1.1:1" {
+				t.Fatalf("unexpected response %v %v", v, err)
+			}
+		}
+	})
+}
+
+func TestClusterClientReplicaSelector(t *testing.T) {
+	defer ShouldNotLeaked(SetupLeakDetection())
+	var calls int64
+	client, err := newClusterClient(&ClientOption{
+		InitAddress:      []string{"127.0.0.1:0"},
+		ReadFromReplicas: true,
+		ReplicaSelector: func(slot uint16, replicas []string) int {
+			atomic.AddInt64(&calls, 1)
+			if len(replicas) != 1 || replicas[0] != "127.0.1.1:1" {
+				t.Errorf("unexpected replicas %v", replicas)
+			}
+			return -1
+		},
+	}, func(dst string, opt *ClientOption) conn {
+		return &mockConn{
+			DoFn: func(cmd Completed) RedisResult {
+				if strings.Join(cmd.Commands(), " ") == "CLUSTER SLOTS" {
+					return slotsResp
+				}
+				return newResult(RedisMessage{typ: '+', string: dst}, nil)
+			},
+		}
+	})
+	if err != nil {
+		t.Fatalf("unexpected err %v", err)
+	}
+	defer client.Close()
+	if n := atomic.LoadInt64(&calls); n != 16384 {
+		t.Fatalf("unexpected selector calls %v", n)
+	}
+	if v, err := client.Do(context.Background(), client.B().Get().Key("k").Build()).ToString(); err != nil || v != "127.0.0.1:0" {
+		t.Fatalf("unexpected response %v %v", v, err)
+	}
+}
+
+func TestRoundRobinReplicaSelector(t *testing.T) {
+	selector := NewRoundRobinReplicaSelector()
+	replicas := []string{"a", "b", "c"}
+	for i := 0; i < 6; i++ {
+		if v := selector(0, replicas); v != i%3 {
+			t.Fatalf("unexpected selection %v", v)
+		}
+	}
+}
+
+func TestClusterClientRetryPolicy(t *testing.T) {
+	defer ShouldNotLeaked(SetupLeakDetection())
+	var calls int64
+	client, err := newClusterClient(&ClientOption{
+		InitAddress: []string{"127.0.0.1:0"},
+		RetryPolicy: retryPolicyFn(func(n int, err error, cmd Completed) (bool, time.Duration) {
+			return n < 3, time.Millisecond
+		}),
+	}, func(dst string, opt *ClientOption) conn {
+		return &mockConn{
+			DoFn: func(cmd Completed) RedisResult {
+				if strings.Join(cmd.Commands(), " ") == "CLUSTER SLOTS" {
+					return slotsMultiResp
+				}
+				atomic.AddInt64(&calls, 1)
+				return newErrResult(ErrClosing)
+			},
+			DoMultiFn: func(multi ...Completed) *redisresults {
+				resps := make([]RedisResult, len(multi))
+				for i := range resps {
+					atomic.AddInt64(&calls, 1)
+					resps[i] = newErrResult(ErrClosing)
+				}
+				return &redisresults{s: resps}
+			},
+		}
+	})
+	if err != nil {
+		t.Fatalf("unexpected err %v", err)
+	}
+	defer client.Close()
+	if err := client.Do(context.Background(), client.B().Get().Key("a").Build()).Error(); err != ErrClosing || atomic.LoadInt64(&calls) != 3 {
+		t.Fatalf("unexpected %v %v", err, atomic.LoadInt64(&calls))
+	}
+	atomic.StoreInt64(&calls, 0)
+	resps := client.DoMulti(context.Background(), client.B().Get().Key("a").Build(), client.B().Get().Key("b").Build())
+	if resps[0].Error() != ErrClosing || resps[1].Error() != ErrClosing || atomic.LoadInt64(&calls) != 6 {
+		t.Fatalf("unexpected %v %v", resps, atomic.LoadInt64(&calls))
+	}
+}
+
+func TestClusterClientStreamRedirect(t *testing.T) {
+	defer ShouldNotLeaked(SetupLeakDetection())
+	moved := RedisMessage{typ: '-', string: "MOVED 0 127.0.3.1:0"}
+	streamMux := func(reply RedisMessage) *mux {
+		n1, n2 := net.Pipe()
+		m := makeMux("", &ClientOption{DisableCache: true}, func(dst string, opt *ClientOption) (net.Conn, error) {
+			return n1, nil
+		})
+		go func() {
+			mock := &redisMock{t: t, buf: bufio.NewReader(n2), conn: n2}
+			mock.Expect("HELLO", "3").Reply(RedisMessage{typ: '%', values: []RedisMessage{{typ: '+', string: "proto"}, {typ: ':', integer: 3}}})
+			mock.Expect("GET", "a").Reply(reply)
+			mock.Expect("QUIT").ReplyString("OK")
+			mock.Close()
+		}()
+		return m
+	}
+	var mu sync.Mutex
+	var muxes []*mux
+	var iters []string
+	client, err := newClusterClient(&ClientOption{InitAddress: []string{"127.0.0.1:0"}}, func(dst string, opt *ClientOption) conn {
+		return &mockConn{
+			DoFn: func(cmd Completed) RedisResult {
+				return slotsResp
+			},
+			DoStreamFn: func(cmd Completed) RedisResultStream {
+				reply := RedisMessage{typ: '$', string: "Hello"}
+				if dst != "127.0.3.1:0" {
+					reply = moved
+				}
+				m := streamMux(reply)
+				mu.Lock()
+				muxes = append(muxes, m)
+				mu.Unlock()
+				return m.DoStream(context.Background(), cmd)
+			},
+			DoIterFn: func(cmd Completed, fn func(elem RedisMessage) error) error {
+				mu.Lock()
+				iters = append(iters, dst)
+				mu.Unlock()
+				if dst != "127.0.3.1:0" {
+					return moved.Error()
+				}
+				return fn(RedisMessage{typ: '+', string: "Hello"})
+			},
+		}
+	})
+	if err != nil {
+		t.Fatalf("unexpected err %v", err)
+	}
+	defer client.Close()
+
+	s := client.DoStream(context.Background(), client.B().Get().Key("a").Build())
+	buf := bytes.NewBuffer(nil)
+	if n, err := s.WriteTo(buf); err != nil || n != 5 || buf.String() != "Hello" {
+		t.Fatalf("unexpected WriteTo %v %v %v", n, err, buf.String())
+	}
+	if _, err := s.WriteTo(buf); err != io.EOF {
+		t.Fatalf("unexpected err %v", err)
+	}
+	mu.Lock()
+	if len(muxes) != 2 {
+		t.Fatalf("unexpected streams %v", len(muxes))
+	}
+	for _, m := range muxes {
+		m.Close()
+	}
+	mu.Unlock()
+
+	var elems []string
+	if err := client.DoIter(context.Background(), client.B().Get().Key("a").Build(), func(elem RedisMessage) error {
+		v, _ := elem.ToString()
+		elems = append(elems, v)
+		return nil
+	}); err != nil || !reflect.DeepEqual(elems, []string{"Hello"}) {
+		t.Fatalf("unexpected DoIter %v %v", err, elems)
+	}
+	mu.Lock()
+	if !reflect.DeepEqual(iters, []string{"127.0.0.1:0", "127.0.3.1:0"}) {
+		t.Fatalf("unexpected iters %v", iters)
+	}
+	mu.Unlock()
+}
+
+func TestClusterClientNotRetried(t *testing.T) {
+	defer ShouldNotLeaked(SetupLeakDetection())
+	for _, e := range []error{context.DeadlineExceeded, ErrCircuitOpen} {
+		var calls int64
+		client, err := newClusterClient(&ClientOption{InitAddress: []string{"127.0.0.1:0"}}, func(dst string, opt *ClientOption) conn {
+			return &mockConn{
+				DoFn: func(cmd Completed) RedisResult {
+					if strings.Join(cmd.Commands(), " ") == "CLUSTER SLOTS" {
+						return slotsMultiResp
+					}
+					atomic.AddInt64(&calls, 1)
+					return newErrResult(e)
+				},
+			}
+		})
+		if err != nil {
+			t.Fatalf("unexpected err %v", err)
+		}
+		if err := client.Do(context.Background(), client.B().Get().Key("a").Build()).Error(); err != e || atomic.LoadInt64(&calls) != 1 {
+			t.Fatalf("unexpected %v %v", err, atomic.LoadInt64(&calls))
+		}
+		client.Close()
+	}
+}
+
 func TestClusterClientRetry(t *testing.T) {
 	defer ShouldNotLeaked(SetupLeakDetection())
 	SetupClientRetry(t, func(m *mockConn) Client {
@@ -3,6 +3,7 @@ package cmds
 import (
 	"strings"
 	"sync"
+	"time"
 )
 
 const ErrBuiltTwice = "a command should not be built twice"
@@ -15,6 +16,7 @@ var pool = &sync.Pool{New: func() any {
 // CommandSlice is the command container managed by the sync.Pool
 type CommandSlice struct {
 	s []string
+	t time.Duration
 	l int32
 	r int32
 }
@@ -54,6 +56,7 @@ func Put(cs *CommandSlice) {
 	cs.s = cs.s[:0]
 	cs.l = -1
 	cs.r = 0
+	cs.t = 0
 	pool.Put(cs)
 }
 
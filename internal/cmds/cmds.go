@@ -1,6 +1,10 @@
 package cmds
 
-import "strings"
+import (
+	"strconv"
+	"strings"
+	"time"
+)
 
 const (
 	optInTag = uint16(1 << 15)
@@ -9,6 +13,8 @@ const (
 	noRetTag = uint16(1<<12) | readonly // make noRetTag can also be retried
 	mtGetTag = uint16(1<<11) | readonly // make mtGetTag can also be retried
 	scrRoTag = uint16(1<<10) | readonly // make scrRoTag can also be retried
+	retryTag = uint16(1 << 9)
+	prioTag  = uint16(1 << 8)
 	// InitSlot indicates that the command be sent to any redis node in cluster
 	InitSlot = uint16(1 << 14)
 	// NoSlot indicates that the command has no key slot specified
@@ -60,6 +66,10 @@ var (
 	SlotCmd = Completed{
 		cs: newCommandSlice([]string{"CLUSTER", "SLOTS"}),
 	}
+	// ShardsCmd is predefined CLUSTER SHARDS
+	ShardsCmd = Completed{
+		cs: newCommandSlice([]string{"CLUSTER", "SHARDS"}),
+	}
 	// AskingCmd is predefined CLUSTER ASKING
 	AskingCmd = Completed{
 		cs: newCommandSlice([]string{"ASKING"}),
@@ -94,6 +104,31 @@ func (c Completed) Pin() Completed {
 	return c
 }
 
+// Idempotent marks the command as safe to be retried when network error, even if it is not readonly.
+// It should only be used on commands that have the same effect when executed more than once, such as SET without GET, DEL and EXPIRE.
+func (c Completed) Idempotent() Completed {
+	c.cf |= retryTag
+	return c
+}
+
+// HighPriority marks the command to be sent through the priority lane, if the ClientOption.PriorityLane is enabled.
+func (c Completed) HighPriority() Completed {
+	c.cf |= prioTag
+	return c
+}
+
+// WithTimeout sets the timeout of the command, which takes precedence over the ClientOption.DefaultCommandTimeout.
+// Like Pin, it applies to all the copies of the command.
+func (c Completed) WithTimeout(d time.Duration) Completed {
+	c.cs.t = d
+	return c
+}
+
+// Timeout returns the timeout set by WithTimeout.
+func (c *Completed) Timeout() time.Duration {
+	return c.cs.t
+}
+
 // IsEmpty checks if it is an empty command.
 func (c *Completed) IsEmpty() bool {
 	return c.cs == nil || len(c.cs.s) == 0
@@ -119,6 +154,16 @@ func (c *Completed) IsReadOnly() bool {
 	return c.cf&readonly == readonly
 }
 
+// IsIdempotent checks if it is marked by Idempotent and can be retried when network error.
+func (c *Completed) IsIdempotent() bool {
+	return c.cf&retryTag == retryTag
+}
+
+// IsHighPriority checks if it is marked by HighPriority.
+func (c *Completed) IsHighPriority() bool {
+	return c.cf&prioTag == prioTag
+}
+
 // IsWrite checks if it is not readonly command.
 func (c *Completed) IsWrite() bool {
 	return !c.IsReadOnly()
@@ -146,6 +191,18 @@ func (c Cacheable) Pin() Cacheable {
 	return c
 }
 
+// WithTimeout sets the timeout of the command, which takes precedence over the ClientOption.DefaultCommandTimeout.
+// Like Pin, it applies to all the copies of the command.
+func (c Cacheable) WithTimeout(d time.Duration) Cacheable {
+	c.cs.t = d
+	return c
+}
+
+// Timeout returns the timeout set by WithTimeout.
+func (c *Cacheable) Timeout() time.Duration {
+	return c.cs.t
+}
+
 // Slot returns the command key slot
 func (c *Cacheable) Slot() uint16 {
 	return c.ks
@@ -210,6 +267,41 @@ func CacheKey(c Cacheable) (key, command string) {
 	return key, sb.String()
 }
 
+// BlockTimeout returns the server side timeout of the blocking command, where zero means blocking indefinitely.
+// It returns -1 if the command has no known server side timeout.
+func BlockTimeout(c Completed) time.Duration {
+	s := c.cs.s
+	if len(s) < 2 {
+		return -1
+	}
+	switch strings.ToUpper(s[0]) {
+	case "BLPOP", "BRPOP", "BRPOPLPUSH", "BLMOVE", "BZPOPMIN", "BZPOPMAX":
+		return parseTimeout(s[len(s)-1], time.Second)
+	case "BLMPOP", "BZMPOP":
+		return parseTimeout(s[1], time.Second)
+	case "WAIT", "WAITAOF":
+		return parseTimeout(s[len(s)-1], time.Millisecond)
+	case "XREAD", "XREADGROUP":
+		for i := 1; i < len(s)-1; i++ {
+			switch strings.ToUpper(s[i]) {
+			case "BLOCK":
+				return parseTimeout(s[i+1], time.Millisecond)
+			case "STREAMS":
+				return -1
+			}
+		}
+	}
+	return -1
+}
+
+func parseTimeout(s string, unit time.Duration) time.Duration {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f < 0 {
+		return -1
+	}
+	return time.Duration(f * float64(unit))
+}
+
 // CompletedCS get the underlying *CommandSlice
 func CompletedCS(c Completed) *CommandSlice {
 	return c.cs
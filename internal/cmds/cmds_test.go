@@ -4,6 +4,7 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestCacheable_CacheKey(t *testing.T) {
@@ -126,6 +127,83 @@ func TestComplete_IsReadOnly(t *testing.T) {
 	}
 }
 
+func TestCompleted_Idempotent(t *testing.T) {
+	cmd := NewCompleted([]string{"a", "b"})
+	if cmd.IsIdempotent() {
+		t.Fatalf("should not be idempotent command")
+	}
+	if idem := cmd.Idempotent(); !idem.IsIdempotent() || !idem.IsWrite() || cmd.IsIdempotent() {
+		t.Fatalf("should be idempotent write command")
+	}
+	if cmd := NewBlockingCompleted([]string{"a", "b"}).Idempotent(); !cmd.IsIdempotent() || !cmd.IsBlock() {
+		t.Fatalf("should keep other tags")
+	}
+}
+
+func TestCompleted_HighPriority(t *testing.T) {
+	cmd := NewCompleted([]string{"a", "b"})
+	if cmd.IsHighPriority() {
+		t.Fatalf("should not be high priority command")
+	}
+	if prio := cmd.HighPriority(); !prio.IsHighPriority() || cmd.IsHighPriority() {
+		t.Fatalf("should be high priority command")
+	}
+	if cmd := NewReadOnlyCompleted([]string{"a", "b"}).HighPriority(); !cmd.IsHighPriority() || !cmd.IsReadOnly() || cmd.IsIdempotent() {
+		t.Fatalf("should keep other tags")
+	}
+}
+
+func TestCompleted_WithTimeout(t *testing.T) {
+	cmd := NewCompleted([]string{"a", "b"})
+	if cmd.Timeout() != 0 {
+		t.Fatalf("should not have timeout")
+	}
+	if cmd = cmd.WithTimeout(time.Second); cmd.Timeout() != time.Second {
+		t.Fatalf("unexpected timeout %v", cmd.Timeout())
+	}
+	c := Cacheable(NewCompleted([]string{"a", "b"}))
+	if c.Timeout() != 0 {
+		t.Fatalf("should not have timeout")
+	}
+	if c = c.WithTimeout(time.Second); c.Timeout() != time.Second {
+		t.Fatalf("unexpected timeout %v", c.Timeout())
+	}
+	cs := get()
+	cs.t = time.Second
+	Put(cs)
+	if cs.t != 0 {
+		t.Fatalf("timeout should be reset")
+	}
+}
+
+func TestBlockTimeout(t *testing.T) {
+	b := NewBuilder(NoSlot)
+	for _, c := range []struct {
+		cmd Completed
+		exp time.Duration
+	}{
+		{cmd: b.Blpop().Key("a", "b").Timeout(1.5).Build(), exp: 1500 * time.Millisecond},
+		{cmd: b.Brpop().Key("a").Timeout(0).Build(), exp: 0},
+		{cmd: b.Brpoplpush().Source("a").Destination("b").Timeout(2).Build(), exp: 2 * time.Second},
+		{cmd: b.Blmove().Source("a").Destination("b").Left().Right().Timeout(1).Build(), exp: time.Second},
+		{cmd: b.Bzpopmin().Key("a").Timeout(1).Build(), exp: time.Second},
+		{cmd: b.Blmpop().Timeout(3).Numkeys(1).Key("a").Left().Build(), exp: 3 * time.Second},
+		{cmd: b.Bzmpop().Timeout(3).Numkeys(1).Key("a").Min().Build(), exp: 3 * time.Second},
+		{cmd: b.Wait().Numreplicas(1).Timeout(100).Build(), exp: 100 * time.Millisecond},
+		{cmd: b.Xread().Count(1).Block(200).Streams().Key("a").Id("0").Build(), exp: 200 * time.Millisecond},
+		{cmd: b.Xreadgroup().Group("g", "c").Block(300).Streams().Key("BLOCK").Id(">").Build(), exp: 300 * time.Millisecond},
+		{cmd: NewBlockingCompleted([]string{"XREAD", "STREAMS", "BLOCK", "1"}), exp: -1},
+		{cmd: NewBlockingCompleted([]string{"blpop", "a", "x"}), exp: -1},
+		{cmd: NewBlockingCompleted([]string{"blpop", "a", "-1"}), exp: -1},
+		{cmd: b.ClientPause().Timeout(1).Build(), exp: -1},
+		{cmd: NewCompleted([]string{"GET"}), exp: -1},
+	} {
+		if to := BlockTimeout(c.cmd); to != c.exp {
+			t.Fatalf("unexpected timeout %v of %v", to, c.cmd.Commands())
+		}
+	}
+}
+
 func TestNewMultiCompleted(t *testing.T) {
 	multi := NewMultiCompleted([][]string{{"a", "b"}, {"c", "d"}})
 	if strings.Join(multi[0].Commands(), " ") != "a b" {
@@ -60,6 +60,7 @@ type lru struct {
 	mu    sync.RWMutex
 	size  int
 	max   int
+	evict uint64
 }
 
 func newLRU(opt CacheStoreOption) CacheStore {
@@ -248,6 +249,7 @@ func (c *lru) Update(key, cmd string, value RedisMessage) (pxat int64) {
 					}
 					c.list.Remove(ele)
 					c.size -= e.size
+					c.evict++
 				}
 				ele = ele.Next()
 			}
@@ -293,6 +295,13 @@ func (c *lru) GetTTL(key, cmd string) (ttl time.Duration) {
 	return
 }
 
+func (c *lru) stats() (size int64, evictions uint64) {
+	c.mu.RLock()
+	size, evictions = int64(c.size), c.evict
+	c.mu.RUnlock()
+	return
+}
+
 func (c *lru) purge(key string, kc *keyCache) {
 	if kc != nil {
 		for cmd, ele := range kc.cache {
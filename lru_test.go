@@ -103,6 +103,9 @@ func TestLRU(t *testing.T) {
 		if v, entry := lru.Flight("1", "GET", TTL, time.Now()); v.typ != 0 {
 			t.Fatalf("got evicted value from the first Flight: %v %v", v, entry)
 		}
+		if size, evictions := lru.stats(); size <= 0 || size > int64(lru.max) || evictions == 0 {
+			t.Fatalf("got unexpected stats: %v %v", size, evictions)
+		}
 		if v, _ := lru.Flight(strconv.Itoa(Entries), "GET", TTL, time.Now()); v.typ == 0 {
 			t.Fatalf("did not get the latest value from the Flight")
 		} else if v.string != strconv.Itoa(Entries) {
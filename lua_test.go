@@ -245,6 +245,14 @@ func (c *client) DoCache(ctx context.Context, cmd Cacheable, ttl time.Duration)
 	return RedisResult{}
 }
 
+func (c *client) DoStream(ctx context.Context, cmd Completed) (resp RedisResultStream) {
+	return RedisResultStream{}
+}
+
+func (c *client) DoIter(ctx context.Context, cmd Completed, fn func(elem RedisMessage) error) (err error) {
+	return nil
+}
+
 func (c *client) Dedicated(fn func(DedicatedClient) error) (err error) {
 	if c.DedicatedFn != nil {
 		return c.DedicatedFn(fn)
@@ -259,16 +267,34 @@ func (c *client) Dedicate() (DedicatedClient, func()) {
 	return nil, nil
 }
 
+func (c *client) DedicatedCtx(ctx context.Context, fn func(DedicatedClient) error) (err error) {
+	return c.Dedicated(fn)
+}
+
+func (c *client) DedicateCtx(ctx context.Context) (DedicatedClient, func(), error) {
+	client, cancel := c.Dedicate()
+	return client, cancel, nil
+}
+
 func (c *client) Nodes() map[string]Client {
 	return map[string]Client{"addr": c}
 }
 
+func (c *client) Stats() ClientStats {
+	return ClientStats{}
+}
+
 func (c *client) Close() {
 	if c.CloseFn != nil {
 		c.CloseFn()
 	}
 }
 
+func (c *client) CloseWithContext(ctx context.Context) error {
+	c.Close()
+	return nil
+}
+
 func ExampleLua_exec() {
 	client, err := NewClient(ClientOption{InitAddress: []string{"127.0.0.1:6379"}})
 	if err != nil {
@@ -1,11 +1,16 @@
 package rueidis
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math/big"
+	"os"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 	"unsafe"
 
@@ -175,6 +180,16 @@ func (r RedisResult) AsBytes() (v []byte, err error) {
 	return
 }
 
+// AsVerbatim delegates to RedisMessage.AsVerbatim
+func (r RedisResult) AsVerbatim() (format, text string, err error) {
+	if r.err != nil {
+		err = r.err
+	} else {
+		format, text, err = r.val.AsVerbatim()
+	}
+	return
+}
+
 // DecodeJSON delegates to RedisMessage.DecodeJSON
 func (r RedisResult) DecodeJSON(v any) (err error) {
 	if r.err != nil {
@@ -195,6 +210,16 @@ func (r RedisResult) AsInt64() (v int64, err error) {
 	return
 }
 
+// AsBigInt delegates to RedisMessage.AsBigInt
+func (r RedisResult) AsBigInt() (v *big.Int, err error) {
+	if r.err != nil {
+		err = r.err
+	} else {
+		v, err = r.val.AsBigInt()
+	}
+	return
+}
+
 // AsUint64 delegates to RedisMessage.AsUint64
 func (r RedisResult) AsUint64() (v uint64, err error) {
 	if r.err != nil {
@@ -245,6 +270,16 @@ func (r RedisResult) AsStrSlice() (v []string, err error) {
 	return
 }
 
+// AsStrSet delegates to RedisMessage.AsStrSet
+func (r RedisResult) AsStrSet() (v map[string]struct{}, err error) {
+	if r.err != nil {
+		err = r.err
+	} else {
+		v, err = r.val.AsStrSet()
+	}
+	return
+}
+
 // AsIntSlice delegates to RedisMessage.AsIntSlice
 func (r RedisResult) AsIntSlice() (v []int64, err error) {
 	if r.err != nil {
@@ -416,6 +451,11 @@ func (r RedisResult) IsCacheHit() bool {
 	return r.val.IsCacheHit()
 }
 
+// Attributes delegates to RedisMessage.Attributes
+func (r RedisResult) Attributes() map[string]RedisMessage {
+	return r.val.Attributes()
+}
+
 // CacheTTL delegates to RedisMessage.CacheTTL
 func (r RedisResult) CacheTTL() int64 {
 	return r.val.CacheTTL()
@@ -431,6 +471,103 @@ func (r RedisResult) CachePXAT() int64 {
 	return r.val.CachePXAT()
 }
 
+// RedisResultStream is the return struct from Client.DoStream.
+// It holds a dedicated connection until its response is fully written by WriteTo.
+type RedisResultStream struct {
+	p    *pool
+	w    *pipe
+	e    error
+	n    int
+	redo func(err error) (RedisResultStream, bool) // sends the command again if the err is a redirection, ex. MOVED
+	hook func(err error)
+}
+
+// HasNext can be used in a for loop condition to check if a further WriteTo call is needed.
+func (s *RedisResultStream) HasNext() bool {
+	return s.n > 0 && s.e == nil
+}
+
+// Error returns the error happened when sending the command to redis or reading the response from redis.
+// Usually a user is not required to use this function because the error is also reported by WriteTo.
+func (s *RedisResultStream) Error() error {
+	return s.e
+}
+
+// OnComplete sets the fn to be called once, with the error if any, when the response is fully read by WriteTo
+// or fails to be read. The fn is called immediately if the stream has already failed or finished.
+// It is useful for wrappers of Client.DoStream, such as tracing, to observe the whole response.
+func (s *RedisResultStream) OnComplete(fn func(err error)) {
+	if s.e == io.EOF {
+		fn(nil)
+	} else if s.e != nil || s.n == 0 {
+		fn(s.e)
+	} else {
+		s.hook = fn
+	}
+}
+
+// WriteTo reads the redis response from the connection and copies it to the given writer.
+// Blob string payloads are copied directly from the connection buffer without being loaded into memory first.
+// The dedicated connection is released once the response is fully read, and an io.EOF is reported afterward.
+// If the writer or the connection fails in the middle of a response, the connection is closed instead of being reused.
+// A cluster client follows a MOVED redirection or retries the command here if nothing has been written to the writer yet.
+// This function is not thread safe.
+func (s *RedisResultStream) WriteTo(w io.Writer) (n int64, err error) {
+	if err = s.e; err == nil && s.n > 0 {
+		var clean bool
+		n, err, clean = streamTo(s.w.r, s.w.limits, w)
+		err = s.done(err, clean)
+		if n == 0 && err != nil && s.redo != nil {
+			if next, ok := s.redo(err); ok {
+				next.hook = s.hook
+				*s = next
+				return s.WriteTo(w)
+			}
+		}
+	}
+	if s.hook != nil && (s.e != nil || s.n == 0) {
+		hook := s.hook
+		s.hook = nil
+		hook(err)
+	}
+	return n, err
+}
+
+func (s *RedisResultStream) iter(fn func(elem RedisMessage) error) (err error) {
+	if err = s.e; err == nil && s.n > 0 {
+		var clean bool
+		err, clean = iterTo(s.w.r, s.w.limits, fn)
+		err = s.done(err, clean)
+	}
+	return err
+}
+
+// done releases the dedicated connection after a response is consumed.
+// The connection is closed instead if the response is not fully read from it.
+func (s *RedisResultStream) done(err error, clean bool) error {
+	if !clean {
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			err = context.DeadlineExceeded
+		}
+		s.e = err // err must not be nil in case of !clean
+		s.n = 1
+		s.w.error.CompareAndSwap(nil, &errs{error: err})
+		s.w.conn.Close()
+		s.w.background() // start the background worker to clean up goroutines
+	}
+	if s.n--; s.n == 0 {
+		s.w.conn.SetDeadline(time.Time{})
+		atomic.AddInt32(&s.w.blcksig, -1)
+		atomic.AddInt32(&s.w.pending, -1)
+		atomic.AddInt32(&s.w.waits, -1)
+		if s.e == nil {
+			s.e = io.EOF
+		}
+		s.p.Store(s.w)
+	}
+	return err
+}
+
 // RedisMessage is a redis response message, it may be a nil response
 type RedisMessage struct {
 	attrs   *RedisMessage
@@ -471,6 +608,11 @@ func (m *RedisMessage) IsArray() bool {
 	return m.typ == typeArray || m.typ == typeSet
 }
 
+// IsSet check if message is a redis RESP3 set response
+func (m *RedisMessage) IsSet() bool {
+	return m.typ == typeSet
+}
+
 // IsMap check if message is a redis RESP3 map response
 func (m *RedisMessage) IsMap() bool {
 	return m.typ == typeMap
@@ -520,6 +662,21 @@ func (m *RedisMessage) AsBytes() (bs []byte, err error) {
 	return unsafe.Slice(unsafe.StringData(str), len(str)), nil
 }
 
+// AsVerbatim check if message is a redis RESP3 verbatim string response, and return its format and text separately.
+// A redis string response is also accepted as a "txt" formatted text.
+func (m *RedisMessage) AsVerbatim() (format, text string, err error) {
+	if m.typ == typeVerbatimString {
+		if len(m.string) < 4 || m.string[3] != ':' {
+			return "", "", fmt.Errorf("redis message %q is not a valid verbatim string", m.string)
+		}
+		return m.string[:3], m.string[4:], nil
+	}
+	if text, err = m.ToString(); err != nil {
+		return "", "", err
+	}
+	return "txt", text, nil
+}
+
 // DecodeJSON check if message is a redis string response and treat it as json, then unmarshal it into provided value
 func (m *RedisMessage) DecodeJSON(v any) (err error) {
 	str, err := m.ToString()
@@ -587,6 +744,24 @@ func (m *RedisMessage) AsFloat64() (val float64, err error) {
 	return util.ToFloat64(v)
 }
 
+// AsBigInt check if message is a redis RESP3 big number response, and return it as a *big.Int.
+// A redis int response or a redis string response is also accepted and parsed.
+func (m *RedisMessage) AsBigInt() (val *big.Int, err error) {
+	if m.IsInt64() {
+		return big.NewInt(m.integer), nil
+	}
+	v := m.string
+	if m.typ != typeBigNumber {
+		if v, err = m.ToString(); err != nil {
+			return nil, err
+		}
+	}
+	if val, ok := new(big.Int).SetString(v, 10); ok {
+		return val, nil
+	}
+	return nil, fmt.Errorf("redis message %q is not a valid big number", v)
+}
+
 // ToInt64 check if message is a redis RESP3 int response, and return it
 func (m *RedisMessage) ToInt64() (val int64, err error) {
 	if m.IsInt64() {
@@ -649,6 +824,20 @@ func (m *RedisMessage) AsStrSlice() ([]string, error) {
 	return s, nil
 }
 
+// AsStrSet check if message is a redis array/set response, and convert to map[string]struct{}.
+// redis nil element and other non string element will be present as zero.
+func (m *RedisMessage) AsStrSet() (map[string]struct{}, error) {
+	values, err := m.ToArray()
+	if err != nil {
+		return nil, err
+	}
+	s := make(map[string]struct{}, len(values))
+	for _, v := range values {
+		s[v.string] = struct{}{}
+	}
+	return s, nil
+}
+
 // AsIntSlice check if message is a redis array/set response, and convert to []int64.
 // redis nil element and other non integer element will be present as zero.
 func (m *RedisMessage) AsIntSlice() ([]int64, error) {
@@ -1023,7 +1212,9 @@ func (m *RedisMessage) ToMap() (map[string]RedisMessage, error) {
 	panic(fmt.Sprintf("redis message type %s is not a RESP3 map", typeNames[typ]))
 }
 
-// ToAny turns message into go any value
+// ToAny turns message into go any value.
+// A RESP3 big number and a RESP3 verbatim string are kept as they are received, in string, and a RESP3 set is turned
+// into []any like an array. Use the AsBigInt, AsVerbatim and AsStrSet to get them in their own types instead.
 func (m *RedisMessage) ToAny() (any, error) {
 	if err := m.Error(); err != nil {
 		return nil, err
@@ -1064,7 +1255,30 @@ func (m *RedisMessage) ToAny() (any, error) {
 
 // IsCacheHit check if message is from client side cache
 func (m *RedisMessage) IsCacheHit() bool {
-	return m.attrs == cacheMark
+	return m.attrs == cacheMark || (m.attrs != nil && m.attrs.attrs == cacheMark)
+}
+
+// Attributes returns the RESP3 attributes sent by redis along with the message, or nil if there is none.
+// Attributes are out-of-band metadata, such as key popularity, that do not change the message itself.
+func (m *RedisMessage) Attributes() map[string]RedisMessage {
+	if m.attrs == nil || m.attrs == cacheMark {
+		return nil
+	}
+	values := m.attrs.values
+	attrs := make(map[string]RedisMessage, len(values)/2)
+	for i := 0; i+1 < len(values); i += 2 {
+		attrs[values[i].string] = values[i+1]
+	}
+	return attrs
+}
+
+// markCacheHit marks the copy of message stored in the client side cache, while keeping its attributes.
+func markCacheHit(m *RedisMessage) {
+	if m.attrs == nil {
+		m.attrs = cacheMark
+	} else {
+		m.attrs = &RedisMessage{typ: typeAttribute, values: m.attrs.values, attrs: cacheMark}
+	}
 }
 
 // CacheTTL returns the remaining TTL in seconds of client side cache
@@ -243,6 +243,43 @@ func TestRedisResult(t *testing.T) {
 		}
 	})
 
+	t.Run("AsStrSet", func(t *testing.T) {
+		if _, err := (RedisResult{err: errors.New("other")}).AsStrSet(); err == nil {
+			t.Fatal("AsStrSet not failed as expected")
+		}
+		if _, err := (RedisResult{val: RedisMessage{typ: '-'}}).AsStrSet(); err == nil {
+			t.Fatal("AsStrSet not failed as expected")
+		}
+		values := []RedisMessage{{string: "a", typ: '+'}, {string: "b", typ: '$'}}
+		if ret, _ := (RedisResult{val: RedisMessage{typ: '~', values: values}}).AsStrSet(); !reflect.DeepEqual(ret, map[string]struct{}{"a": {}, "b": {}}) {
+			t.Fatal("AsStrSet not get value as expected")
+		}
+	})
+
+	t.Run("AsBigInt", func(t *testing.T) {
+		if _, err := (RedisResult{err: errors.New("other")}).AsBigInt(); err == nil {
+			t.Fatal("AsBigInt not failed as expected")
+		}
+		if _, err := (RedisResult{val: RedisMessage{typ: '-'}}).AsBigInt(); err == nil {
+			t.Fatal("AsBigInt not failed as expected")
+		}
+		if ret, _ := (RedisResult{val: RedisMessage{typ: '(', string: "3492890328409238509324850943850943825024385"}}).AsBigInt(); ret.String() != "3492890328409238509324850943850943825024385" {
+			t.Fatal("AsBigInt not get value as expected")
+		}
+	})
+
+	t.Run("AsVerbatim", func(t *testing.T) {
+		if _, _, err := (RedisResult{err: errors.New("other")}).AsVerbatim(); err == nil {
+			t.Fatal("AsVerbatim not failed as expected")
+		}
+		if _, _, err := (RedisResult{val: RedisMessage{typ: '-'}}).AsVerbatim(); err == nil {
+			t.Fatal("AsVerbatim not failed as expected")
+		}
+		if f, v, _ := (RedisResult{val: RedisMessage{typ: '=', string: "mkd:Some string"}}).AsVerbatim(); f != "mkd" || v != "Some string" {
+			t.Fatal("AsVerbatim not get value as expected")
+		}
+	})
+
 	t.Run("AsIntSlice", func(t *testing.T) {
 		if _, err := (RedisResult{err: errors.New("other")}).AsIntSlice(); err == nil {
 			t.Fatal("AsIntSlice not failed as expected")
@@ -350,6 +387,9 @@ func TestRedisResult(t *testing.T) {
 			{typ: ',', string: "1.2"},
 			{typ: '+', string: "str"},
 			{typ: '#', integer: 0},
+			{typ: '(', string: "12345678901234567890"},
+			{typ: '=', string: "txt:verbatim"},
+			{typ: '~', values: []RedisMessage{{typ: '+', string: "member"}}},
 			{typ: '-', string: "err"},
 			{typ: '_'},
 		}}}).ToAny(); !reflect.DeepEqual([]any{
@@ -359,6 +399,9 @@ func TestRedisResult(t *testing.T) {
 			1.2,
 			"str",
 			false,
+			"12345678901234567890",
+			"txt:verbatim",
+			[]any{"member"},
 			&RedisError{typ: '-', string: "err"},
 			nil,
 		}, ret) {
@@ -812,6 +855,16 @@ func TestRedisResult(t *testing.T) {
 		}
 	})
 
+	t.Run("Attributes", func(t *testing.T) {
+		if (RedisResult{err: errors.New("other")}).Attributes() != nil {
+			t.Fatal("Attributes not as expected")
+		}
+		attrs := &RedisMessage{typ: '|', values: []RedisMessage{{typ: '+', string: "a"}, {typ: ':', integer: 1}}}
+		if v := (RedisResult{val: RedisMessage{attrs: attrs}}).Attributes(); !reflect.DeepEqual(v, map[string]RedisMessage{"a": {typ: ':', integer: 1}}) {
+			t.Fatalf("Attributes not as expected %v", v)
+		}
+	})
+
 	t.Run("CacheTTL", func(t *testing.T) {
 		if (RedisResult{err: errors.New("other")}).CacheTTL() != -1 {
 			t.Fatal("CacheTTL != -1")
@@ -1036,6 +1089,68 @@ func TestRedisMessage(t *testing.T) {
 		(&RedisMessage{typ: 't'}).AsStrSlice()
 	})
 
+	t.Run("AsStrSet", func(t *testing.T) {
+		if _, err := (&RedisMessage{typ: '_'}).AsStrSet(); err == nil {
+			t.Fatal("AsStrSet not failed as expected")
+		}
+
+		defer func() {
+			if !strings.Contains(recover().(string), "redis message type t is not a array") {
+				t.Fatal("AsStrSet not panic as expected")
+			}
+		}()
+		(&RedisMessage{typ: 't'}).AsStrSet()
+	})
+
+	t.Run("IsSet", func(t *testing.T) {
+		if !(&RedisMessage{typ: '~'}).IsSet() {
+			t.Fatal("IsSet not as expected")
+		}
+		if (&RedisMessage{typ: '*'}).IsSet() {
+			t.Fatal("IsSet not as expected")
+		}
+	})
+
+	t.Run("AsBigInt", func(t *testing.T) {
+		if _, err := (&RedisMessage{typ: '_'}).AsBigInt(); err == nil {
+			t.Fatal("AsBigInt not failed as expected")
+		}
+		if _, err := (&RedisMessage{typ: '(', string: "12x"}).AsBigInt(); err == nil {
+			t.Fatal("AsBigInt not failed as expected")
+		}
+		for _, m := range []RedisMessage{
+			{typ: '(', string: "-12"},
+			{typ: ':', integer: -12},
+			{typ: '$', string: "-12"},
+		} {
+			if v, err := m.AsBigInt(); err != nil || v.Int64() != -12 {
+				t.Fatalf("AsBigInt not get value as expected %v %v", v, err)
+			}
+		}
+
+		defer func() {
+			if !strings.Contains(recover().(string), "redis message type array is not a string") {
+				t.Fatal("AsBigInt not panic as expected")
+			}
+		}()
+		(&RedisMessage{typ: '*', values: []RedisMessage{}}).AsBigInt()
+	})
+
+	t.Run("AsVerbatim", func(t *testing.T) {
+		if _, _, err := (&RedisMessage{typ: '_'}).AsVerbatim(); err == nil {
+			t.Fatal("AsVerbatim not failed as expected")
+		}
+		if _, _, err := (&RedisMessage{typ: '=', string: "txt"}).AsVerbatim(); err == nil {
+			t.Fatal("AsVerbatim not failed as expected")
+		}
+		if f, v, err := (&RedisMessage{typ: '=', string: "txt:"}).AsVerbatim(); err != nil || f != "txt" || v != "" {
+			t.Fatalf("AsVerbatim not get value as expected %v %v %v", f, v, err)
+		}
+		if f, v, err := (&RedisMessage{typ: '$', string: "str"}).AsVerbatim(); err != nil || f != "txt" || v != "str" {
+			t.Fatalf("AsVerbatim not get value as expected %v %v %v", f, v, err)
+		}
+	})
+
 	t.Run("AsIntSlice", func(t *testing.T) {
 		if _, err := (&RedisMessage{typ: '_'}).AsIntSlice(); err == nil {
 			t.Fatal("AsIntSlice not failed as expected")
@@ -1340,6 +1455,34 @@ func TestRedisMessage(t *testing.T) {
 		}
 	})
 
+	t.Run("Attributes", func(t *testing.T) {
+		if (&RedisMessage{typ: '_'}).Attributes() != nil {
+			t.Fatal("Attributes not as expected")
+		}
+		if (&RedisMessage{typ: '_', attrs: cacheMark}).Attributes() != nil {
+			t.Fatal("Attributes not as expected")
+		}
+		m := &RedisMessage{typ: '_', attrs: &RedisMessage{typ: '|', values: []RedisMessage{
+			{typ: '+', string: "key-popularity"},
+			{typ: '%', values: []RedisMessage{{typ: '$', string: "a"}, {typ: ',', string: "0.1923"}}},
+		}}}
+		want := map[string]RedisMessage{"key-popularity": {typ: '%', values: []RedisMessage{{typ: '$', string: "a"}, {typ: ',', string: "0.1923"}}}}
+		if v := m.Attributes(); !reflect.DeepEqual(v, want) {
+			t.Fatalf("Attributes not as expected %v", v)
+		}
+		markCacheHit(m)
+		if !m.IsCacheHit() {
+			t.Fatal("IsCacheHit not as expected")
+		}
+		if v := m.Attributes(); !reflect.DeepEqual(v, want) {
+			t.Fatalf("Attributes not as expected after markCacheHit %v", v)
+		}
+		m = &RedisMessage{typ: '_'}
+		if markCacheHit(m); m.attrs != cacheMark {
+			t.Fatal("markCacheHit not as expected")
+		}
+	})
+
 	t.Run("CacheTTL", func(t *testing.T) {
 		if (&RedisMessage{typ: '_'}).CacheTTL() != -1 {
 			t.Fatal("CacheTTL != -1")
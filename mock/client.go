@@ -72,6 +72,20 @@ func (mr *ClientMockRecorder) Close() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*Client)(nil).Close))
 }
 
+// CloseWithContext mocks base method.
+func (m *Client) CloseWithContext(arg0 context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CloseWithContext", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CloseWithContext indicates an expected call of CloseWithContext.
+func (mr *ClientMockRecorder) CloseWithContext(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CloseWithContext", reflect.TypeOf((*Client)(nil).CloseWithContext), arg0)
+}
+
 // Dedicate mocks base method.
 func (m *Client) Dedicate() (rueidis.DedicatedClient, func()) {
 	m.ctrl.T.Helper()
@@ -87,6 +101,22 @@ func (mr *ClientMockRecorder) Dedicate() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Dedicate", reflect.TypeOf((*Client)(nil).Dedicate))
 }
 
+// DedicateCtx mocks base method.
+func (m *Client) DedicateCtx(arg0 context.Context) (rueidis.DedicatedClient, func(), error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DedicateCtx", arg0)
+	ret0, _ := ret[0].(rueidis.DedicatedClient)
+	ret1, _ := ret[1].(func())
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// DedicateCtx indicates an expected call of DedicateCtx.
+func (mr *ClientMockRecorder) DedicateCtx(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DedicateCtx", reflect.TypeOf((*Client)(nil).DedicateCtx), arg0)
+}
+
 // Dedicated mocks base method.
 func (m *Client) Dedicated(arg0 func(rueidis.DedicatedClient) error) error {
 	m.ctrl.T.Helper()
@@ -101,6 +131,20 @@ func (mr *ClientMockRecorder) Dedicated(arg0 any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Dedicated", reflect.TypeOf((*Client)(nil).Dedicated), arg0)
 }
 
+// DedicatedCtx mocks base method.
+func (m *Client) DedicatedCtx(arg0 context.Context, arg1 func(rueidis.DedicatedClient) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DedicatedCtx", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DedicatedCtx indicates an expected call of DedicatedCtx.
+func (mr *ClientMockRecorder) DedicatedCtx(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DedicatedCtx", reflect.TypeOf((*Client)(nil).DedicatedCtx), arg0, arg1)
+}
+
 // Do mocks base method.
 func (m *Client) Do(arg0 context.Context, arg1 rueidis.Completed) rueidis.RedisResult {
 	m.ctrl.T.Helper()
@@ -129,6 +173,20 @@ func (mr *ClientMockRecorder) DoCache(arg0, arg1, arg2 any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DoCache", reflect.TypeOf((*Client)(nil).DoCache), arg0, arg1, arg2)
 }
 
+// DoIter mocks base method.
+func (m *Client) DoIter(arg0 context.Context, arg1 rueidis.Completed, arg2 func(rueidis.RedisMessage) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DoIter", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DoIter indicates an expected call of DoIter.
+func (mr *ClientMockRecorder) DoIter(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DoIter", reflect.TypeOf((*Client)(nil).DoIter), arg0, arg1, arg2)
+}
+
 // DoMulti mocks base method.
 func (m *Client) DoMulti(arg0 context.Context, arg1 ...rueidis.Completed) []rueidis.RedisResult {
 	m.ctrl.T.Helper()
@@ -167,6 +225,20 @@ func (mr *ClientMockRecorder) DoMultiCache(arg0 any, arg1 ...any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DoMultiCache", reflect.TypeOf((*Client)(nil).DoMultiCache), varargs...)
 }
 
+// DoStream mocks base method.
+func (m *Client) DoStream(arg0 context.Context, arg1 rueidis.Completed) rueidis.RedisResultStream {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DoStream", arg0, arg1)
+	ret0, _ := ret[0].(rueidis.RedisResultStream)
+	return ret0
+}
+
+// DoStream indicates an expected call of DoStream.
+func (mr *ClientMockRecorder) DoStream(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DoStream", reflect.TypeOf((*Client)(nil).DoStream), arg0, arg1)
+}
+
 // Nodes mocks base method.
 func (m *Client) Nodes() map[string]rueidis.Client {
 	m.ctrl.T.Helper()
@@ -195,6 +267,20 @@ func (mr *ClientMockRecorder) Receive(arg0, arg1, arg2 any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Receive", reflect.TypeOf((*Client)(nil).Receive), arg0, arg1, arg2)
 }
 
+// Stats mocks base method.
+func (m *Client) Stats() rueidis.ClientStats {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Stats")
+	ret0, _ := ret[0].(rueidis.ClientStats)
+	return ret0
+}
+
+// Stats indicates an expected call of Stats.
+func (mr *ClientMockRecorder) Stats() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stats", reflect.TypeOf((*Client)(nil).Stats))
+}
+
 // DedicatedClient is a mock of DedicatedClient interface.
 type DedicatedClient struct {
 	ctrl     *gomock.Controller
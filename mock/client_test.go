@@ -62,6 +62,18 @@ func TestNewClient(t *testing.T) {
 			t.Fatalf("unexpected val %v", nodes)
 		}
 	}
+	{
+		client.EXPECT().Stats().Return(rueidis.ClientStats{Nodes: map[string]rueidis.NodeStats{"addr": {Dials: 1}}})
+		if stats := client.Stats(); stats.Nodes["addr"].Dials != 1 {
+			t.Fatalf("unexpected val %v", stats)
+		}
+	}
+	{
+		client.EXPECT().CloseWithContext(context.Background()).Return(context.Canceled)
+		if err := client.CloseWithContext(context.Background()); err != context.Canceled {
+			t.Fatalf("unexpected err %v", err)
+		}
+	}
 	{
 		ch := make(chan struct{})
 		client.EXPECT().Close().Do(func() { close(ch) })
@@ -106,6 +118,19 @@ func TestNewClient(t *testing.T) {
 			t.Fatalf("unexpected err %v", err)
 		}
 	}
+	{
+		dc := NewDedicatedClient(ctrl)
+		client.EXPECT().DedicateCtx(context.Background()).Return(dc, func() {}, nil)
+		if c, _, err := client.DedicateCtx(context.Background()); c != dc || err != nil {
+			t.Fatalf("unexpected val %v %v", c, err)
+		}
+	}
+	{
+		client.EXPECT().DedicatedCtx(context.Background(), gomock.Any()).Return(context.Canceled)
+		if err := client.DedicatedCtx(context.Background(), func(c rueidis.DedicatedClient) error { return nil }); err != context.Canceled {
+			t.Fatalf("unexpected err %v", err)
+		}
+	}
 }
 
 func TestNewDedicatedClient(t *testing.T) {
@@ -2,6 +2,7 @@ package rueidis
 
 import (
 	"context"
+	"math"
 	"net"
 	"runtime"
 	"sync"
@@ -49,12 +50,16 @@ type conn interface {
 	DoMulti(ctx context.Context, multi ...Completed) *redisresults
 	DoMultiCache(ctx context.Context, multi ...CacheableTTL) *redisresults
 	Receive(ctx context.Context, subscribe Completed, fn func(message PubSubMessage)) error
+	DoStream(ctx context.Context, cmd Completed) RedisResultStream
+	DoIter(ctx context.Context, cmd Completed, fn func(elem RedisMessage) error) error
 	Info() map[string]RedisMessage
+	NodeStats() NodeStats
 	Error() error
 	Close()
+	CloseWithContext(ctx context.Context) int
 	Dial() error
 	Override(conn)
-	Acquire() wire
+	Acquire(ctx context.Context) (wire, error)
 	Store(w wire)
 	Addr() string
 }
@@ -65,45 +70,88 @@ type mux struct {
 	init   wire
 	dead   wire
 	pool   *pool
+	spool  *pool
 	wireFn wireFn
 	dst    string
 	wire   []atomic.Value
 	sc     []*singleconnect
 	mu     []sync.Mutex
 	maxp   int
+	mask   uint16
+	lane   uint16
+	lload  bool
+	cb     *breaker
+	dials  atomic.Uint64
+	derrs  atomic.Uint64
 }
 
-func makeMux(dst string, option *ClientOption, dialFn dialFn) *mux {
+func makeMux(dst string, option *ClientOption, dialFn dialFn) (m *mux) {
 	dead := deadFn()
-	return newMux(dst, option, (*pipe)(nil), dead, func() (w wire) {
-		w, err := newPipe(func() (net.Conn, error) {
-			return dialFn(dst, option)
-		}, option)
+	connFn := func() (net.Conn, error) {
+		conn, err := dialFn(dst, option)
 		if err != nil {
-			dead.error.Store(&errs{error: err})
-			w = dead
+			m.derrs.Add(1)
+		} else {
+			m.dials.Add(1)
 		}
-		return w
-	})
+		return conn, err
+	}
+	pipeFn := func(fn func(string, func() (net.Conn, error), *ClientOption) (*pipe, error)) wireFn {
+		return func() (w wire) {
+			probe, err := m.cb.allow()
+			if err == nil {
+				var p *pipe
+				if p, err = fn(dst, connFn, option); err == nil && probe {
+					timeout := option.Dialer.Timeout
+					if timeout <= 0 {
+						timeout = DefaultDialTimeout
+					}
+					ctx, cancel := context.WithTimeout(context.Background(), timeout)
+					if err = p.Do(ctx, cmds.PingCmd).Error(); err != nil {
+						p.Close()
+					}
+					cancel()
+				}
+				m.cb.report(err)
+				w = p
+			}
+			if err != nil {
+				dead.error.Store(&errs{error: err})
+				w = dead
+			}
+			return w
+		}
+	}
+	return newMux(dst, option, (*pipe)(nil), dead, pipeFn(newPipe), pipeFn(newPipeNoReauth), pipeFn(newPipeNoBg))
 }
 
-func newMux(dst string, option *ClientOption, init, dead wire, wireFn wireFn) *mux {
+func newMux(dst string, option *ClientOption, init, dead wire, wireFn, wirePoolFn, wireNoBgFn wireFn) *mux {
 	var multiplex int
 	if option.PipelineMultiplex >= 0 {
 		multiplex = 1 << option.PipelineMultiplex
 	} else {
 		multiplex = 1
 	}
+	pipes := multiplex
+	if option.PriorityLane {
+		pipes++ // the last pipe is the priority lane
+	}
 	m := &mux{dst: dst, init: init, dead: dead, wireFn: wireFn,
-		wire: make([]atomic.Value, multiplex),
-		mu:   make([]sync.Mutex, multiplex),
-		sc:   make([]*singleconnect, multiplex),
-		maxp: runtime.GOMAXPROCS(0),
+		wire:  make([]atomic.Value, pipes),
+		mu:    make([]sync.Mutex, pipes),
+		sc:    make([]*singleconnect, pipes),
+		maxp:  runtime.GOMAXPROCS(0),
+		mask:  uint16(multiplex - 1),
+		lload: option.PipelineLeastLoaded,
+	}
+	if option.PriorityLane {
+		m.lane = uint16(multiplex)
 	}
 	for i := 0; i < len(m.wire); i++ {
 		m.wire[i].Store(init)
 	}
-	m.pool = newPool(option.BlockingPoolSize, dead, wireFn)
+	m.pool = newPool(option.BlockingPoolSize, dead, option.BlockingPoolCleanup, option.BlockingPoolMaxLifetime, option.BlockingPoolMinSize, wirePoolFn)
+	m.spool = newPool(option.BlockingPoolSize, dead, option.BlockingPoolCleanup, option.BlockingPoolMaxLifetime, 0, wireNoBgFn)
 	return m
 }
 
@@ -140,6 +188,7 @@ func (m *mux) _pipe(i uint16) (w wire, err error) {
 			w.SetOnCloseHook(func(err error) {
 				if err != ErrClosing {
 					m.wire[i].CompareAndSwap(w, m.init)
+					m.cb.report(err)
 				}
 			})
 			m.wire[i].Store(w)
@@ -174,6 +223,20 @@ func (m *mux) Info() map[string]RedisMessage {
 	return m.pipe(0).Info()
 }
 
+func (m *mux) NodeStats() (s NodeStats) {
+	for i := 0; i < len(m.wire); i++ {
+		if w := m.wire[i].Load().(wire); w != m.init && w != m.dead {
+			s.Conns = append(s.Conns, w.Stats())
+		}
+	}
+	s.Pool = m.pool.Stats()
+	s.StreamPool = m.spool.Stats()
+	s.Dials = m.dials.Load()
+	s.DialErrors = m.derrs.Load()
+	s.Circuit = m.cb.State()
+	return s
+}
+
 func (m *mux) Error() error {
 	return m.pipe(0).Error()
 }
@@ -200,7 +263,10 @@ block:
 }
 
 func (m *mux) blocking(ctx context.Context, cmd Completed) (resp RedisResult) {
-	wire := m.pool.Acquire()
+	wire, err := m.pool.Acquire(ctx)
+	if err != nil {
+		return newErrResult(err)
+	}
 	resp = wire.Do(ctx, cmd)
 	if resp.NonRedisError() != nil { // abort the wire if blocking command return early (ex. context.DeadlineExceeded)
 		wire.Close()
@@ -210,7 +276,14 @@ func (m *mux) blocking(ctx context.Context, cmd Completed) (resp RedisResult) {
 }
 
 func (m *mux) blockingMulti(ctx context.Context, cmd []Completed) (resp *redisresults) {
-	wire := m.pool.Acquire()
+	wire, err := m.pool.Acquire(ctx)
+	if err != nil {
+		resp = resultsp.Get(len(cmd), len(cmd))
+		for i := range resp.s {
+			resp.s[i] = newErrResult(err)
+		}
+		return resp
+	}
 	resp = wire.DoMulti(ctx, cmd...)
 	for _, res := range resp.s {
 		if res.NonRedisError() != nil { // abort the wire if blocking command return early (ex. context.DeadlineExceeded)
@@ -222,8 +295,41 @@ func (m *mux) blockingMulti(ctx context.Context, cmd []Completed) (resp *redisre
 	return resp
 }
 
+// pipeSlot decides which pipe a non-cached command with the slot is pipelined to.
+// It is the priority lane for high priority commands if PriorityLane is set. Otherwise, it is the slot by default,
+// or the pipe with the fewest in-flight calls if PipelineLeastLoaded is set.
+// The cached commands and the pubsub commands always use the slot because the pipes have their own cache and subscriptions,
+// so callers must not use pipeSlot for them.
+func (m *mux) pipeSlot(slot uint16, prio bool) uint16 {
+	if prio && m.lane != 0 {
+		return m.lane
+	}
+	mask := m.mask
+	slot &= mask
+	if !m.lload || mask == 0 {
+		return slot
+	}
+	best, least := slot, math.MaxInt
+	for i := uint16(0); i <= mask; i++ {
+		s := (slot + i) & mask // start from the slot to spread the ties
+		queued := 0
+		if w := m.wire[s].Load().(wire); w != m.init {
+			queued = w.Queued()
+		}
+		if queued < least {
+			if best, least = s, queued; least == 0 {
+				break
+			}
+		}
+	}
+	return best
+}
+
 func (m *mux) pipeline(ctx context.Context, cmd Completed) (resp RedisResult) {
-	slot := cmd.Slot() & uint16(len(m.wire)-1)
+	slot := cmd.Slot() & m.mask
+	if !cmd.NoReply() { // the pubsub commands must go to the pipe used by the Receive
+		slot = m.pipeSlot(cmd.Slot(), cmd.IsHighPriority())
+	}
 	wire := m.pipe(slot)
 	if resp = wire.Do(ctx, cmd); isBroken(resp.NonRedisError(), wire) {
 		m.wire[slot].CompareAndSwap(wire, m.init)
@@ -232,7 +338,19 @@ func (m *mux) pipeline(ctx context.Context, cmd Completed) (resp RedisResult) {
 }
 
 func (m *mux) pipelineMulti(ctx context.Context, cmd []Completed) (resp *redisresults) {
-	slot := cmd[0].Slot() & uint16(len(m.wire)-1)
+	prio, pubsub := false, -1
+	for i := range cmd {
+		prio = prio || cmd[i].IsHighPriority()
+		if pubsub < 0 && cmd[i].NoReply() {
+			pubsub = i
+		}
+	}
+	var slot uint16
+	if pubsub >= 0 { // the pubsub commands must go to the pipe used by the Receive
+		slot = cmd[pubsub].Slot() & m.mask
+	} else {
+		slot = m.pipeSlot(cmd[0].Slot(), prio)
+	}
 	wire := m.pipe(slot)
 	resp = wire.DoMulti(ctx, cmd...)
 	for _, r := range resp.s {
@@ -245,7 +363,7 @@ func (m *mux) pipelineMulti(ctx context.Context, cmd []Completed) (resp *redisre
 }
 
 func (m *mux) DoCache(ctx context.Context, cmd Cacheable, ttl time.Duration) RedisResult {
-	slot := cmd.Slot() & uint16(len(m.wire)-1)
+	slot := cmd.Slot() & m.mask
 	wire := m.pipe(slot)
 	resp := wire.DoCache(ctx, cmd, ttl)
 	if isBroken(resp.NonRedisError(), wire) {
@@ -256,7 +374,7 @@ func (m *mux) DoCache(ctx context.Context, cmd Cacheable, ttl time.Duration) Red
 
 func (m *mux) DoMultiCache(ctx context.Context, multi ...CacheableTTL) (results *redisresults) {
 	var slots map[uint16]int
-	var mask = uint16(len(m.wire) - 1)
+	var mask = m.mask
 
 	if mask == 0 {
 		return m.doMultiCache(ctx, 0, multi)
@@ -311,7 +429,7 @@ func (m *mux) doMultiCache(ctx context.Context, slot uint16, multi []CacheableTT
 }
 
 func (m *mux) Receive(ctx context.Context, subscribe Completed, fn func(message PubSubMessage)) error {
-	slot := subscribe.Slot() & uint16(len(m.wire)-1)
+	slot := subscribe.Slot() & m.mask
 	wire := m.pipe(slot)
 	err := wire.Receive(ctx, subscribe, fn)
 	if isBroken(err, wire) {
@@ -320,8 +438,26 @@ func (m *mux) Receive(ctx context.Context, subscribe Completed, fn func(message
 	return err
 }
 
-func (m *mux) Acquire() wire {
-	return m.pool.Acquire()
+func (m *mux) DoStream(ctx context.Context, cmd Completed) RedisResultStream {
+	wire, err := m.spool.Acquire(ctx)
+	if err != nil {
+		return RedisResultStream{e: err}
+	}
+	s := wire.DoStream(ctx, cmd)
+	if s.w == nil { // the wire is not held by the stream, so put it back immediately
+		m.spool.Store(wire)
+	}
+	s.p = m.spool
+	return s
+}
+
+func (m *mux) DoIter(ctx context.Context, cmd Completed, fn func(elem RedisMessage) error) error {
+	s := m.DoStream(ctx, cmd)
+	return s.iter(fn)
+}
+
+func (m *mux) Acquire(ctx context.Context) (wire, error) {
+	return m.pool.Acquire(ctx)
 }
 
 func (m *mux) Store(w wire) {
@@ -337,6 +473,25 @@ func (m *mux) Close() {
 		}
 	}
 	m.pool.Close()
+	m.spool.Close()
+}
+
+func (m *mux) CloseWithContext(ctx context.Context) int {
+	var abandoned atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < len(m.wire); i++ {
+		if prev := m.wire[i].Swap(m.dead).(wire); prev != m.init && prev != m.dead {
+			wg.Add(1)
+			go func(w wire) {
+				abandoned.Add(int64(w.CloseWithContext(ctx)))
+				wg.Done()
+			}(prev)
+		}
+	}
+	m.pool.Close()
+	m.spool.Close()
+	wg.Wait()
+	return int(abandoned.Load())
 }
 
 func (m *mux) Addr() string {
@@ -2,12 +2,17 @@ package rueidis
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net"
+	"reflect"
 	"runtime"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -23,16 +28,17 @@ func setupMux(wires []*mockWire) (conn *mux, checkClean func(t *testing.T)) {
 func setupMuxWithOption(wires []*mockWire, option *ClientOption) (conn *mux, checkClean func(t *testing.T)) {
 	var mu sync.Mutex
 	var count = -1
-	return newMux("", option, (*mockWire)(nil), (*mockWire)(nil), func() wire {
-			mu.Lock()
-			defer mu.Unlock()
-			count++
-			return wires[count]
-		}), func(t *testing.T) {
-			if count != len(wires)-1 {
-				t.Fatalf("there is %d remaining unused wires", len(wires)-count-1)
-			}
+	wfn := func() wire {
+		mu.Lock()
+		defer mu.Unlock()
+		count++
+		return wires[count]
+	}
+	return newMux("", option, (*mockWire)(nil), (*mockWire)(nil), wfn, wfn, wfn), func(t *testing.T) {
+		if count != len(wires)-1 {
+			t.Fatalf("there is %d remaining unused wires", len(wires)-count-1)
 		}
+	}
 }
 
 func TestNewMuxDailErr(t *testing.T) {
@@ -55,12 +61,190 @@ func TestNewMuxDailErr(t *testing.T) {
 	if err := m.Dial(); err != e { // c = 3
 		t.Fatalf("unexpected return %v", err)
 	}
-	if w := m.Acquire(); w != m.dead {
+	if w, _ := m.Acquire(context.Background()); w != m.dead {
 		t.Fatalf("unexpected wire %v", w)
 	}
 	if c != 4 {
 		t.Fatalf("dialFn not called %v", c)
 	}
+	if s := m.NodeStats(); s.Dials != 0 || s.DialErrors != 4 || len(s.Conns) != 0 {
+		t.Fatalf("unexpected stats %v", s)
+	}
+}
+
+func TestMuxCircuitBreaker(t *testing.T) {
+	defer ShouldNotLeaked(SetupLeakDetection())
+	var changes []string
+	var dialErr error
+	dials := 0
+	n1, n2 := net.Pipe()
+	m := makeMux("addr", &ClientOption{}, func(dst string, opt *ClientOption) (net.Conn, error) {
+		dials++
+		if dialErr != nil {
+			return nil, dialErr
+		}
+		return n1, nil
+	})
+	m.cb = newBreaker("addr", CircuitBreakerOption{
+		Threshold: 2,
+		Cooldown:  50 * time.Millisecond,
+		OnStateChange: func(addr string, from, to CircuitState) {
+			changes = append(changes, addr+":"+from.String()+"->"+to.String())
+		},
+	})
+	dialErr = errors.New("any")
+	for i := 0; i < 2; i++ {
+		if err := m.Dial(); err != dialErr {
+			t.Fatalf("unexpected return %v", err)
+		}
+	}
+	if err := m.Dial(); err != ErrCircuitOpen {
+		t.Fatalf("unexpected return %v", err)
+	}
+	if w, _ := m.Acquire(context.Background()); w != m.dead || w.Error() != ErrCircuitOpen {
+		t.Fatalf("unexpected wire %v", w)
+	}
+	if err := m.Do(context.Background(), cmds.NewCompleted([]string{"GET", "a"})).Error(); err != ErrCircuitOpen {
+		t.Fatalf("unexpected err %v", err)
+	}
+	if dials != 2 {
+		t.Fatalf("unexpected dials %v", dials)
+	}
+	if s := m.NodeStats(); s.Circuit != CircuitOpen {
+		t.Fatalf("unexpected circuit state %v", s.Circuit)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if err := m.Dial(); err != dialErr { // the failed probe opens the breaker again
+		t.Fatalf("unexpected return %v", err)
+	}
+	if err := m.Dial(); err != ErrCircuitOpen {
+		t.Fatalf("unexpected return %v", err)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	dialErr = nil
+	go func() {
+		mock := &redisMock{t: t, buf: bufio.NewReader(n2), conn: n2}
+		mock.Expect("HELLO", "3").
+			Reply(RedisMessage{
+				typ: '%',
+				values: []RedisMessage{
+					{typ: '+', string: "proto"},
+					{typ: ':', integer: 3},
+				},
+			})
+		mock.Expect("CLIENT", "TRACKING", "ON", "OPTIN").
+			ReplyString("OK")
+		mock.Expect("PING").ReplyString("PONG")
+		mock.Expect("QUIT").ReplyString("OK")
+		mock.Close()
+	}()
+	if err := m.Dial(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if s := m.NodeStats(); s.Circuit != CircuitClosed || dials != 4 {
+		t.Fatalf("unexpected circuit state %v %v", s.Circuit, dials)
+	}
+	m.Close()
+
+	if exp := []string{
+		"addr:closed->open",
+		"addr:open->half-open",
+		"addr:half-open->open",
+		"addr:open->half-open",
+		"addr:half-open->closed",
+	}; !reflect.DeepEqual(changes, exp) {
+		t.Fatalf("unexpected state changes %v", changes)
+	}
+}
+
+func TestMuxCircuitBreakerProbeTimeout(t *testing.T) {
+	defer ShouldNotLeaked(SetupLeakDetection())
+	var dialErr = errors.New("any")
+	n1, n2 := net.Pipe()
+	m := makeMux("addr", &ClientOption{Dialer: net.Dialer{Timeout: 50 * time.Millisecond}}, func(dst string, opt *ClientOption) (net.Conn, error) {
+		if dialErr != nil {
+			return nil, dialErr
+		}
+		return n1, nil
+	})
+	m.cb = newBreaker("addr", CircuitBreakerOption{Threshold: 1, Cooldown: 10 * time.Millisecond})
+	if err := m.Dial(); err != dialErr {
+		t.Fatalf("unexpected return %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	dialErr = nil
+	go func() {
+		mock := &redisMock{t: t, buf: bufio.NewReader(n2), conn: n2}
+		mock.Expect("HELLO", "3").
+			Reply(RedisMessage{
+				typ: '%',
+				values: []RedisMessage{
+					{typ: '+', string: "proto"},
+					{typ: ':', integer: 3},
+				},
+			})
+		mock.Expect("CLIENT", "TRACKING", "ON", "OPTIN").
+			ReplyString("OK")
+		for { // never reply the PING
+			if _, err := mock.ReadMessage(); err != nil {
+				break
+			}
+		}
+		mock.Close()
+	}()
+	if err := m.Dial(); err == nil {
+		t.Fatalf("the probe should be timed out")
+	}
+	if s := m.NodeStats(); s.Circuit != CircuitOpen {
+		t.Fatalf("unexpected circuit state %v", s.Circuit)
+	}
+	m.Close()
+}
+
+func TestMakeConnFnSharesBreakers(t *testing.T) {
+	defer ShouldNotLeaked(SetupLeakDetection())
+	fn := makeConnFn(CircuitBreakerOption{Threshold: 1})
+	c1 := fn("addr", &ClientOption{}).(*mux)
+	c2 := fn("addr", &ClientOption{}).(*mux)
+	c3 := fn("other", &ClientOption{}).(*mux)
+	if c1.cb == nil || c1.cb != c2.cb || c1.cb == c3.cb {
+		t.Fatalf("unexpected breakers")
+	}
+	c1.Close()
+	c2.Close()
+	c3.Close()
+}
+
+func TestMuxCloseWithContext(t *testing.T) {
+	defer ShouldNotLeaked(SetupLeakDetection())
+	var ctxs []context.Context
+	var mu sync.Mutex
+	wires := make([]*mockWire, 2)
+	for i := range wires {
+		wires[i] = &mockWire{CloseWithCtxFn: func(ctx context.Context) int {
+			mu.Lock()
+			ctxs = append(ctxs, ctx)
+			mu.Unlock()
+			return 2
+		}}
+	}
+	m, checkClean := setupMuxWithOption(wires, &ClientOption{PipelineMultiplex: 1})
+	defer checkClean(t)
+	m.pipe(0)
+	m.pipe(1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if n := m.CloseWithContext(ctx); n != 4 {
+		t.Fatalf("unexpected abandoned %v", n)
+	}
+	if len(ctxs) != 2 || ctxs[0] != ctx || ctxs[1] != ctx {
+		t.Fatalf("unexpected ctxs %v", ctxs)
+	}
+	if s := m.NodeStats(); len(s.Conns) != 0 {
+		t.Fatalf("unexpected stats %v", s)
+	}
 }
 
 func TestNewMux(t *testing.T) {
@@ -99,6 +283,216 @@ func TestNewMux(t *testing.T) {
 		m2.Close()
 	})
 }
+func TestMuxDoStream(t *testing.T) {
+	defer ShouldNotLeaked(SetupLeakDetection())
+	setupStream := func(option *ClientOption, serve func(mock *redisMock)) (*mux, *int) {
+		n1, n2 := net.Pipe()
+		mock := &redisMock{t: t, buf: bufio.NewReader(n2), conn: n2}
+		dials := 0
+		m := makeMux("", option, func(dst string, opt *ClientOption) (net.Conn, error) {
+			dials++
+			return n1, nil
+		})
+		go func() {
+			mock.Expect("HELLO", "3").
+				Reply(RedisMessage{
+					typ: '%',
+					values: []RedisMessage{
+						{typ: '+', string: "proto"},
+						{typ: ':', integer: 3},
+					},
+				})
+			mock.Expect("CLIENT", "TRACKING", "ON", "OPTIN").
+				ReplyString("OK")
+			serve(mock)
+			mock.Close()
+		}()
+		return m, &dials
+	}
+
+	t.Run("Stream and Reuse", func(t *testing.T) {
+		m, dials := setupStream(&ClientOption{OnInvalidations: func(messages []RedisMessage) {}}, func(mock *redisMock) {
+			mock.Expect("GET", "a").Reply(RedisMessage{typ: '$', string: "Hello World"})
+			mock.Expect("GET", "b").Reply(RedisMessage{typ: '_'})
+			mock.Expect("QUIT").ReplyString("OK")
+		})
+		s := m.DoStream(context.Background(), cmds.NewCompleted([]string{"GET", "a"}))
+		if atomic.LoadInt32(&s.w.state) != 0 {
+			t.Fatalf("unexpected background worker on stream wire")
+		}
+		var completes []error
+		s.OnComplete(func(err error) { completes = append(completes, err) })
+		if len(completes) != 0 {
+			t.Fatalf("unexpected OnComplete before WriteTo %v", completes)
+		}
+		buf := bytes.NewBuffer(nil)
+		for s.HasNext() {
+			if n, err := s.WriteTo(buf); err != nil || n != 11 {
+				t.Fatalf("unexpected WriteTo %v %v", n, err)
+			}
+		}
+		if buf.String() != "Hello World" || s.Error() != io.EOF {
+			t.Fatalf("unexpected stream %v %v", buf.String(), s.Error())
+		}
+		if len(completes) != 1 || completes[0] != nil {
+			t.Fatalf("unexpected OnComplete %v", completes)
+		}
+		s.OnComplete(func(err error) { completes = append(completes, err) })
+		if len(completes) != 2 || completes[1] != nil {
+			t.Fatalf("unexpected OnComplete on finished stream %v", completes)
+		}
+		if n, err := s.WriteTo(buf); err != io.EOF || n != 0 {
+			t.Fatalf("unexpected WriteTo %v %v", n, err)
+		}
+		s = m.DoStream(context.Background(), cmds.NewCompleted([]string{"GET", "b"}))
+		s.OnComplete(func(err error) { completes = append(completes, err) })
+		if _, err := s.WriteTo(buf); err != Nil {
+			t.Fatalf("unexpected WriteTo %v", err)
+		}
+		if len(completes) != 3 || completes[2] != Nil {
+			t.Fatalf("unexpected OnComplete %v", completes)
+		}
+		if *dials != 1 {
+			t.Fatalf("unexpected dials %v", *dials)
+		}
+		m.Close()
+	})
+
+	t.Run("Writer Error Closes Wire", func(t *testing.T) {
+		m, _ := setupStream(&ClientOption{}, func(mock *redisMock) {
+			mock.Expect("GET", "a").Reply(RedisMessage{typ: '$', string: "Hello World"})
+		})
+		e := errors.New("any")
+		s := m.DoStream(context.Background(), cmds.NewCompleted([]string{"GET", "a"}))
+		w := s.w
+		if _, err := s.WriteTo(errWriter{err: e}); err == nil {
+			t.Fatalf("unexpected no error")
+		}
+		if s.HasNext() || s.Error() == nil || w.Error() == nil {
+			t.Fatalf("unexpected stream state %v %v", s.Error(), w.Error())
+		}
+		m.Close()
+	})
+
+	t.Run("DoIter and Reuse", func(t *testing.T) {
+		m, dials := setupStream(&ClientOption{}, func(mock *redisMock) {
+			mock.Expect("HGETALL", "a").Reply(RedisMessage{typ: '%', values: []RedisMessage{
+				{typ: '+', string: "f1"}, {typ: '+', string: "v1"},
+				{typ: '+', string: "f2"}, {typ: '+', string: "v2"},
+			}})
+			mock.Expect("GET", "b").ReplyString("b")
+			mock.Expect("QUIT").ReplyString("OK")
+		})
+		var elems []string
+		if err := m.DoIter(context.Background(), cmds.NewCompleted([]string{"HGETALL", "a"}), func(elem RedisMessage) error {
+			elems = append(elems, elem.string)
+			return nil
+		}); err != nil {
+			t.Fatalf("unexpected err %v", err)
+		}
+		if !reflect.DeepEqual(elems, []string{"f1", "v1", "f2", "v2"}) {
+			t.Fatalf("unexpected elements %v", elems)
+		}
+		if err := m.DoIter(context.Background(), cmds.NewCompleted([]string{"GET", "b"}), func(elem RedisMessage) error {
+			if elem.string != "b" {
+				t.Fatalf("unexpected element %v", elem)
+			}
+			return nil
+		}); err != nil {
+			t.Fatalf("unexpected err %v", err)
+		}
+		if *dials != 1 {
+			t.Fatalf("unexpected dials %v", *dials)
+		}
+		m.Close()
+	})
+
+	t.Run("DoIter Callback Error Closes Wire", func(t *testing.T) {
+		m, _ := setupStream(&ClientOption{}, func(mock *redisMock) {
+			mock.Expect("LRANGE", "a", "0", "-1").Reply(RedisMessage{typ: '*', values: []RedisMessage{
+				{typ: '+', string: "1"}, {typ: '+', string: "2"},
+			}})
+		})
+		e := errors.New("any")
+		if err := m.DoIter(context.Background(), cmds.NewCompleted([]string{"LRANGE", "a", "0", "-1"}), func(elem RedisMessage) error {
+			return e
+		}); err != e {
+			t.Fatalf("unexpected err %v", err)
+		}
+		m.Close()
+	})
+
+	t.Run("Context Canceled", func(t *testing.T) {
+		m, _ := setupStream(&ClientOption{}, func(mock *redisMock) {
+			mock.Expect("QUIT").ReplyString("OK")
+		})
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if s := m.DoStream(ctx, cmds.NewCompleted([]string{"GET", "a"})); s.Error() != context.Canceled || s.HasNext() {
+			t.Fatalf("unexpected stream %v", s.Error())
+		}
+		m.Close()
+	})
+}
+
+func TestMuxStats(t *testing.T) {
+	m, checkClean := setupMux([]*mockWire{
+		{
+			StatsFn: func() ConnStats {
+				return ConnStats{Commands: 1, Queued: 2}
+			},
+		},
+		{},
+	})
+	defer checkClean(t)
+	if s := m.NodeStats(); len(s.Conns) != 0 {
+		t.Fatalf("unexpected stats %v", s)
+	}
+	if err := m.Dial(); err != nil {
+		t.Fatalf("unexpected err %v", err)
+	}
+	w, _ := m.Acquire(context.Background())
+	if s := m.NodeStats(); len(s.Conns) != 1 || s.Conns[0].Commands != 1 || s.Conns[0].Queued != 2 ||
+		s.Pool != (PoolStats{Acquired: 1}) || s.StreamPool != (PoolStats{}) {
+		t.Fatalf("unexpected stats %v", s)
+	}
+	m.Store(w)
+	if s := m.NodeStats(); s.Pool != (PoolStats{Idle: 1}) {
+		t.Fatalf("unexpected stats %v", s)
+	}
+}
+
+func TestMuxBlockingPoolExhausted(t *testing.T) {
+	defer ShouldNotLeaked(SetupLeakDetection())
+	m, checkClean := setupMuxWithOption([]*mockWire{{}, {}}, &ClientOption{BlockingPoolSize: 1})
+	defer checkClean(t)
+	defer m.Close()
+	w, err := m.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected err %v", err)
+	}
+	s, _ := m.spool.Acquire(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := m.Acquire(ctx); !errors.Is(err, ErrPoolExhausted) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("unexpected err %v", err)
+	}
+	if err := m.Do(ctx, cmds.NewBlockingCompleted([]string{"BLPOP", "a", "0"})).Error(); !errors.Is(err, ErrPoolExhausted) {
+		t.Fatalf("unexpected err %v", err)
+	}
+	for _, resp := range m.DoMulti(ctx, cmds.NewBlockingCompleted([]string{"BLPOP", "a", "0"}), cmds.NewCompleted([]string{"GET", "a"})).s {
+		if err := resp.Error(); !errors.Is(err, ErrPoolExhausted) {
+			t.Fatalf("unexpected err %v", err)
+		}
+	}
+	if stream := m.DoStream(ctx, cmds.NewCompleted([]string{"GET", "a"})); !errors.Is(stream.Error(), ErrPoolExhausted) {
+		t.Fatalf("unexpected err %v", stream.Error())
+	}
+	m.Store(w)
+	m.spool.Store(s)
+}
+
 func TestNewMuxPipelineMultiplex(t *testing.T) {
 	defer ShouldNotLeaked(SetupLeakDetection())
 	for _, v := range []int{-1, 0, 1, 2} {
@@ -121,11 +515,12 @@ func TestMuxDialSuppress(t *testing.T) {
 	defer ShouldNotLeaked(SetupLeakDetection())
 	var wires, waits, done int64
 	blocking := make(chan struct{})
-	m := newMux("", &ClientOption{}, (*mockWire)(nil), (*mockWire)(nil), func() wire {
+	wfn := func() wire {
 		atomic.AddInt64(&wires, 1)
 		<-blocking
 		return &mockWire{}
-	})
+	}
+	m := newMux("", &ClientOption{}, (*mockWire)(nil), (*mockWire)(nil), wfn, wfn, wfn)
 	for i := 0; i < 1000; i++ {
 		go func() {
 			atomic.AddInt64(&waits, 1)
@@ -191,7 +586,7 @@ func TestMuxReuseWire(t *testing.T) {
 			t.Fatalf("unexpected dial error %v", err)
 		}
 
-		wire1 := m.Acquire()
+		wire1, _ := m.Acquire(context.Background())
 
 		go func() {
 			// this should use the second wire
@@ -235,7 +630,7 @@ func TestMuxReuseWire(t *testing.T) {
 			t.Fatalf("unexpected dial error %v", err)
 		}
 
-		wire1 := m.Acquire()
+		wire1, _ := m.Acquire(context.Background())
 		m.Store(wire1)
 
 		if !cleaned {
@@ -740,6 +1135,223 @@ func TestMuxRegisterCloseHook(t *testing.T) {
 	})
 }
 
+func TestMuxPipelineLeastLoaded(t *testing.T) {
+	multiplex := 2
+	loads := make([]int32, 1<<multiplex)
+	wires := make([]*mockWire, 1<<multiplex)
+	subscribed := -1
+	for i := range wires {
+		idx := i
+		wires[i] = &mockWire{
+			ReceiveFn: func(ctx context.Context, subscribe Completed, fn func(message PubSubMessage)) error {
+				subscribed = idx
+				return nil
+			},
+			DoFn: func(cmd Completed) RedisResult {
+				return newResult(RedisMessage{typ: '+', string: strconv.Itoa(idx)}, nil)
+			},
+			DoMultiFn: func(multi ...Completed) *redisresults {
+				return &redisresults{s: []RedisResult{newResult(RedisMessage{typ: '+', string: strconv.Itoa(idx)}, nil)}}
+			},
+			DoCacheFn: func(cmd Cacheable, ttl time.Duration) RedisResult {
+				return newResult(RedisMessage{typ: '+', string: strconv.Itoa(idx)}, nil)
+			},
+			QueuedFn: func() int {
+				return int(atomic.LoadInt32(&loads[idx]))
+			},
+		}
+	}
+	m, checkClean := setupMuxWithOption(wires, &ClientOption{PipelineMultiplex: multiplex, PipelineLeastLoaded: true})
+	defer checkClean(t)
+	defer m.Close()
+
+	for i := range wires {
+		m._pipe(uint16(i))
+	}
+
+	builder := cmds.NewBuilder(cmds.NoSlot)
+	cmd := builder.Get().Key("a").Build()
+	for _, c := range []struct {
+		loads []int32
+		exp   string
+	}{
+		{loads: []int32{3, 1, 0, 2}, exp: "2"},
+		{loads: []int32{3, 1, 4, 2}, exp: "1"},
+		{loads: []int32{0, 0, 0, 0}, exp: strconv.Itoa(int(cmd.Slot() & uint16(len(wires)-1)))},
+	} {
+		for i, l := range c.loads {
+			atomic.StoreInt32(&loads[i], l)
+		}
+		if v, err := m.Do(context.Background(), cmd).ToString(); err != nil || v != c.exp {
+			t.Fatalf("unexpected pipe for Do with loads %v: %v %v", c.loads, v, err)
+		}
+		if v, err := m.DoMulti(context.Background(), cmd).s[0].ToString(); err != nil || v != c.exp {
+			t.Fatalf("unexpected pipe for DoMulti with loads %v: %v %v", c.loads, v, err)
+		}
+	}
+
+	atomic.StoreInt32(&loads[cmd.Slot()&uint16(len(wires)-1)], 10)
+	cacheable := builder.Get().Key("a").Cache()
+	if v, err := m.DoCache(context.Background(), cacheable, time.Second).ToString(); err != nil || v != strconv.Itoa(int(cacheable.Slot()&uint16(len(wires)-1))) {
+		t.Fatalf("unexpected pipe for DoCache %v %v", v, err)
+	}
+
+	if err := m.Receive(context.Background(), builder.Subscribe().Channel("ch").Build(), func(msg PubSubMessage) {}); err != nil {
+		t.Fatalf("unexpected err %v", err)
+	}
+	for i := range loads {
+		atomic.StoreInt32(&loads[i], 0)
+	}
+	atomic.StoreInt32(&loads[subscribed], 10)
+	exp := strconv.Itoa(subscribed)
+	if v, err := m.Do(context.Background(), builder.Unsubscribe().Channel("ch").Build()).ToString(); err != nil || v != exp {
+		t.Fatalf("unexpected pipe for UNSUBSCRIBE %v %v", v, err)
+	}
+	if v, err := m.DoMulti(context.Background(), cmd, builder.Unsubscribe().Channel("ch").Build()).s[0].ToString(); err != nil || v != exp {
+		t.Fatalf("unexpected pipe for DoMulti with UNSUBSCRIBE %v %v", v, err)
+	}
+}
+
+func TestMuxPriorityLane(t *testing.T) {
+	newWires := func() []*mockWire {
+		wires := make([]*mockWire, 2)
+		for i := range wires {
+			idx := strconv.Itoa(i)
+			wires[i] = &mockWire{
+				DoFn: func(cmd Completed) RedisResult {
+					return newResult(RedisMessage{typ: '+', string: idx}, nil)
+				},
+				DoMultiFn: func(multi ...Completed) *redisresults {
+					return &redisresults{s: []RedisResult{newResult(RedisMessage{typ: '+', string: idx}, nil)}}
+				},
+				StatsFn: func() ConnStats {
+					return ConnStats{}
+				},
+			}
+		}
+		return wires
+	}
+	check := func(t *testing.T, m *mux, exp string, prio bool, multi ...bool) {
+		t.Helper()
+		cmd := cmds.NewCompleted([]string{"GET", "a"})
+		if prio {
+			cmd = cmd.HighPriority()
+		}
+		if len(multi) == 0 {
+			if v, err := m.Do(context.Background(), cmd).ToString(); err != nil || v != exp {
+				t.Fatalf("unexpected pipe for Do %v %v", v, err)
+			}
+			return
+		}
+		commands := make([]Completed, len(multi))
+		for i, p := range multi {
+			if commands[i] = cmds.NewCompleted([]string{"GET", "a"}); p {
+				commands[i] = commands[i].HighPriority()
+			}
+		}
+		if v, err := m.DoMulti(context.Background(), commands...).s[0].ToString(); err != nil || v != exp {
+			t.Fatalf("unexpected pipe for DoMulti %v %v", v, err)
+		}
+	}
+	t.Run("Enabled", func(t *testing.T) {
+		wires := newWires()
+		m, checkClean := setupMuxWithOption(wires, &ClientOption{PipelineMultiplex: 0, PriorityLane: true})
+		defer checkClean(t)
+		defer m.Close()
+
+		if len(m.wire) != 2 || m.mask != 0 || m.lane != 1 {
+			t.Fatalf("unexpected lane %v %v %v", len(m.wire), m.mask, m.lane)
+		}
+		check(t, m, "0", false)
+		check(t, m, "1", true)
+		check(t, m, "0", false, false, false)
+		check(t, m, "1", false, true, false)
+		if s := m.NodeStats(); len(s.Conns) != 2 {
+			t.Fatalf("unexpected stats %v", s)
+		}
+	})
+	t.Run("Disabled", func(t *testing.T) {
+		wires := newWires()[:1]
+		m, checkClean := setupMuxWithOption(wires, &ClientOption{PipelineMultiplex: 0})
+		defer checkClean(t)
+		defer m.Close()
+
+		check(t, m, "0", true)
+		check(t, m, "0", false, true)
+	})
+}
+
+func BenchmarkMuxPipelineLeastLoaded(b *testing.B) {
+	// every pipe replies in order, so the commands queued behind a slow command have to wait for it.
+	setup := func(b *testing.B, leastLoaded bool) *mux {
+		multiplex := 2
+		wires := make([]*mockWire, 1<<multiplex)
+		for i := range wires {
+			var mu sync.Mutex
+			var waits int32
+			wires[i] = &mockWire{
+				DoFn: func(cmd Completed) RedisResult {
+					atomic.AddInt32(&waits, 1)
+					mu.Lock()
+					if strings.HasPrefix(cmd.Commands()[1], "slow") {
+						time.Sleep(time.Millisecond)
+					}
+					mu.Unlock()
+					atomic.AddInt32(&waits, -1)
+					return newResult(RedisMessage{typ: '+', string: "OK"}, nil)
+				},
+				QueuedFn: func() int {
+					return int(atomic.LoadInt32(&waits))
+				},
+			}
+		}
+		m, _ := setupMuxWithOption(wires, &ClientOption{PipelineMultiplex: multiplex, PipelineLeastLoaded: leastLoaded})
+		for i := range wires {
+			m._pipe(uint16(i))
+		}
+		return m
+	}
+	run := func(b *testing.B, leastLoaded bool) {
+		m := setup(b, leastLoaded)
+		defer m.Close()
+		builder := cmds.NewBuilder(cmds.NoSlot)
+		commands := make([]Completed, 100)
+		for i := range commands {
+			if i == 0 { // 1% of the commands are slow
+				commands[i] = builder.Get().Key("slow").Build()
+			} else {
+				commands[i] = builder.Get().Key(strconv.Itoa(i)).Build()
+			}
+		}
+		var mu sync.Mutex
+		var latencies []time.Duration
+		b.SetParallelism(8)
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			local := make([]time.Duration, 0, 1024)
+			for i := 0; pb.Next(); i++ {
+				ts := time.Now()
+				m.Do(context.Background(), commands[i%len(commands)])
+				if i%len(commands) != 0 { // only the latencies of the fast commands are collected
+					local = append(local, time.Since(ts))
+				}
+			}
+			mu.Lock()
+			latencies = append(latencies, local...)
+			mu.Unlock()
+		})
+		b.StopTimer()
+		if len(latencies) == 0 {
+			return
+		}
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		b.ReportMetric(float64(latencies[len(latencies)*99/100].Nanoseconds()), "p99-ns")
+		b.ReportMetric(float64(latencies[len(latencies)*999/1000].Nanoseconds()), "p999-ns")
+	}
+	b.Run("Slot", func(b *testing.B) { run(b, false) })
+	b.Run("LeastLoaded", func(b *testing.B) { run(b, true) })
+}
+
 func BenchmarkClientSideCaching(b *testing.B) {
 	setup := func(b *testing.B) *mux {
 		c := makeMux("127.0.0.1:6379", &ClientOption{CacheSizeEachConn: DefaultCacheBytes}, func(dst string, opt *ClientOption) (conn net.Conn, err error) {
@@ -778,9 +1390,13 @@ type mockWire struct {
 	DoMultiFn      func(multi ...Completed) *redisresults
 	DoMultiCacheFn func(multi ...CacheableTTL) *redisresults
 	ReceiveFn      func(ctx context.Context, subscribe Completed, fn func(message PubSubMessage)) error
+	DoStreamFn     func(cmd Completed) RedisResultStream
 	InfoFn         func() map[string]RedisMessage
+	StatsFn        func() ConnStats
+	QueuedFn       func() int
 	ErrorFn        func() error
 	CloseFn        func()
+	CloseWithCtxFn func(ctx context.Context) int
 
 	CleanSubscriptionsFn func()
 	SetPubSubHooksFn     func(hooks PubSubHooks) <-chan error
@@ -822,6 +1438,13 @@ func (m *mockWire) Receive(ctx context.Context, subscribe Completed, fn func(mes
 	return nil
 }
 
+func (m *mockWire) DoStream(ctx context.Context, cmd Completed) RedisResultStream {
+	if m.DoStreamFn != nil {
+		return m.DoStreamFn(cmd)
+	}
+	return RedisResultStream{}
+}
+
 func (m *mockWire) CleanSubscriptions() {
 	if m.CleanSubscriptionsFn != nil {
 		m.CleanSubscriptionsFn()
@@ -850,6 +1473,20 @@ func (m *mockWire) Info() map[string]RedisMessage {
 	return nil
 }
 
+func (m *mockWire) Stats() ConnStats {
+	if m.StatsFn != nil {
+		return m.StatsFn()
+	}
+	return ConnStats{}
+}
+
+func (m *mockWire) Queued() int {
+	if m.QueuedFn != nil {
+		return m.QueuedFn()
+	}
+	return 0
+}
+
 func (m *mockWire) Error() error {
 	if m == nil {
 		return ErrClosing
@@ -868,3 +1505,13 @@ func (m *mockWire) Close() {
 		m.CloseFn()
 	}
 }
+
+func (m *mockWire) CloseWithContext(ctx context.Context) int {
+	if m == nil {
+		return 0
+	}
+	if m.CloseWithCtxFn != nil {
+		return m.CloseWithCtxFn(ctx)
+	}
+	return 0
+}
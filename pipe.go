@@ -27,9 +27,13 @@ type wire interface {
 	DoMulti(ctx context.Context, multi ...Completed) *redisresults
 	DoMultiCache(ctx context.Context, multi ...CacheableTTL) *redisresults
 	Receive(ctx context.Context, subscribe Completed, fn func(message PubSubMessage)) error
+	DoStream(ctx context.Context, cmd Completed) RedisResultStream
 	Info() map[string]RedisMessage
+	Stats() ConnStats
+	Queued() int
 	Error() error
 	Close()
+	CloseWithContext(ctx context.Context) int
 
 	CleanSubscriptions()
 	SetPubSubHooks(hooks PubSubHooks) <-chan error
@@ -79,23 +83,35 @@ var _ wire = (*pipe)(nil)
 type pipe struct {
 	conn            net.Conn
 	error           atomic.Value
+	cmds            atomic.Uint64
+	flushes         atomic.Uint64
+	rbytes          atomic.Uint64
+	wbytes          atomic.Uint64
+	hits            atomic.Uint64
+	misses          atomic.Uint64
 	clhks           atomic.Value
 	pshks           atomic.Value
 	queue           queue
 	cache           CacheStore
 	r               *bufio.Reader
 	w               *bufio.Writer
+	limits          protoLimits
 	close           chan struct{}
 	onInvalidations func([]RedisMessage)
+	onPush          func(addr string, kind string, values []RedisMessage)
 	r2psFn          func() (p *pipe, err error)
 	r2pipe          *pipe
 	ssubs           *subs
 	nsubs           *subs
 	psubs           *subs
 	info            map[string]RedisMessage
+	addr            string
+	tracking        []string
 	timeout         time.Duration
+	cmdto           time.Duration
 	pinggap         time.Duration
 	maxFlushDelay   time.Duration
+	batch           int
 	once            sync.Once
 	r2mu            sync.Mutex
 	version         int32
@@ -104,14 +120,37 @@ type pipe struct {
 	state           int32
 	waits           int32
 	recvs           int32
+	pending         int32 // the number of the commands of the in-flight calls
 	r2ps            bool
 }
 
-func newPipe(connFn func() (net.Conn, error), option *ClientOption) (p *pipe, err error) {
-	return _newPipe(connFn, option, false)
+func newPipe(dst string, connFn func() (net.Conn, error), option *ClientOption) (p *pipe, err error) {
+	if p, err = _newPipe(dst, connFn, option, false, false); err != nil {
+		return p, err
+	}
+	p.batch = option.MaxPipelineBatch // only the multiplexed pipes split a large DoMulti into chunks
+	if option.AuthCredentialsFn != nil && option.AuthCredentialsRefreshInterval > 0 {
+		timeout := option.Dialer.Timeout
+		if timeout <= 0 {
+			timeout = DefaultDialTimeout
+		}
+		go p.backgroundReauth(option.AuthCredentialsRefreshInterval, timeout, option.AuthCredentialsFn)
+	}
+	return p, nil
+}
+
+// newPipeNoReauth creates a pipe for the blocking pool, which is never re-authenticated in the background,
+// because the AUTH could land inside a WATCH/MULTI of a dedicated client or wait behind a blocking command.
+func newPipeNoReauth(dst string, connFn func() (net.Conn, error), option *ClientOption) (p *pipe, err error) {
+	return _newPipe(dst, connFn, option, false, false)
+}
+
+// newPipeNoBg creates a pipe that never starts its background goroutines, so that its reader can be used by DoStream.
+func newPipeNoBg(dst string, connFn func() (net.Conn, error), option *ClientOption) (p *pipe, err error) {
+	return _newPipe(dst, connFn, option, false, true)
 }
 
-func _newPipe(connFn func() (net.Conn, error), option *ClientOption, r2ps bool) (p *pipe, err error) {
+func _newPipe(dst string, connFn func() (net.Conn, error), option *ClientOption, r2ps, nobg bool) (p *pipe, err error) {
 	conn, err := connFn()
 	if err != nil {
 		return nil, err
@@ -119,23 +158,27 @@ func _newPipe(connFn func() (net.Conn, error), option *ClientOption, r2ps bool)
 	p = &pipe{
 		conn:  conn,
 		queue: newRing(option.RingScaleEachConn),
-		r:     bufio.NewReaderSize(conn, option.ReadBufferEachConn),
-		w:     bufio.NewWriterSize(conn, option.WriteBufferEachConn),
+
+		limits: newProtoLimits(option),
 
 		nsubs: newSubs(),
 		psubs: newSubs(),
 		ssubs: newSubs(),
 		close: make(chan struct{}),
 
+		addr:          dst,
 		timeout:       option.ConnWriteTimeout,
+		cmdto:         option.DefaultCommandTimeout,
 		pinggap:       option.Dialer.KeepAlive,
 		maxFlushDelay: option.MaxFlushDelay,
 
 		r2ps: r2ps,
 	}
+	p.r = bufio.NewReaderSize(countReader{r: conn, n: &p.rbytes}, option.ReadBufferEachConn)
+	p.w = bufio.NewWriterSize(countWriter{w: conn, n: &p.wbytes}, option.WriteBufferEachConn)
 	if !r2ps {
 		p.r2psFn = func() (p *pipe, err error) {
-			return _newPipe(connFn, option, true)
+			return _newPipe(dst, connFn, option, true, nobg)
 		}
 	}
 	if !option.DisableCache {
@@ -145,14 +188,33 @@ func _newPipe(connFn func() (net.Conn, error), option *ClientOption, r2ps bool)
 		}
 		p.cache = cacheStoreFn(CacheStoreOption{CacheSizeEachConn: option.CacheSizeEachConn})
 	}
+	if !nobg {
+		p.onPush = option.OnPush
+	}
 	p.pshks.Store(emptypshks)
 	p.clhks.Store(emptyclhks)
 
+	timeout := option.Dialer.Timeout
+	if timeout <= 0 {
+		timeout = DefaultDialTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	username, password := option.Username, option.Password
+	if option.AuthCredentialsFn != nil {
+		if username, password, err = option.AuthCredentialsFn(ctx); err != nil {
+			p.Close()
+			return nil, err
+		}
+	}
+
 	helloCmd := []string{"HELLO", "3"}
-	if option.Password != "" && option.Username == "" {
-		helloCmd = append(helloCmd, "AUTH", "default", option.Password)
-	} else if option.Username != "" {
-		helloCmd = append(helloCmd, "AUTH", option.Username, option.Password)
+	if password != "" && username == "" {
+		helloCmd = append(helloCmd, "AUTH", "default", password)
+	} else if username != "" {
+		helloCmd = append(helloCmd, "AUTH", username, password)
 	}
 	if option.ClientName != "" {
 		helloCmd = append(helloCmd, "SETNAME", option.ClientName)
@@ -160,10 +222,11 @@ func _newPipe(connFn func() (net.Conn, error), option *ClientOption, r2ps bool)
 
 	init := make([][]string, 0, 3)
 	if option.ClientTrackingOptions == nil {
-		init = append(init, helloCmd, []string{"CLIENT", "TRACKING", "ON", "OPTIN"})
+		p.tracking = []string{"CLIENT", "TRACKING", "ON", "OPTIN"}
 	} else {
-		init = append(init, helloCmd, append([]string{"CLIENT", "TRACKING", "ON"}, option.ClientTrackingOptions...))
+		p.tracking = append([]string{"CLIENT", "TRACKING", "ON"}, option.ClientTrackingOptions...)
 	}
+	init = append(init, helloCmd, p.tracking)
 	if option.ClientNoEvict {
 		init = append(init, []string{"CLIENT", "NO-EVICT", "ON"})
 	}
@@ -181,15 +244,10 @@ func _newPipe(connFn func() (net.Conn, error), option *ClientOption, r2ps bool)
 	if option.ClientNoTouch {
 		init = append(init, []string{"CLIENT", "NO-TOUCH", "ON"})
 	}
-
-	timeout := option.Dialer.Timeout
-	if timeout <= 0 {
-		timeout = DefaultDialTimeout
+	if option.ReplicaOnly && option.Sentinel.MasterSet == "" {
+		init = append(init, []string{"READONLY"})
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	defer cancel()
-
 	r2 := option.AlwaysRESP2
 	if !r2 && !r2ps {
 		resp := p.DoMulti(ctx, cmds.NewMultiCompleted(init)...)
@@ -226,17 +284,17 @@ func _newPipe(connFn func() (net.Conn, error), option *ClientOption, r2ps bool)
 				p.version = int32(vv)
 			}
 		}
-		p.onInvalidations = option.OnInvalidations
+		if !nobg {
+			p.onInvalidations = option.OnInvalidations
+		}
 	} else {
 		if !option.DisableCache {
 			p.Close()
 			return nil, ErrNoCache
 		}
 		init = init[:0]
-		if option.Password != "" && option.Username == "" {
-			init = append(init, []string{"AUTH", option.Password})
-		} else if option.Username != "" {
-			init = append(init, []string{"AUTH", option.Username, option.Password})
+		if auth := authCmd(username, password); auth != nil {
+			init = append(init, auth)
 		}
 		if option.ClientName != "" {
 			init = append(init, []string{"CLIENT", "SETNAME", option.ClientName})
@@ -255,6 +313,9 @@ func _newPipe(connFn func() (net.Conn, error), option *ClientOption, r2ps bool)
 		if option.ClientNoTouch {
 			init = append(init, []string{"CLIENT", "NO-TOUCH", "ON"})
 		}
+		if option.ReplicaOnly && option.Sentinel.MasterSet == "" {
+			init = append(init, []string{"READONLY"})
+		}
 
 		if len(init) != 0 {
 			resp := p.DoMulti(ctx, cmds.NewMultiCompleted(init)...)
@@ -268,7 +329,10 @@ func _newPipe(connFn func() (net.Conn, error), option *ClientOption, r2ps bool)
 		}
 		p.version = 5
 	}
-	if p.onInvalidations != nil || option.AlwaysPipelining {
+	if nobg {
+		return p, nil
+	}
+	if p.onInvalidations != nil || p.onPush != nil || option.AlwaysPipelining {
 		p.background()
 	}
 	if p.timeout > 0 && p.pinggap > 0 {
@@ -277,6 +341,15 @@ func _newPipe(connFn func() (net.Conn, error), option *ClientOption, r2ps bool)
 	return p, nil
 }
 
+func authCmd(username, password string) []string {
+	if password != "" && username == "" {
+		return []string{"AUTH", password}
+	} else if username != "" {
+		return []string{"AUTH", username, password}
+	}
+	return nil
+}
+
 func (p *pipe) background() {
 	atomic.CompareAndSwapInt32(&p.state, 0, 1)
 	p.once.Do(func() { go p._background() })
@@ -369,7 +442,7 @@ func (p *pipe) _backgroundWrite() (err error) {
 			if p.w.Buffered() == 0 {
 				err = p.Error()
 			} else {
-				err = p.w.Flush()
+				err = p.flush()
 			}
 			if err == nil {
 				if atomic.LoadInt32(&p.state) == 1 {
@@ -389,6 +462,7 @@ func (p *pipe) _backgroundWrite() (err error) {
 		for _, cmd := range multi {
 			err = writeCmd(p.w, cmd.Commands())
 		}
+		p.cmds.Add(uint64(len(multi)))
 		if err != nil {
 			if err != ErrClosing { // ignore ErrClosing to allow final QUIT command to be sent
 				return
@@ -425,7 +499,7 @@ func (p *pipe) _backgroundRead() (err error) {
 	}()
 
 	for {
-		if msg, err = readNextMessage(p.r); err != nil {
+		if msg, err = readNextMessage(p.r, p.limits); err != nil {
 			return
 		}
 		if msg.typ == '>' || (r2ps && len(msg.values) != 0 && msg.values[0].string != "pong") {
@@ -455,7 +529,7 @@ func (p *pipe) _backgroundRead() (err error) {
 				}
 			}
 			for ; i < len(msg.values); i++ {
-				if msg.values[i], err = readNextMessage(p.r); err != nil {
+				if msg.values[i], err = readNextMessage(p.r, p.limits); err != nil {
 					return
 				}
 			}
@@ -486,7 +560,7 @@ func (p *pipe) _backgroundRead() (err error) {
 				msgs := msg.values[len(msg.values)-1].values
 				for i, cp := range msgs {
 					ck := cmds.MGetCacheKey(cacheable, i)
-					cp.attrs = cacheMark
+					markCacheHit(&cp)
 					if pttl := msg.values[i].integer; pttl >= 0 {
 						cp.setExpireAt(now.Add(time.Duration(pttl) * time.Millisecond).UnixMilli())
 					}
@@ -496,7 +570,7 @@ func (p *pipe) _backgroundRead() (err error) {
 				ck, cc := cmds.CacheKey(cacheable)
 				ci := len(msg.values) - 1
 				cp := msg.values[ci]
-				cp.attrs = cacheMark
+				markCacheHit(&cp)
 				if pttl := msg.values[ci-1].integer; pttl >= 0 {
 					cp.setExpireAt(now.Add(time.Duration(pttl) * time.Millisecond).UnixMilli())
 				}
@@ -565,13 +639,43 @@ func (p *pipe) backgroundPing() {
 	}
 }
 
+// backgroundReauth re-authenticates the connection with fresh credentials every interval,
+// so that the connection is not dropped by redis after the previous credentials expire.
+// Failures of the fn are retried on the next tick while the previous credentials are still valid,
+// but the connection is closed if the AUTH fails, so that it will be replaced by a new one.
+func (p *pipe) backgroundReauth(interval, timeout time.Duration, fn func(context.Context) (string, string, error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			var err error
+			if username, password, ferr := fn(ctx); ferr == nil {
+				if auth := authCmd(username, password); auth != nil {
+					err = p.Do(ctx, cmds.NewCompleted(auth)).Error()
+				}
+			}
+			cancel()
+			if err != nil {
+				if err != ErrClosing {
+					p._exit(err)
+				}
+				return
+			}
+		case <-p.close:
+			return
+		}
+	}
+}
+
 func (p *pipe) handlePush(values []RedisMessage) (reply bool, unsubscribe bool) {
 	if len(values) < 2 {
+		if len(values) == 1 && p.onPush != nil {
+			p.onPush(p.addr, values[0].string, nil)
+		}
 		return
 	}
-	// TODO: handle other push data
-	// tracking-redir-broken
-	// server-cpu-usage
 	switch values[0].string {
 	case "invalidate":
 		if p.cache != nil {
@@ -629,10 +733,31 @@ func (p *pipe) handlePush(values []RedisMessage) (reply bool, unsubscribe bool)
 			p.pshks.Load().(*pshks).hooks.OnSubscription(PubSubSubscription{Kind: values[0].string, Channel: values[1].string, Count: values[2].integer})
 		}
 		return true, false
+	case "tracking-redir-broken":
+		// the invalidations are lost since the redirection target is gone, so flush the cache and enable the tracking again
+		if p.cache != nil {
+			p.cache.Delete(nil)
+			go p.retrack()
+		}
+		if p.onInvalidations != nil {
+			p.onInvalidations(nil)
+		}
+	default:
+		if p.onPush != nil {
+			p.onPush(p.addr, values[0].string, values[1:])
+		}
 	}
 	return false, false
 }
 
+// retrack re-issues the CLIENT TRACKING after the tracking-redir-broken push,
+// and closes the pipe if it fails, because the cache can't be kept fresh anymore.
+func (p *pipe) retrack() {
+	if err := p.Do(context.Background(), cmds.NewCompleted(p.tracking)).Error(); err != nil && err != ErrClosing {
+		p._exit(err)
+	}
+}
+
 func (p *pipe) _r2pipe() (r2p *pipe) {
 	p.r2mu.Lock()
 	if p.r2pipe != nil {
@@ -750,11 +875,41 @@ func (p *pipe) Info() map[string]RedisMessage {
 	return p.info
 }
 
+// Queued returns the number of the in-flight calls, which is cheaper than Stats().
+// Unlike the Stats().Queued, a DoMulti call is counted once no matter how many commands it has.
+func (p *pipe) Queued() int {
+	return int(atomic.LoadInt32(&p.waits))
+}
+
+func (p *pipe) Stats() (s ConnStats) {
+	s.Commands = p.cmds.Load()
+	s.Flushes = p.flushes.Load()
+	s.BytesRead = p.rbytes.Load()
+	s.BytesWritten = p.wbytes.Load()
+	s.Queued = int64(atomic.LoadInt32(&p.pending))
+	s.Cache.Hits = p.hits.Load()
+	s.Cache.Misses = p.misses.Load()
+	if cache, ok := p.cache.(*lru); ok {
+		s.Cache.Size, s.Cache.Evictions = cache.stats()
+	}
+	return s
+}
+
 func (p *pipe) Do(ctx context.Context, cmd Completed) (resp RedisResult) {
 	if err := ctx.Err(); err != nil {
 		return newErrResult(err)
 	}
 
+	var local bool // the deadline is from the command timeout instead of the caller
+	if _, ok := ctx.Deadline(); !ok {
+		if to := p.cmdTimeout(cmd); to > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, to)
+			defer cancel()
+			local = true
+		}
+	}
+
 	cmds.CompletedCS(cmd).Verify()
 
 	if cmd.IsBlock() {
@@ -772,6 +927,7 @@ func (p *pipe) Do(ctx context.Context, cmd Completed) (resp RedisResult) {
 		}
 	}
 
+	atomic.AddInt32(&p.pending, 1)
 	waits := atomic.AddInt32(&p.waits, 1) // if this is 1, and background worker is not started, no need to queue
 	state := atomic.LoadInt32(&p.state)
 
@@ -788,7 +944,7 @@ func (p *pipe) Do(ctx context.Context, cmd Completed) (resp RedisResult) {
 			goto queue
 		}
 		dl, ok := ctx.Deadline()
-		if !ok && ctx.Done() != nil {
+		if local || (!ok && ctx.Done() != nil) { // the local deadline should not be applied to the connection
 			p.background()
 			goto queue
 		}
@@ -796,6 +952,7 @@ func (p *pipe) Do(ctx context.Context, cmd Completed) (resp RedisResult) {
 	} else {
 		resp = newErrResult(p.Error())
 	}
+	atomic.AddInt32(&p.pending, -1)
 	if left := atomic.AddInt32(&p.waits, -1); state == 0 && waits == 1 && left != 0 {
 		p.background()
 	}
@@ -806,17 +963,20 @@ queue:
 	ch := p.queue.PutOne(cmd)
 	if ctxCh := ctx.Done(); ctxCh == nil {
 		resp = <-ch
+		atomic.AddInt32(&p.pending, -1)
 		atomic.AddInt32(&p.waits, -1)
 		atomic.AddInt32(&p.recvs, 1)
 	} else {
 		select {
 		case resp = <-ch:
+			atomic.AddInt32(&p.pending, -1)
 			atomic.AddInt32(&p.waits, -1)
 			atomic.AddInt32(&p.recvs, 1)
 		case <-ctxCh:
 			resp = newErrResult(ctx.Err())
 			go func() {
 				<-ch
+				atomic.AddInt32(&p.pending, -1)
 				atomic.AddInt32(&p.waits, -1)
 				atomic.AddInt32(&p.recvs, 1)
 			}()
@@ -834,6 +994,16 @@ func (p *pipe) DoMulti(ctx context.Context, multi ...Completed) *redisresults {
 		return resp
 	}
 
+	var local bool // the deadline is from the command timeout instead of the caller
+	if _, ok := ctx.Deadline(); !ok {
+		if to := p.multiTimeout(multi); to > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, to)
+			defer cancel()
+			local = true
+		}
+	}
+
 	cmds.CompletedCS(multi[0]).Verify()
 
 	isOptIn := multi[0].IsOptIn() // len(multi) > 0 should have already been checked by upper layer
@@ -864,6 +1034,8 @@ func (p *pipe) DoMulti(ctx context.Context, multi ...Completed) *redisresults {
 		}
 	}
 
+	chunked := !isBlock && p.batch > 0 && len(multi) > p.batch
+
 	if isBlock {
 		atomic.AddInt32(&p.blcksig, 1)
 		defer func() {
@@ -876,6 +1048,7 @@ func (p *pipe) DoMulti(ctx context.Context, multi ...Completed) *redisresults {
 		}()
 	}
 
+	atomic.AddInt32(&p.pending, int32(len(multi)))
 	waits := atomic.AddInt32(&p.waits, 1) // if this is 1, and background worker is not started, no need to queue
 	state := atomic.LoadInt32(&p.state)
 
@@ -887,12 +1060,12 @@ func (p *pipe) DoMulti(ctx context.Context, multi ...Completed) *redisresults {
 		if waits != 1 {
 			goto queue
 		}
-		if isOptIn || noReply != 0 {
+		if isOptIn || noReply != 0 || chunked {
 			p.background()
 			goto queue
 		}
 		dl, ok := ctx.Deadline()
-		if !ok && ctx.Done() != nil {
+		if local || (!ok && ctx.Done() != nil) { // the local deadline should not be applied to the connection
 			p.background()
 			goto queue
 		}
@@ -903,6 +1076,7 @@ func (p *pipe) DoMulti(ctx context.Context, multi ...Completed) *redisresults {
 			resp.s[i] = err
 		}
 	}
+	atomic.AddInt32(&p.pending, -int32(len(multi)))
 	if left := atomic.AddInt32(&p.waits, -1); state == 0 && waits == 1 && left != 0 {
 		p.background()
 	}
@@ -910,6 +1084,9 @@ func (p *pipe) DoMulti(ctx context.Context, multi ...Completed) *redisresults {
 	return resp
 
 queue:
+	if chunked {
+		return p.queueChunks(ctx, multi, resp)
+	}
 	ch := p.queue.PutMulti(multi)
 	var i int
 	if ctxCh := ctx.Done(); ctxCh == nil {
@@ -925,6 +1102,7 @@ queue:
 			}
 		}
 	}
+	atomic.AddInt32(&p.pending, -int32(len(multi)))
 	atomic.AddInt32(&p.waits, -1)
 	atomic.AddInt32(&p.recvs, 1)
 	return resp
@@ -933,6 +1111,7 @@ abort:
 		for ; i < len(resp.s); i++ {
 			<-ch
 		}
+		atomic.AddInt32(&p.pending, -int32(len(multi)))
 		atomic.AddInt32(&p.waits, -1)
 		atomic.AddInt32(&p.recvs, 1)
 	}(i)
@@ -943,6 +1122,146 @@ abort:
 	return resp
 }
 
+// chunksInFlight is how many chunks of a large DoMulti can be queued at a time.
+const chunksInFlight = 2
+
+// queueChunks queues the multi in chunks of at most p.batch commands, so that commands from other callers can be
+// interleaved between chunks. The next chunk is queued while the replies of the earlier one are read, so the chunks are
+// still written without waiting for a round trip each, but at most chunksInFlight of them are queued at a time.
+func (p *pipe) queueChunks(ctx context.Context, multi []Completed, resp *redisresults) *redisresults {
+	type chunk struct {
+		ch  chan RedisResult
+		end int
+	}
+	var (
+		i, next int
+		queued  = make([]chunk, 0, chunksInFlight)
+		ctxCh   = ctx.Done()
+	)
+	for i < len(resp.s) {
+		for next < len(multi) && len(queued) < chunksInFlight {
+			end := chunkEnd(multi, next, p.batch)
+			queued = append(queued, chunk{ch: p.queue.PutMulti(multi[next:end]), end: end})
+			next = end
+		}
+		for c := queued[0]; i < c.end; i++ {
+			select {
+			case resp.s[i] = <-c.ch:
+			case <-ctxCh:
+				goto abort
+			}
+		}
+		queued = append(queued[:0], queued[1:]...)
+	}
+	atomic.AddInt32(&p.pending, -int32(len(multi)))
+	atomic.AddInt32(&p.waits, -1)
+	atomic.AddInt32(&p.recvs, 1)
+	return resp
+abort:
+	go func(i int, queued []chunk) {
+		for _, c := range queued {
+			for ; i < c.end; i++ {
+				<-c.ch
+			}
+		}
+		atomic.AddInt32(&p.pending, -int32(len(multi)))
+		atomic.AddInt32(&p.waits, -1)
+		atomic.AddInt32(&p.recvs, 1)
+	}(i, queued)
+	err := newErrResult(ctx.Err())
+	for ; i < len(resp.s); i++ {
+		resp.s[i] = err
+	}
+	return resp
+}
+
+// chunkEnd returns the end of the chunk starting at the start, which has at most size commands
+// unless a MULTI/EXEC block is longer, because the block should never be split.
+func chunkEnd(multi []Completed, start, size int) (end int) {
+	tx := false
+	for end = start; end < len(multi) && (tx || end-start < size); end++ {
+		if s := multi[end].Commands(); len(s) != 0 {
+			if strings.EqualFold(s[0], "MULTI") {
+				tx = true
+			} else if strings.EqualFold(s[0], "EXEC") || strings.EqualFold(s[0], "DISCARD") {
+				tx = false
+			}
+		}
+	}
+	return end
+}
+
+func (p *pipe) DoStream(ctx context.Context, cmd Completed) RedisResultStream {
+	cmds.CompletedCS(cmd).Verify()
+
+	if err := ctx.Err(); err != nil {
+		return RedisResultStream{e: err}
+	}
+
+	state := atomic.LoadInt32(&p.state)
+
+	if state == 1 {
+		panic("DoStream with auto pipelining is a bug")
+	}
+
+	if state == 0 {
+		atomic.AddInt32(&p.blcksig, 1)
+		atomic.AddInt32(&p.pending, 1)
+		if waits := atomic.AddInt32(&p.waits, 1); waits != 1 {
+			panic("DoStream with racing is a bug")
+		}
+		if dl, ok := ctx.Deadline(); ok {
+			p.conn.SetDeadline(dl)
+		} else if p.timeout > 0 && !cmd.IsBlock() {
+			p.conn.SetDeadline(time.Now().Add(p.timeout))
+		} else {
+			p.conn.SetDeadline(time.Time{})
+		}
+		_ = writeCmd(p.w, cmd.Commands())
+		p.cmds.Add(1)
+		if err := p.flush(); err != nil {
+			p.error.CompareAndSwap(nil, &errs{error: err})
+			p.conn.Close()
+			p.background() // start the background worker to clean up goroutines
+		} else {
+			return RedisResultStream{w: p, n: 1}
+		}
+		atomic.AddInt32(&p.blcksig, -1)
+		atomic.AddInt32(&p.pending, -1)
+		atomic.AddInt32(&p.waits, -1)
+	}
+	return RedisResultStream{e: p.Error()}
+}
+
+// cmdTimeout returns the timeout of the cmd, or zero if it should not time out.
+// Blocking commands use their server side timeout plus blockingTimeoutMargin instead of the DefaultCommandTimeout.
+func (p *pipe) cmdTimeout(cmd Completed) time.Duration {
+	if to := cmd.Timeout(); to > 0 {
+		return to
+	}
+	if cmd.IsBlock() {
+		if to := cmds.BlockTimeout(cmd); to > 0 {
+			return to + blockingTimeoutMargin
+		}
+		return 0
+	}
+	return p.cmdto
+}
+
+// multiTimeout returns the longest timeout of the multi, or zero if any of them should not time out.
+func (p *pipe) multiTimeout(multi []Completed) (to time.Duration) {
+	for _, cmd := range multi {
+		t := p.cmdTimeout(cmd)
+		if t <= 0 {
+			return 0
+		}
+		if t > to {
+			to = t
+		}
+	}
+	return to
+}
+
 func (p *pipe) syncDo(dl time.Time, dlOk bool, cmd Completed) (resp RedisResult) {
 	if dlOk {
 		p.conn.SetDeadline(dl)
@@ -954,16 +1273,19 @@ func (p *pipe) syncDo(dl time.Time, dlOk bool, cmd Completed) (resp RedisResult)
 
 	var msg RedisMessage
 	err := writeCmd(p.w, cmd.Commands())
+	p.cmds.Add(1)
 	if err == nil {
-		if err = p.w.Flush(); err == nil {
-			msg, err = syncRead(p.r)
+		if err = p.flush(); err == nil {
+			msg, err = syncRead(p.r, p.limits)
 		}
 	}
 	if err != nil {
 		if errors.Is(err, os.ErrDeadlineExceeded) {
 			err = context.DeadlineExceeded
 		}
-		p.error.CompareAndSwap(nil, &errs{error: err})
+		if !p.error.CompareAndSwap(nil, &errs{error: err}) && p.error.Load() == errAbandoned {
+			err = ErrClosing
+		}
 		p.conn.Close()
 		p.background() // start the background worker to clean up goroutines
 	}
@@ -990,11 +1312,12 @@ process:
 	for _, cmd := range multi {
 		_ = writeCmd(p.w, cmd.Commands())
 	}
-	if err = p.w.Flush(); err != nil {
+	p.cmds.Add(uint64(len(multi)))
+	if err = p.flush(); err != nil {
 		goto abort
 	}
 	for i := 0; i < len(resp); i++ {
-		if msg, err = syncRead(p.r); err != nil {
+		if msg, err = syncRead(p.r, p.limits); err != nil {
 			goto abort
 		}
 		resp[i] = newResult(msg, err)
@@ -1004,7 +1327,9 @@ abort:
 	if errors.Is(err, os.ErrDeadlineExceeded) {
 		err = context.DeadlineExceeded
 	}
-	p.error.CompareAndSwap(nil, &errs{error: err})
+	if !p.error.CompareAndSwap(nil, &errs{error: err}) && p.error.Load() == errAbandoned {
+		err = ErrClosing
+	}
 	p.conn.Close()
 	p.background() // start the background worker to clean up goroutines
 	for i := 0; i < len(resp); i++ {
@@ -1013,9 +1338,14 @@ abort:
 	return resp
 }
 
-func syncRead(r *bufio.Reader) (m RedisMessage, err error) {
+func (p *pipe) flush() error {
+	p.flushes.Add(1)
+	return p.w.Flush()
+}
+
+func syncRead(r *bufio.Reader, l protoLimits) (m RedisMessage, err error) {
 next:
-	if m, err = readNextMessage(r); err != nil {
+	if m, err = readNextMessage(r, l); err != nil {
 		return m, err
 	}
 	if m.typ == '>' {
@@ -1037,10 +1367,13 @@ func (p *pipe) DoCache(ctx context.Context, cmd Cacheable, ttl time.Duration) Re
 	ck, cc := cmds.CacheKey(cmd)
 	now := time.Now()
 	if v, entry := p.cache.Flight(ck, cc, ttl, now); v.typ != 0 {
+		p.hits.Add(1)
 		return newResult(v, nil)
 	} else if entry != nil {
+		p.hits.Add(1)
 		return newResult(entry.Wait(ctx))
 	}
+	p.misses.Add(1)
 	resp := p.DoMulti(
 		ctx,
 		cmds.OptInCmd,
@@ -1074,6 +1407,7 @@ func (p *pipe) doCacheMGet(ctx context.Context, cmd Cacheable, ttl time.Duration
 	defer entriesp.Put(entries)
 	var now = time.Now()
 	var rewrite cmds.Arbitrary
+	var misses int
 	for i, key := range commands[1 : keys+1] {
 		v, entry := p.cache.Flight(key, mgetcc, ttl, now)
 		if v.typ != 0 { // cache hit for one key
@@ -1091,7 +1425,10 @@ func (p *pipe) doCacheMGet(ctx context.Context, cmd Cacheable, ttl time.Duration
 			rewrite = builder.Arbitrary(commands[0])
 		}
 		rewrite = rewrite.Args(key)
+		misses++
 	}
+	p.hits.Add(uint64(keys - misses))
+	p.misses.Add(uint64(misses))
 
 	var partial []RedisMessage
 	if !rewrite.IsZero() {
@@ -1176,6 +1513,7 @@ func (p *pipe) DoMultiCache(ctx context.Context, multi ...CacheableTTL) *redisre
 	entries := entriesp.Get(len(multi), len(multi))
 	defer entriesp.Put(entries)
 	var missing []Completed
+	var misses int
 	now := time.Now()
 	for _, ct := range multi {
 		if ct.Cmd.IsMGet() {
@@ -1188,6 +1526,7 @@ func (p *pipe) DoMultiCache(ctx context.Context, multi ...CacheableTTL) *redisre
 			ct := multi[i]
 			ck, _ := cmds.CacheKey(ct.Cmd)
 			missing = append(missing, cmds.OptInCmd, cmds.MultiCmd, cmds.NewCompleted([]string{"PTTL", ck}), Completed(ct.Cmd), cmds.ExecCmd)
+			misses++
 		}
 	} else {
 		for i, ct := range multi {
@@ -1202,9 +1541,13 @@ func (p *pipe) DoMultiCache(ctx context.Context, multi ...CacheableTTL) *redisre
 				continue
 			}
 			missing = append(missing, cmds.OptInCmd, cmds.MultiCmd, cmds.NewCompleted([]string{"PTTL", ck}), Completed(ct.Cmd), cmds.ExecCmd)
+			misses++
 		}
 	}
 
+	p.misses.Add(uint64(misses))
+	p.hits.Add(uint64(len(multi) - misses))
+
 	var resp *redisresults
 	if len(missing) > 0 {
 		resp = p.DoMulti(ctx, missing...)
@@ -1281,6 +1624,30 @@ func (p *pipe) Close() {
 	p.r2mu.Unlock()
 }
 
+// CloseWithContext is the same as Close, but it only waits for the pending commands to finish until the ctx is done.
+// After that, the connection is closed forcibly and the pending commands are failed with ErrClosing.
+// It returns the number of the commands abandoned in this way.
+func (p *pipe) CloseWithContext(ctx context.Context) (abandoned int) {
+	p.error.CompareAndSwap(nil, errClosing)
+	done := make(chan struct{})
+	go func() {
+		p.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return 0
+	case <-ctx.Done():
+	}
+	abandoned = int(atomic.LoadInt32(&p.pending))
+	p.error.CompareAndSwap(errClosing, errAbandoned) // make the in-flight sync call fail with ErrClosing as well
+	if p.conn != nil {
+		p.conn.Close()
+	}
+	<-done
+	return abandoned
+}
+
 type pshks struct {
 	hooks PubSubHooks
 	close chan error
@@ -1319,7 +1686,11 @@ const (
 	panicmgetcsc = "MGET and JSON.MGET in DoMultiCache are not implemented, use DoCache instead"
 )
 
+// blockingTimeoutMargin is added to the server side timeout of blocking commands to tolerate the network latency.
+const blockingTimeoutMargin = time.Second
+
 var cacheMark = &(RedisMessage{})
 var errClosing = &errs{error: ErrClosing}
+var errAbandoned = &errs{error: ErrClosing}
 
 type errs struct{ error }
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"io"
 	"net"
+	"reflect"
 	"runtime"
 	"strconv"
 	"strings"
@@ -29,7 +30,7 @@ type redisMock struct {
 }
 
 func (r *redisMock) ReadMessage() (RedisMessage, error) {
-	m, err := readNextMessage(r.buf)
+	m, err := readNextMessage(r.buf, noLimits)
 	if err != nil {
 		return RedisMessage{}, err
 	}
@@ -96,15 +97,21 @@ func (r *redisMock) Close() {
 }
 
 func write(o io.Writer, m RedisMessage) (err error) {
+	if m.attrs != nil {
+		_ = write(o, *m.attrs)
+	}
 	_, err = o.Write([]byte{m.typ})
 	switch m.typ {
 	case '+', '-', '_':
 		_, err = o.Write(append([]byte(m.string), '\r', '\n'))
 	case ':':
 		_, err = o.Write(append([]byte(strconv.FormatInt(m.integer, 10)), '\r', '\n'))
-	case '%', '>', '*':
+	case '$':
+		_, err = o.Write(append([]byte(strconv.Itoa(len(m.string))), '\r', '\n'))
+		_, err = o.Write(append([]byte(m.string), '\r', '\n'))
+	case '%', '|', '>', '*':
 		size := int64(len(m.values))
-		if m.typ == '%' {
+		if m.typ == '%' || m.typ == '|' {
 			if size%2 != 0 {
 				panic("map message with wrong value length")
 			}
@@ -146,7 +153,7 @@ func setup(t *testing.T, option ClientOption) (*pipe, *redisMock, func(), func()
 				ReplyString("OK")
 		}
 	}()
-	p, err := newPipe(func() (net.Conn, error) { return n1, nil }, &option)
+	p, err := newPipe("", func() (net.Conn, error) { return n1, nil }, &option)
 	if err != nil {
 		t.Fatalf("pipe setup failed: %v", err)
 	}
@@ -202,7 +209,7 @@ func TestNewPipe(t *testing.T) {
 			mock.Expect("CLIENT", "NO-TOUCH", "ON").
 				ReplyString("OK")
 		}()
-		p, err := newPipe(func() (net.Conn, error) { return n1, nil }, &ClientOption{
+		p, err := newPipe("", func() (net.Conn, error) { return n1, nil }, &ClientOption{
 			SelectDB:      1,
 			Password:      "pa",
 			ClientName:    "cn",
@@ -219,6 +226,35 @@ func TestNewPipe(t *testing.T) {
 		n1.Close()
 		n2.Close()
 	})
+	t.Run("ReplicaOnly with READONLY", func(t *testing.T) {
+		n1, n2 := net.Pipe()
+		mock := &redisMock{buf: bufio.NewReader(n2), conn: n2}
+		go func() {
+			mock.Expect("HELLO", "3").
+				Reply(RedisMessage{
+					typ: '%',
+					values: []RedisMessage{
+						{typ: '+', string: "proto"},
+						{typ: ':', integer: 3},
+					},
+				})
+			mock.Expect("CLIENT", "TRACKING", "ON", "OPTIN").
+				ReplyString("OK")
+			mock.Expect("READONLY").
+				ReplyString("OK")
+		}()
+		p, err := newPipe("", func() (net.Conn, error) { return n1, nil }, &ClientOption{
+			ReplicaOnly: true,
+		})
+		if err != nil {
+			t.Fatalf("pipe setup failed: %v", err)
+		}
+		go func() { mock.Expect("QUIT").ReplyString("OK") }()
+		p.Close()
+		mock.Close()
+		n1.Close()
+		n2.Close()
+	})
 	t.Run("AlwaysRESP2", func(t *testing.T) {
 		n1, n2 := net.Pipe()
 		mock := &redisMock{buf: bufio.NewReader(n2), conn: n2}
@@ -236,7 +272,7 @@ func TestNewPipe(t *testing.T) {
 			mock.Expect("CLIENT", "NO-TOUCH", "ON").
 				ReplyString("OK")
 		}()
-		p, err := newPipe(func() (net.Conn, error) { return n1, nil }, &ClientOption{
+		p, err := newPipe("", func() (net.Conn, error) { return n1, nil }, &ClientOption{
 			SelectDB:      1,
 			Password:      "pa",
 			ClientName:    "cn",
@@ -272,7 +308,7 @@ func TestNewPipe(t *testing.T) {
 			mock.Expect("SELECT", "1").
 				ReplyString("OK")
 		}()
-		p, err := newPipe(func() (net.Conn, error) { return n1, nil }, &ClientOption{
+		p, err := newPipe("", func() (net.Conn, error) { return n1, nil }, &ClientOption{
 			SelectDB:   1,
 			Username:   "ua",
 			Password:   "pa",
@@ -287,6 +323,174 @@ func TestNewPipe(t *testing.T) {
 		n1.Close()
 		n2.Close()
 	})
+	t.Run("Auth with AuthCredentialsFn", func(t *testing.T) {
+		n1, n2 := net.Pipe()
+		mock := &redisMock{buf: bufio.NewReader(n2), conn: n2}
+		go func() {
+			mock.Expect("HELLO", "3", "AUTH", "ua2", "pa2").
+				Reply(RedisMessage{
+					typ: '%',
+					values: []RedisMessage{
+						{typ: '+', string: "proto"},
+						{typ: ':', integer: 3},
+					},
+				})
+			mock.Expect("CLIENT", "TRACKING", "ON", "OPTIN").
+				ReplyString("OK")
+		}()
+		p, err := newPipe("", func() (net.Conn, error) { return n1, nil }, &ClientOption{
+			Username: "ua",
+			Password: "pa",
+			AuthCredentialsFn: func(ctx context.Context) (string, string, error) {
+				return "ua2", "pa2", nil
+			},
+		})
+		if err != nil {
+			t.Fatalf("pipe setup failed: %v", err)
+		}
+		go func() { mock.Expect("QUIT").ReplyString("OK") }()
+		p.Close()
+		mock.Close()
+		n1.Close()
+		n2.Close()
+	})
+	t.Run("Auth with AuthCredentialsFn Error", func(t *testing.T) {
+		n1, n2 := net.Pipe()
+		mock := &redisMock{buf: bufio.NewReader(n2), conn: n2}
+		go func() { mock.Expect("QUIT").ReplyString("OK") }()
+		e := errors.New("credentials")
+		if _, err := newPipe("", func() (net.Conn, error) { return n1, nil }, &ClientOption{
+			AuthCredentialsFn: func(ctx context.Context) (string, string, error) {
+				return "", "", e
+			},
+		}); err != e {
+			t.Fatalf("pipe setup should failed with %v, but got %v", e, err)
+		}
+		mock.Close()
+		n1.Close()
+		n2.Close()
+	})
+	t.Run("Reauth with AuthCredentialsRefreshInterval", func(t *testing.T) {
+		n1, n2 := net.Pipe()
+		mock := &redisMock{buf: bufio.NewReader(n2), conn: n2}
+		var calls int32
+		p, err := func() (*pipe, error) {
+			go func() {
+				mock.Expect("HELLO", "3", "AUTH", "default", "token1").
+					Reply(RedisMessage{
+						typ: '%',
+						values: []RedisMessage{
+							{typ: '+', string: "proto"},
+							{typ: ':', integer: 3},
+						},
+					})
+				mock.Expect("CLIENT", "TRACKING", "ON", "OPTIN").
+					ReplyString("OK")
+			}()
+			return newPipe("", func() (net.Conn, error) { return n1, nil }, &ClientOption{
+				AuthCredentialsFn: func(ctx context.Context) (string, string, error) {
+					return "", "token" + strconv.Itoa(int(atomic.AddInt32(&calls, 1))), nil
+				},
+				AuthCredentialsRefreshInterval: 10 * time.Millisecond,
+			})
+		}()
+		if err != nil {
+			t.Fatalf("pipe setup failed: %v", err)
+		}
+		mock.Expect("AUTH", "token2").ReplyString("OK")
+		go func() {
+			for { // there may be more AUTH before QUIT
+				m, err := mock.ReadMessage()
+				if err != nil {
+					return
+				}
+				mock.Expect().ReplyString("OK")
+				if m.values[0].string == "QUIT" {
+					return
+				}
+			}
+		}()
+		p.Close()
+		mock.Close()
+		n1.Close()
+		n2.Close()
+	})
+	t.Run("Reauth Error closes the pipe", func(t *testing.T) {
+		n1, n2 := net.Pipe()
+		mock := &redisMock{buf: bufio.NewReader(n2), conn: n2}
+		p, err := func() (*pipe, error) {
+			go func() {
+				mock.Expect("HELLO", "3", "AUTH", "default", "token").
+					Reply(RedisMessage{
+						typ: '%',
+						values: []RedisMessage{
+							{typ: '+', string: "proto"},
+							{typ: ':', integer: 3},
+						},
+					})
+				mock.Expect("CLIENT", "TRACKING", "ON", "OPTIN").
+					ReplyString("OK")
+			}()
+			return newPipe("", func() (net.Conn, error) { return n1, nil }, &ClientOption{
+				AuthCredentialsFn: func(ctx context.Context) (string, string, error) {
+					return "", "token", nil
+				},
+				AuthCredentialsRefreshInterval: 10 * time.Millisecond,
+			})
+		}()
+		if err != nil {
+			t.Fatalf("pipe setup failed: %v", err)
+		}
+		mock.Expect("AUTH", "token").ReplyError("WRONGPASS invalid username-password pair")
+		for p.Error() == nil {
+			time.Sleep(time.Millisecond)
+		}
+		if err := p.Error(); err == nil || !strings.Contains(err.Error(), "WRONGPASS") {
+			t.Fatalf("unexpected err %v", err)
+		}
+		p.Close()
+		mock.Close()
+		n1.Close()
+		n2.Close()
+	})
+	t.Run("No Reauth on pooled pipes", func(t *testing.T) {
+		n1, n2 := net.Pipe()
+		mock := &redisMock{t: t, buf: bufio.NewReader(n2), conn: n2}
+		var calls int32
+		p, err := func() (*pipe, error) {
+			go func() {
+				mock.Expect("HELLO", "3", "AUTH", "default", "token").
+					Reply(RedisMessage{
+						typ: '%',
+						values: []RedisMessage{
+							{typ: '+', string: "proto"},
+							{typ: ':', integer: 3},
+						},
+					})
+				mock.Expect("CLIENT", "TRACKING", "ON", "OPTIN").
+					ReplyString("OK")
+			}()
+			return newPipeNoReauth("", func() (net.Conn, error) { return n1, nil }, &ClientOption{
+				AuthCredentialsFn: func(ctx context.Context) (string, string, error) {
+					atomic.AddInt32(&calls, 1)
+					return "", "token", nil
+				},
+				AuthCredentialsRefreshInterval: time.Millisecond,
+			})
+		}()
+		if err != nil {
+			t.Fatalf("pipe setup failed: %v", err)
+		}
+		time.Sleep(20 * time.Millisecond)
+		if c := atomic.LoadInt32(&calls); c != 1 {
+			t.Fatalf("unexpected calls to AuthCredentialsFn %v", c)
+		}
+		go func() { mock.Expect("QUIT").ReplyString("OK") }()
+		p.Close()
+		mock.Close()
+		n1.Close()
+		n2.Close()
+	})
 	t.Run("With ClientSideTrackingOptions", func(t *testing.T) {
 		n1, n2 := net.Pipe()
 		mock := &redisMock{buf: bufio.NewReader(n2), conn: n2}
@@ -302,7 +506,7 @@ func TestNewPipe(t *testing.T) {
 			mock.Expect("CLIENT", "TRACKING", "ON", "OPTIN", "NOLOOP").
 				ReplyString("OK")
 		}()
-		p, err := newPipe(func() (net.Conn, error) { return n1, nil }, &ClientOption{
+		p, err := newPipe("", func() (net.Conn, error) { return n1, nil }, &ClientOption{
 			ClientTrackingOptions: []string{"OPTIN", "NOLOOP"},
 		})
 		if err != nil {
@@ -318,7 +522,7 @@ func TestNewPipe(t *testing.T) {
 		n1, n2 := net.Pipe()
 		n1.Close()
 		n2.Close()
-		if _, err := newPipe(func() (net.Conn, error) { return n1, nil }, &ClientOption{}); err != io.ErrClosedPipe {
+		if _, err := newPipe("", func() (net.Conn, error) { return n1, nil }, &ClientOption{}); err != io.ErrClosedPipe {
 			t.Fatalf("pipe setup should failed with io.ErrClosedPipe, but got %v", err)
 		}
 	})
@@ -326,6 +530,29 @@ func TestNewPipe(t *testing.T) {
 
 func TestNewRESP2Pipe(t *testing.T) {
 	defer ShouldNotLeaked(SetupLeakDetection())
+	t.Run("Auth with AuthCredentialsFn", func(t *testing.T) {
+		n1, n2 := net.Pipe()
+		mock := &redisMock{buf: bufio.NewReader(n2), conn: n2}
+		go func() {
+			mock.Expect("AUTH", "ua", "pa").
+				ReplyString("OK")
+		}()
+		p, err := newPipe("", func() (net.Conn, error) { return n1, nil }, &ClientOption{
+			AuthCredentialsFn: func(ctx context.Context) (string, string, error) {
+				return "ua", "pa", nil
+			},
+			AlwaysRESP2:  true,
+			DisableCache: true,
+		})
+		if err != nil {
+			t.Fatalf("pipe setup failed: %v", err)
+		}
+		go func() { mock.Expect("QUIT").ReplyString("OK") }()
+		p.Close()
+		mock.Close()
+		n1.Close()
+		n2.Close()
+	})
 	t.Run("Without DisableCache", func(t *testing.T) {
 		n1, n2 := net.Pipe()
 		mock := &redisMock{buf: bufio.NewReader(n2), conn: n2}
@@ -336,7 +563,7 @@ func TestNewRESP2Pipe(t *testing.T) {
 				ReplyError("ERR unknown subcommand or wrong number of arguments for 'TRACKING'. Try CLIENT HELP")
 			mock.Expect("QUIT").ReplyString("OK")
 		}()
-		if _, err := newPipe(func() (net.Conn, error) { return n1, nil }, &ClientOption{}); !errors.Is(err, ErrNoCache) {
+		if _, err := newPipe("", func() (net.Conn, error) { return n1, nil }, &ClientOption{}); !errors.Is(err, ErrNoCache) {
 			t.Fatalf("unexpected err: %v", err)
 		}
 		mock.Close()
@@ -353,7 +580,7 @@ func TestNewRESP2Pipe(t *testing.T) {
 				ReplyString("OK")
 			mock.Expect("QUIT").ReplyString("OK")
 		}()
-		if _, err := newPipe(func() (net.Conn, error) { return n1, nil }, &ClientOption{}); !errors.Is(err, ErrNoCache) {
+		if _, err := newPipe("", func() (net.Conn, error) { return n1, nil }, &ClientOption{}); !errors.Is(err, ErrNoCache) {
 			t.Fatalf("unexpected err: %v", err)
 		}
 		mock.Close()
@@ -372,7 +599,7 @@ func TestNewRESP2Pipe(t *testing.T) {
 					{typ: ':', integer: 2},
 				}})
 		}()
-		p, err := newPipe(func() (net.Conn, error) { return n1, nil }, &ClientOption{
+		p, err := newPipe("", func() (net.Conn, error) { return n1, nil }, &ClientOption{
 			DisableCache: true,
 		})
 		if err != nil {
@@ -402,7 +629,7 @@ func TestNewRESP2Pipe(t *testing.T) {
 			mock.Expect("SELECT", "1").
 				ReplyString("OK")
 		}()
-		p, err := newPipe(func() (net.Conn, error) { return n1, nil }, &ClientOption{
+		p, err := newPipe("", func() (net.Conn, error) { return n1, nil }, &ClientOption{
 			SelectDB:     1,
 			Password:     "pa",
 			ClientName:   "cn",
@@ -435,7 +662,7 @@ func TestNewRESP2Pipe(t *testing.T) {
 			mock.Expect("SELECT", "1").
 				ReplyString("OK")
 		}()
-		p, err := newPipe(func() (net.Conn, error) { return n1, nil }, &ClientOption{
+		p, err := newPipe("", func() (net.Conn, error) { return n1, nil }, &ClientOption{
 			SelectDB:     1,
 			Username:     "ua",
 			Password:     "pa",
@@ -465,7 +692,7 @@ func TestNewRESP2Pipe(t *testing.T) {
 			n1.Close()
 			n2.Close()
 		}()
-		_, err := newPipe(func() (net.Conn, error) { return n1, nil }, &ClientOption{
+		_, err := newPipe("", func() (net.Conn, error) { return n1, nil }, &ClientOption{
 			SelectDB:     1,
 			Username:     "ua",
 			Password:     "pa",
@@ -568,6 +795,99 @@ func TestWriteMultiPipelineFlush(t *testing.T) {
 	}
 }
 
+func TestPipeMaxPipelineBatch(t *testing.T) {
+	defer ShouldNotLeaked(SetupLeakDetection())
+	build := func(n int) []Completed {
+		multi := make([]Completed, n)
+		for i := range multi {
+			multi[i] = cmds.NewCompleted([]string{"GET", strconv.Itoa(i)})
+		}
+		return multi
+	}
+	t.Run("Chunks In Flight", func(t *testing.T) {
+		p, mock, cancel, _ := setup(t, ClientOption{MaxPipelineBatch: 2})
+		defer cancel()
+		go func() {
+			// the second chunk is written before the replies of the first one
+			mock.Expect("GET", "0").Expect("GET", "1").Expect("GET", "2").Expect("GET", "3").ReplyString("0", "1")
+			// the third chunk is written only after the replies of the first one are read
+			mock.Expect("GET", "4").ReplyString("2", "3", "4")
+		}()
+		for i, resp := range p.DoMulti(context.Background(), build(5)...).s {
+			if v, err := resp.ToString(); err != nil || v != strconv.Itoa(i) {
+				t.Fatalf("unexpected response %v %v", v, err)
+			}
+		}
+	})
+	t.Run("Context Canceled", func(t *testing.T) {
+		p, mock, cancel, _ := setup(t, ClientOption{MaxPipelineBatch: 2})
+		defer cancel()
+		ctx, ctxCancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			mock.Expect("GET", "0").Expect("GET", "1").Expect("GET", "2").Expect("GET", "3")
+			ctxCancel()
+			<-done
+			// the replies of the queued chunks are still drained, and the third chunk is never written
+			mock.Expect().ReplyString("0", "1", "2", "3")
+			mock.Expect("GET", "a").ReplyString("a")
+		}()
+		for _, resp := range p.DoMulti(ctx, build(5)...).s {
+			if err := resp.Error(); err != context.Canceled {
+				t.Fatalf("unexpected response %v", err)
+			}
+		}
+		close(done)
+		if v, err := p.Do(context.Background(), cmds.NewCompleted([]string{"GET", "a"})).ToString(); err != nil || v != "a" {
+			t.Fatalf("unexpected response %v %v", v, err)
+		}
+	})
+	t.Run("Chunk End", func(t *testing.T) {
+		build := func(commands ...string) []Completed {
+			multi := make([]Completed, len(commands))
+			for i, c := range commands {
+				multi[i] = cmds.NewCompleted(strings.Split(c, " "))
+			}
+			return multi
+		}
+		for _, c := range []struct {
+			multi  []Completed
+			chunks []int
+		}{
+			{multi: build("GET 0", "GET 1"), chunks: []int{2}},
+			{multi: build("GET 0", "GET 1", "GET 2", "GET 3", "GET 4"), chunks: []int{2, 2, 1}},
+			{multi: build("GET 0", "MULTI 1", "SET 2", "SET 3", "EXEC 4", "GET 5"), chunks: []int{5, 1}},
+			{multi: build("GET 0", "GET 1", "multi 2", "SET 3", "SET 4", "discard 5"), chunks: []int{2, 4}},
+		} {
+			var chunks []int
+			for i, j := 0, 0; i < len(c.multi); i = j {
+				j = chunkEnd(c.multi, i, 2)
+				chunks = append(chunks, j-i)
+			}
+			if !reflect.DeepEqual(chunks, c.chunks) {
+				t.Fatalf("unexpected chunks %v", chunks)
+			}
+		}
+	})
+	t.Run("Not For Pool", func(t *testing.T) {
+		n1, n2 := net.Pipe()
+		defer n2.Close()
+		go func() {
+			mock := &redisMock{t: t, buf: bufio.NewReader(n2), conn: n2}
+			mock.Expect("HELLO", "3").Reply(RedisMessage{typ: '%', values: []RedisMessage{{typ: '+', string: "proto"}, {typ: ':', integer: 3}}})
+			mock.Expect("QUIT").ReplyString("OK")
+		}()
+		p, err := newPipeNoReauth("", func() (net.Conn, error) { return n1, nil }, &ClientOption{DisableCache: true, MaxPipelineBatch: 2})
+		if err != nil {
+			t.Fatalf("unexpected err %v", err)
+		}
+		if p.batch != 0 {
+			t.Fatalf("unexpected batch %v", p.batch)
+		}
+		p.Close()
+	})
+}
+
 func TestNoReplyExceedRingSize(t *testing.T) {
 	defer ShouldNotLeaked(SetupLeakDetection())
 	p, mock, cancel, _ := setup(t, ClientOption{})
@@ -636,6 +956,295 @@ func TestResponseSequenceWithPushMessageInjected(t *testing.T) {
 	wg.Wait()
 }
 
+func TestOnPush(t *testing.T) {
+	defer ShouldNotLeaked(SetupLeakDetection())
+	pushes := make(chan []RedisMessage, 2)
+	p, mock, cancel, _ := setup(t, ClientOption{
+		OnPush: func(addr string, kind string, values []RedisMessage) {
+			pushes <- append([]RedisMessage{{typ: '+', string: kind}}, values...)
+		},
+	})
+	defer cancel()
+
+	go func() {
+		mock.Expect().Reply(RedisMessage{typ: '>', values: []RedisMessage{{typ: '+', string: "server-cpu-usage"}, {typ: ':', integer: 1}}})
+		mock.Expect().Reply(RedisMessage{typ: '>', values: []RedisMessage{{typ: '+', string: "single"}}})
+		mock.Expect("GET", "a").ReplyString("OK")
+	}()
+	if v := <-pushes; len(v) != 2 || v[0].string != "server-cpu-usage" || v[1].integer != 1 {
+		t.Fatalf("unexpected push %v", v)
+	}
+	if v := <-pushes; len(v) != 1 || v[0].string != "single" {
+		t.Fatalf("unexpected push %v", v)
+	}
+	ExpectOK(t, p.Do(context.Background(), cmds.NewCompleted([]string{"GET", "a"})))
+}
+
+func TestTrackingRedirBroken(t *testing.T) {
+	defer ShouldNotLeaked(SetupLeakDetection())
+	expectCSC := func(mock *redisMock, resp string) {
+		mock.Expect("CLIENT", "CACHING", "YES").
+			Expect("MULTI").
+			Expect("PTTL", "a").
+			Expect("GET", "a").
+			Expect("EXEC").
+			ReplyString("OK").
+			ReplyString("OK").
+			ReplyString("OK").
+			ReplyString("OK").
+			Reply(RedisMessage{typ: '*', values: []RedisMessage{
+				{typ: ':', integer: -1},
+				{typ: '+', string: resp},
+			}})
+	}
+	redirBroken := func(mock *redisMock) {
+		mock.Expect().Reply(RedisMessage{typ: '>', values: []RedisMessage{{typ: '+', string: "tracking-redir-broken"}, {typ: ':', integer: 3}}})
+	}
+
+	t.Run("Retrack", func(t *testing.T) {
+		var invalidated int32
+		p, mock, cancel, _ := setup(t, ClientOption{OnInvalidations: func(messages []RedisMessage) {
+			if messages == nil {
+				atomic.AddInt32(&invalidated, 1)
+			}
+		}})
+		defer cancel()
+
+		go expectCSC(mock, "1")
+		if v, _ := p.DoCache(context.Background(), Cacheable(cmds.NewCompleted([]string{"GET", "a"})), 10*time.Second).ToString(); v != "1" {
+			t.Fatalf("unexpected result %v", v)
+		}
+		redirBroken(mock)
+		mock.Expect("CLIENT", "TRACKING", "ON", "OPTIN").ReplyString("OK")
+		go expectCSC(mock, "2")
+		if v, _ := p.DoCache(context.Background(), Cacheable(cmds.NewCompleted([]string{"GET", "a"})), 10*time.Second).ToString(); v != "2" {
+			t.Fatalf("unexpected result %v", v)
+		}
+		if atomic.LoadInt32(&invalidated) != 1 {
+			t.Fatalf("OnInvalidations should be called")
+		}
+	})
+
+	t.Run("Retrack Failed", func(t *testing.T) {
+		p, mock, _, closeConn := setup(t, ClientOption{})
+		defer closeConn()
+
+		p.background()
+		redirBroken(mock)
+		mock.Expect("CLIENT", "TRACKING", "ON", "OPTIN").ReplyError("ERR redirect")
+		for p.Error() == nil {
+			t.Log("waiting for the pipe to be closed")
+			time.Sleep(10 * time.Millisecond)
+		}
+		if err := p.Error(); !strings.Contains(err.Error(), "redirect") {
+			t.Fatalf("unexpected error %v", p.Error())
+		}
+	})
+}
+
+func TestPipeStats(t *testing.T) {
+	defer ShouldNotLeaked(SetupLeakDetection())
+	p, mock, cancel, _ := setup(t, ClientOption{})
+	defer cancel()
+
+	before := p.Stats()
+	go func() {
+		mock.Expect("GET", "a").ReplyString("b")
+		mock.Expect("CLIENT", "CACHING", "YES").
+			Expect("MULTI").
+			Expect("PTTL", "a").
+			Expect("GET", "a").
+			Expect("EXEC").
+			ReplyString("OK").
+			ReplyString("OK").
+			ReplyString("OK").
+			ReplyString("OK").
+			Reply(RedisMessage{typ: '*', values: []RedisMessage{
+				{typ: ':', integer: -1},
+				{typ: '+', string: "b"},
+			}})
+	}()
+	if v, _ := p.Do(context.Background(), cmds.NewCompleted([]string{"GET", "a"})).ToString(); v != "b" {
+		t.Fatalf("unexpected result %v", v)
+	}
+	for i := 0; i < 2; i++ {
+		if v, _ := p.DoCache(context.Background(), Cacheable(cmds.NewCompleted([]string{"GET", "a"})), 10*time.Second).ToString(); v != "b" {
+			t.Fatalf("unexpected cached result %v", v)
+		}
+	}
+	after := p.Stats()
+	if after.Commands-before.Commands != 6 || after.Flushes <= before.Flushes || after.Queued != 0 || p.Queued() != 0 ||
+		after.BytesRead <= before.BytesRead || after.BytesWritten <= before.BytesWritten {
+		t.Fatalf("unexpected stats %v %v", before, after)
+	}
+	if after.Cache.Hits != 1 || after.Cache.Misses != 1 || after.Cache.Size <= 0 {
+		t.Fatalf("unexpected cache stats %v", after.Cache)
+	}
+}
+
+func TestPipeCommandTimeout(t *testing.T) {
+	defer ShouldNotLeaked(SetupLeakDetection())
+	p, mock, cancel, _ := setup(t, ClientOption{DefaultCommandTimeout: 50 * time.Millisecond})
+	defer cancel()
+
+	replied := make(chan struct{})
+	go func() {
+		mock.Expect("GET", "a")
+		time.Sleep(100 * time.Millisecond)
+		mock.Expect().ReplyString("late")
+		close(replied)
+		mock.Expect("GET", "b").ReplyString("b")
+		mock.Expect("GET", "c").Expect("GET", "d")
+		time.Sleep(100 * time.Millisecond)
+		mock.Expect().ReplyString("c").ReplyString("d")
+		mock.Expect("GET", "e").ReplyString("e")
+	}()
+	if err := p.Do(context.Background(), cmds.NewCompleted([]string{"GET", "a"})).Error(); err != context.DeadlineExceeded {
+		t.Fatalf("unexpected err %v", err)
+	}
+	<-replied
+	if v, err := p.Do(context.Background(), cmds.NewCompleted([]string{"GET", "b"})).ToString(); err != nil || v != "b" {
+		t.Fatalf("unexpected result %v %v", v, err)
+	}
+	for _, resp := range p.DoMulti(context.Background(), cmds.NewCompleted([]string{"GET", "c"}), cmds.NewCompleted([]string{"GET", "d"})).s {
+		if err := resp.Error(); err != context.DeadlineExceeded {
+			t.Fatalf("unexpected err %v", err)
+		}
+	}
+	if v, err := p.Do(context.Background(), cmds.NewCompleted([]string{"GET", "e"}).WithTimeout(time.Second)).ToString(); err != nil || v != "e" {
+		t.Fatalf("unexpected result %v %v", v, err)
+	}
+	if err := p.Error(); err != nil {
+		t.Fatalf("unexpected pipe err %v", err)
+	}
+}
+
+func TestPipeCloseWithContext(t *testing.T) {
+	defer ShouldNotLeaked(SetupLeakDetection())
+	t.Run("Drained", func(t *testing.T) {
+		p, mock, _, _ := setup(t, ClientOption{})
+		received := make(chan struct{})
+		go func() {
+			mock.Expect("GET", "a")
+			close(received)
+			time.Sleep(50 * time.Millisecond)
+			mock.Expect().ReplyString("a")
+			mock.Expect("QUIT").ReplyString("OK")
+			mock.Close()
+		}()
+		result := make(chan RedisResult, 1)
+		go func() {
+			result <- p.Do(context.Background(), cmds.NewCompleted([]string{"GET", "a"}))
+		}()
+		<-received
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if n := p.CloseWithContext(ctx); n != 0 {
+			t.Fatalf("unexpected abandoned %v", n)
+		}
+		if v, err := (<-result).ToString(); err != nil || v != "a" {
+			t.Fatalf("unexpected result %v %v", v, err)
+		}
+		if err := p.Do(context.Background(), cmds.NewCompleted([]string{"GET", "b"})).Error(); err != ErrClosing {
+			t.Fatalf("unexpected err %v", err)
+		}
+	})
+	for _, c := range []struct {
+		name   string
+		option ClientOption
+		keys   []string
+	}{
+		{name: "Abandoned Sync", keys: []string{"a"}},
+		{name: "Abandoned Pipelined", option: ClientOption{AlwaysPipelining: true}, keys: []string{"a", "b"}},
+	} {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			p, mock, _, _ := setup(t, c.option)
+			received := make(chan struct{})
+			go func() {
+				for _, k := range c.keys {
+					mock.Expect("GET", k)
+					received <- struct{}{}
+				}
+			}()
+			result := make(chan RedisResult, len(c.keys))
+			for _, k := range c.keys {
+				go func(k string) {
+					result <- p.Do(context.Background(), cmds.NewCompleted([]string{"GET", k}))
+				}(k)
+				<-received
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			if n := p.CloseWithContext(ctx); n != len(c.keys) {
+				t.Fatalf("unexpected abandoned %v", n)
+			}
+			for range c.keys {
+				if err := (<-result).Error(); err != ErrClosing {
+					t.Fatalf("unexpected err %v", err)
+				}
+			}
+			if err := p.Do(context.Background(), cmds.NewCompleted([]string{"GET", "c"})).Error(); err != ErrClosing {
+				t.Fatalf("unexpected err %v", err)
+			}
+			mock.Close()
+		})
+	}
+	t.Run("Abandoned DoMulti", func(t *testing.T) {
+		p, mock, _, _ := setup(t, ClientOption{AlwaysPipelining: true})
+		received := make(chan struct{})
+		go func() {
+			mock.Expect("GET", "a").Expect("GET", "b").Expect("GET", "c")
+			close(received)
+		}()
+		result := make(chan *redisresults, 1)
+		go func() {
+			result <- p.DoMulti(context.Background(),
+				cmds.NewCompleted([]string{"GET", "a"}),
+				cmds.NewCompleted([]string{"GET", "b"}),
+				cmds.NewCompleted([]string{"GET", "c"}))
+		}()
+		<-received
+		if s := p.Stats(); s.Queued != 3 || p.Queued() != 1 {
+			t.Fatalf("unexpected queued %v %v", s.Queued, p.Queued())
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		if n := p.CloseWithContext(ctx); n != 3 {
+			t.Fatalf("unexpected abandoned %v", n)
+		}
+		for _, r := range (<-result).s {
+			if err := r.Error(); err != ErrClosing {
+				t.Fatalf("unexpected err %v", err)
+			}
+		}
+		mock.Close()
+	})
+}
+
+func TestPipeCmdTimeout(t *testing.T) {
+	p := &pipe{cmdto: time.Second}
+	for _, c := range []struct {
+		multi []Completed
+		exp   time.Duration
+	}{
+		{multi: []Completed{cmds.NewCompleted([]string{"GET", "a"})}, exp: time.Second},
+		{multi: []Completed{cmds.NewCompleted([]string{"GET", "a"}).WithTimeout(time.Millisecond)}, exp: time.Millisecond},
+		{multi: []Completed{cmds.NewBlockingCompleted([]string{"BLPOP", "a", "2"})}, exp: 2*time.Second + blockingTimeoutMargin},
+		{multi: []Completed{cmds.NewBlockingCompleted([]string{"BLPOP", "a", "0"})}, exp: 0},
+		{multi: []Completed{cmds.NewBlockingCompleted([]string{"BLPOP", "a", "0"}).WithTimeout(time.Millisecond)}, exp: time.Millisecond},
+		{multi: []Completed{cmds.NewCompleted([]string{"GET", "a"}), cmds.NewBlockingCompleted([]string{"BLPOP", "a", "2"})}, exp: 2*time.Second + blockingTimeoutMargin},
+		{multi: []Completed{cmds.NewCompleted([]string{"GET", "a"}), cmds.NewBlockingCompleted([]string{"MIGRATE"})}, exp: 0},
+	} {
+		if to := p.multiTimeout(c.multi); to != c.exp {
+			t.Fatalf("unexpected timeout %v of %v", to, c.multi)
+		}
+	}
+	if to := (&pipe{}).cmdTimeout(cmds.NewCompleted([]string{"GET", "a"})); to != 0 {
+		t.Fatalf("unexpected timeout %v", to)
+	}
+}
+
 func TestClientSideCaching(t *testing.T) {
 	defer ShouldNotLeaked(SetupLeakDetection())
 	p, mock, cancel, _ := setup(t, ClientOption{})
@@ -726,6 +1335,41 @@ func TestClientSideCaching(t *testing.T) {
 	}
 }
 
+func TestClientSideCachingAttributes(t *testing.T) {
+	defer ShouldNotLeaked(SetupLeakDetection())
+	p, mock, cancel, _ := setup(t, ClientOption{})
+	defer cancel()
+
+	attrs := RedisMessage{typ: '|', values: []RedisMessage{
+		{typ: '+', string: "key-popularity"},
+		{typ: ':', integer: 1},
+	}}
+	go func() {
+		mock.Expect("CLIENT", "CACHING", "YES").
+			Expect("MULTI").
+			Expect("PTTL", "a").
+			Expect("GET", "a").
+			Expect("EXEC").
+			ReplyString("OK").
+			ReplyString("OK").
+			ReplyString("OK").
+			ReplyString("OK").
+			Reply(RedisMessage{typ: '*', values: []RedisMessage{
+				{typ: ':', integer: -1},
+				{typ: '+', string: "1", attrs: &attrs},
+			}})
+	}()
+	for i, hit := range []bool{false, true} {
+		v, err := p.DoCache(context.Background(), Cacheable(cmds.NewCompleted([]string{"GET", "a"})), 10*time.Second).ToMessage()
+		if err != nil || v.string != "1" || v.IsCacheHit() != hit {
+			t.Fatalf("unexpected cached result %d %v %v", i, v, err)
+		}
+		if a := v.Attributes(); len(a) != 1 || a["key-popularity"].integer != 1 {
+			t.Fatalf("unexpected attributes %d %v", i, a)
+		}
+	}
+}
+
 func TestClientSideCachingExecAbort(t *testing.T) {
 	defer ShouldNotLeaked(SetupLeakDetection())
 	p, mock, cancel, _ := setup(t, ClientOption{})
@@ -2705,6 +3349,25 @@ func TestExitOnWriteError(t *testing.T) {
 	}
 }
 
+func TestExitOnProtocolLimits(t *testing.T) {
+	p, mock, _, closeConn := setup(t, ClientOption{MaxBulkLen: 3})
+	defer closeConn()
+
+	go func() {
+		mock.Expect("GET", "a").Reply(RedisMessage{typ: '$', string: "abcd"})
+	}()
+	var pe *ProtocolError
+	for i := 0; i < 2; i++ {
+		if err := p.Do(context.Background(), cmds.NewCompleted([]string{"GET", "a"})).Error(); !errors.As(err, &pe) || pe.Limit != limitBulkLen || pe.Value != 4 || pe.Max != 3 {
+			t.Fatalf("unexpected result, expected protocol err, got %v", err)
+		}
+	}
+	for atomic.LoadInt32(&p.state) != 4 {
+		t.Log("wait the pipe to be closed")
+		time.Sleep(time.Millisecond * 100)
+	}
+}
+
 func TestExitOnPubSubSubscribeWriteError(t *testing.T) {
 	p, _, _, closeConn := setup(t, ClientOption{})
 
@@ -1,63 +1,156 @@
 package rueidis
 
-import "sync"
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
 
-func newPool(cap int, dead wire, makeFn func() wire) *pool {
+func newPool(cap int, dead wire, cleanup, lifetime time.Duration, minSize int, makeFn func() wire) *pool {
 	if cap <= 0 {
 		cap = DefaultPoolSize
 	}
+	if minSize > cap {
+		minSize = cap
+	}
 
-	return &pool{
-		size: 0,
-		dead: dead,
-		make: makeFn,
-		list: make([]wire, 0, cap),
-		cond: sync.NewCond(&sync.Mutex{}),
+	p := &pool{
+		size:     0,
+		dead:     dead,
+		make:     makeFn,
+		list:     make([]wire, 0, cap),
+		idle:     make([]time.Time, 0, cap),
+		cond:     sync.NewCond(&sync.Mutex{}),
+		cleanup:  cleanup,
+		lifetime: lifetime,
+		minSize:  minSize,
+	}
+	if lifetime > 0 {
+		p.born = make(map[wire]time.Time)
+	}
+	if cleanup > 0 || lifetime > 0 || minSize > 0 {
+		p.stop = make(chan struct{}) // the reaper is started by the first Acquire
 	}
+	return p
 }
 
 type pool struct {
-	dead wire
-	cond *sync.Cond
-	make func() wire
-	list []wire
-	size int
-	down bool
+	dead     wire
+	cond     *sync.Cond
+	make     func() wire
+	born     map[wire]time.Time
+	stop     chan struct{}
+	list     []wire
+	idle     []time.Time
+	size     int
+	wait     int
+	minSize  int
+	cleanup  time.Duration
+	lifetime time.Duration
+	down     bool
+	used     bool
 }
 
-func (p *pool) Acquire() (v wire) {
+// Acquire waits for an idle wire or a free slot to make a new one if the pool is exhausted.
+// It gives up waiting and returns an error wrapping both ErrPoolExhausted and the ctx.Err() when the ctx is done.
+func (p *pool) Acquire(ctx context.Context) (v wire, err error) {
+	var expired []wire
+	var stop chan struct{}
 	p.cond.L.Lock()
+	if !p.used && !p.down {
+		p.used = true
+		if p.stop != nil {
+			go p.reaper(reapInterval(p.cleanup, p.lifetime))
+		}
+	}
+retry:
 	for len(p.list) == 0 && p.size == cap(p.list) && !p.down {
+		if err = ctx.Err(); err != nil {
+			p.cond.L.Unlock()
+			err = fmt.Errorf("%w: %w", ErrPoolExhausted, err)
+			goto done
+		}
+		if stop == nil && ctx.Done() != nil {
+			stop = make(chan struct{})
+			go p.wakeup(ctx, stop)
+		}
+		p.wait++
 		p.cond.Wait()
+		p.wait--
 	}
 	if p.down {
 		v = p.dead
 	} else if len(p.list) == 0 {
 		p.size++
 		v = p.make()
+		if p.born != nil {
+			p.born[v] = time.Now()
+		}
 	} else {
 		i := len(p.list) - 1
 		v = p.list[i]
+		p.list[i] = nil
 		p.list = p.list[:i]
+		if p.expired(v, p.idle[i], time.Now()) {
+			p.idle = p.idle[:i]
+			p.size--
+			delete(p.born, v)
+			expired = append(expired, v)
+			goto retry
+		}
+		p.idle = p.idle[:i]
 	}
 	p.cond.L.Unlock()
-	return v
+done:
+	if stop != nil {
+		close(stop)
+	}
+	for _, w := range expired {
+		w.Close()
+	}
+	return v, err
+}
+
+// wakeup wakes up the waiters when the ctx is done, so that they can give up waiting.
+func (p *pool) wakeup(ctx context.Context, stop chan struct{}) {
+	select {
+	case <-ctx.Done():
+		p.cond.L.Lock()
+		p.cond.Broadcast()
+		p.cond.L.Unlock()
+	case <-stop:
+	}
 }
 
 func (p *pool) Store(v wire) {
 	p.cond.L.Lock()
-	if !p.down && v.Error() == nil {
+	if now := time.Now(); !p.down && v.Error() == nil && !p.expired(v, now, now) {
 		p.list = append(p.list, v)
+		p.idle = append(p.idle, now)
 	} else {
 		p.size--
+		delete(p.born, v)
 		v.Close()
 	}
 	p.cond.L.Unlock()
 	p.cond.Signal()
 }
 
+func (p *pool) Stats() (s PoolStats) {
+	p.cond.L.Lock()
+	s.Acquired = p.size - len(p.list)
+	s.Idle = len(p.list)
+	s.Waiting = p.wait
+	p.cond.L.Unlock()
+	return s
+}
+
 func (p *pool) Close() {
 	p.cond.L.Lock()
+	if !p.down && p.stop != nil {
+		close(p.stop)
+	}
 	p.down = true
 	for _, w := range p.list {
 		w.Close()
@@ -65,3 +158,100 @@ func (p *pool) Close() {
 	p.cond.L.Unlock()
 	p.cond.Broadcast()
 }
+
+// expired reports whether the wire has been idle longer than the cleanup or alive longer than the lifetime.
+func (p *pool) expired(w wire, idle, now time.Time) bool {
+	if p.cleanup > 0 && now.Sub(idle) >= p.cleanup {
+		return true
+	}
+	if p.lifetime > 0 {
+		if born, ok := p.born[w]; ok && now.Sub(born) >= p.lifetime {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *pool) reaper(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		p.reap()
+		select {
+		case <-ticker.C:
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// reap closes the expired idle wires and then makes new ones until there are minSize idle wires.
+// It stops making new ones once a dial fails, including when the circuit breaker rejects it, until the next interval.
+func (p *pool) reap() {
+	var expired []wire
+	now := time.Now()
+	p.cond.L.Lock()
+	if p.down {
+		p.cond.L.Unlock()
+		return
+	}
+	n := 0
+	for i, w := range p.list {
+		if p.expired(w, p.idle[i], now) {
+			p.size--
+			delete(p.born, w)
+			expired = append(expired, w)
+		} else {
+			p.list[n] = w
+			p.idle[n] = p.idle[i]
+			n++
+		}
+	}
+	for i := n; i < len(p.list); i++ {
+		p.list[i] = nil
+	}
+	p.list = p.list[:n]
+	p.idle = p.idle[:n]
+	fill := p.minSize - n
+	if free := cap(p.list) - p.size; fill > free {
+		fill = free
+	}
+	if fill > 0 {
+		p.size += fill // reserve the slots before making the wires without holding the lock
+	}
+	p.cond.L.Unlock()
+
+	for _, w := range expired {
+		w.Close()
+	}
+	for ; fill > 0; fill-- {
+		w := p.make()
+		if w.Error() != nil {
+			p.Store(w)
+			p.cond.L.Lock()
+			p.size -= fill - 1 // release the rest of the reserved slots
+			p.cond.L.Unlock()
+			p.cond.Broadcast()
+			break
+		}
+		if p.born != nil {
+			p.cond.L.Lock()
+			p.born[w] = time.Now()
+			p.cond.L.Unlock()
+		}
+		p.Store(w)
+	}
+}
+
+func reapInterval(cleanup, lifetime time.Duration) time.Duration {
+	interval := time.Second
+	for _, d := range []time.Duration{cleanup, lifetime} {
+		if d > 0 && d/2 < interval {
+			interval = d / 2
+		}
+	}
+	if interval <= 0 {
+		interval = time.Millisecond
+	}
+	return interval
+}
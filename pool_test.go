@@ -1,20 +1,27 @@
 package rueidis
 
 import (
+	"context"
 	"errors"
 	"runtime"
 	"sync/atomic"
 	"testing"
+	"time"
 )
 
 var dead = deadFn()
 
+func acquire(p *pool) wire {
+	w, _ := p.Acquire(context.Background())
+	return w
+}
+
 //gocyclo:ignore
 func TestPool(t *testing.T) {
 	defer ShouldNotLeaked(SetupLeakDetection())
 	setup := func(size int) (*pool, *int32) {
 		var count int32
-		return newPool(size, dead, func() wire {
+		return newPool(size, dead, 0, 0, 0, func() wire {
 			atomic.AddInt32(&count, 1)
 			closed := false
 			return &mockWire{
@@ -32,7 +39,7 @@ func TestPool(t *testing.T) {
 	}
 
 	t.Run("DefaultPoolSize", func(t *testing.T) {
-		p := newPool(0, dead, func() wire { return nil })
+		p := newPool(0, dead, 0, 0, 0, func() wire { return nil })
 		if cap(p.list) == 0 {
 			t.Fatalf("DefaultPoolSize is not applied")
 		}
@@ -41,7 +48,7 @@ func TestPool(t *testing.T) {
 	t.Run("Reuse", func(t *testing.T) {
 		pool, count := setup(100)
 		for i := 0; i < 1000; i++ {
-			pool.Store(pool.Acquire())
+			pool.Store(acquire(pool))
 		}
 		if atomic.LoadInt32(count) != 1 {
 			t.Fatalf("pool does not reuse connection")
@@ -52,7 +59,7 @@ func TestPool(t *testing.T) {
 		conn := make([]wire, 100)
 		pool, count := setup(len(conn))
 		for i := 0; i < len(conn); i++ {
-			conn[i] = pool.Acquire()
+			conn[i] = acquire(pool)
 		}
 		if atomic.LoadInt32(count) != 100 {
 			t.Fatalf("unexpected acquire count")
@@ -63,22 +70,86 @@ func TestPool(t *testing.T) {
 			}
 		}()
 		for i := 0; i < len(conn); i++ {
-			pool.Acquire()
+			acquire(pool)
 		}
 		if atomic.LoadInt32(count) > 100 {
 			t.Fatalf("pool must not exceed the size limit")
 		}
 	})
 
+	t.Run("Stats", func(t *testing.T) {
+		pool, _ := setup(1)
+		w := acquire(pool)
+		if s := pool.Stats(); s != (PoolStats{Acquired: 1}) {
+			t.Fatalf("unexpected stats %v", s)
+		}
+		done := make(chan struct{})
+		go func() {
+			pool.Store(acquire(pool))
+			close(done)
+		}()
+		for pool.Stats().Waiting != 1 {
+			runtime.Gosched()
+		}
+		pool.Store(w)
+		<-done
+		if s := pool.Stats(); s != (PoolStats{Idle: 1}) {
+			t.Fatalf("unexpected stats %v", s)
+		}
+	})
+
+	t.Run("Acquire Ctx", func(t *testing.T) {
+		pool, count := setup(1)
+		w := acquire(pool)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		if v, err := pool.Acquire(ctx); v != nil || !errors.Is(err, ErrPoolExhausted) || !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("unexpected acquire %v %v", v, err)
+		}
+		if v, err := pool.Acquire(ctx); v != nil || !errors.Is(err, ErrPoolExhausted) {
+			t.Fatalf("unexpected acquire %v %v", v, err)
+		}
+		if s := pool.Stats(); s != (PoolStats{Acquired: 1}) {
+			t.Fatalf("unexpected stats %v", s)
+		}
+		pool.Store(w)
+		if v, err := pool.Acquire(ctx); v != w || err != nil { // no need to wait
+			t.Fatalf("unexpected acquire %v %v", v, err)
+		}
+		ctx2, cancel2 := context.WithCancel(context.Background())
+		defer cancel2()
+		go func() {
+			for pool.Stats().Waiting != 1 {
+				runtime.Gosched()
+			}
+			pool.Store(w)
+		}()
+		if v, err := pool.Acquire(ctx2); v != w || err != nil {
+			t.Fatalf("unexpected acquire %v %v", v, err)
+		}
+		go func() {
+			for pool.Stats().Waiting != 1 {
+				runtime.Gosched()
+			}
+			cancel2()
+		}()
+		if v, err := pool.Acquire(ctx2); v != nil || !errors.Is(err, ErrPoolExhausted) || !errors.Is(err, context.Canceled) {
+			t.Fatalf("unexpected acquire %v %v", v, err)
+		}
+		if atomic.LoadInt32(count) != 1 {
+			t.Fatalf("unexpected count %v", atomic.LoadInt32(count))
+		}
+	})
+
 	t.Run("NoShare", func(t *testing.T) {
 		conn := make([]wire, 100)
 		pool, _ := setup(len(conn))
 		for i := 0; i < len(conn); i++ {
-			w := pool.Acquire()
+			w := acquire(pool)
 			go pool.Store(w)
 		}
 		for i := 0; i < len(conn); i++ {
-			conn[i] = pool.Acquire()
+			conn[i] = acquire(pool)
 		}
 		for i := 0; i < len(conn); i++ {
 			for j := i + 1; j < len(conn); j++ {
@@ -91,8 +162,8 @@ func TestPool(t *testing.T) {
 
 	t.Run("Close", func(t *testing.T) {
 		pool, count := setup(2)
-		w1 := pool.Acquire()
-		w2 := pool.Acquire()
+		w1 := acquire(pool)
+		w2 := acquire(pool)
 		if w1.Error() != nil {
 			t.Fatalf("unexpected err %v", w1.Error())
 		}
@@ -108,7 +179,7 @@ func TestPool(t *testing.T) {
 			t.Fatalf("pool does not close existing wire after Close()")
 		}
 		for i := 0; i < 100; i++ {
-			if rw := pool.Acquire(); rw != dead {
+			if rw := acquire(pool); rw != dead {
 				t.Fatalf("pool does not return the dead wire after Close()")
 			}
 		}
@@ -120,12 +191,12 @@ func TestPool(t *testing.T) {
 
 	t.Run("Close Empty", func(t *testing.T) {
 		pool, count := setup(2)
-		w1 := pool.Acquire()
+		w1 := acquire(pool)
 		if w1.Error() != nil {
 			t.Fatalf("unexpected err %v", w1.Error())
 		}
 		pool.Close()
-		w2 := pool.Acquire()
+		w2 := acquire(pool)
 		if w2.Error() != ErrClosing {
 			t.Fatalf("pool does not close wire after Close()")
 		}
@@ -133,7 +204,7 @@ func TestPool(t *testing.T) {
 			t.Fatalf("pool should not make new wire")
 		}
 		for i := 0; i < 100; i++ {
-			if rw := pool.Acquire(); rw != dead {
+			if rw := acquire(pool); rw != dead {
 				t.Fatalf("pool does not return the dead wire after Close()")
 			}
 		}
@@ -145,7 +216,7 @@ func TestPool(t *testing.T) {
 
 	t.Run("Close Waiting", func(t *testing.T) {
 		pool, count := setup(1)
-		w1 := pool.Acquire()
+		w1 := acquire(pool)
 		if w1.Error() != nil {
 			t.Fatalf("unexpected err %v", w1.Error())
 		}
@@ -153,7 +224,7 @@ func TestPool(t *testing.T) {
 		for i := 0; i < 100; i++ {
 			go func() {
 				atomic.AddInt64(&pending, 1)
-				if rw := pool.Acquire(); rw != dead {
+				if rw := acquire(pool); rw != dead {
 					t.Errorf("pool does not return the dead wire after Close()")
 				}
 				atomic.AddInt64(&pending, -1)
@@ -179,7 +250,7 @@ func TestPool(t *testing.T) {
 func TestPoolError(t *testing.T) {
 	setup := func(size int) (*pool, *int32) {
 		var count int32
-		return newPool(size, dead, func() wire {
+		return newPool(size, dead, 0, 0, 0, func() wire {
 			w := &pipe{}
 			w.pshks.Store(emptypshks)
 			c := atomic.AddInt32(&count, 1)
@@ -194,7 +265,7 @@ func TestPoolError(t *testing.T) {
 		conn := make([]wire, 100)
 		pool, count := setup(len(conn))
 		for i := 0; i < len(conn); i++ {
-			conn[i] = pool.Acquire()
+			conn[i] = acquire(pool)
 		}
 		if atomic.LoadInt32(count) != int32(len(conn)) {
 			t.Fatalf("unexpected acquire count")
@@ -203,10 +274,144 @@ func TestPoolError(t *testing.T) {
 			pool.Store(conn[i])
 		}
 		for i := 0; i < len(conn); i++ {
-			conn[i] = pool.Acquire()
+			conn[i] = acquire(pool)
 		}
 		if atomic.LoadInt32(count) != int32(len(conn)+len(conn)/2) {
 			t.Fatalf("unexpected acquire count")
 		}
 	})
 }
+
+func TestPoolReaper(t *testing.T) {
+	defer ShouldNotLeaked(SetupLeakDetection())
+	setup := func(size int, cleanup, lifetime time.Duration, minSize int) (*pool, *int32, *int32) {
+		var count, closed int32
+		return newPool(size, dead, cleanup, lifetime, minSize, func() wire {
+			atomic.AddInt32(&count, 1)
+			var c int32
+			return &mockWire{
+				CloseFn: func() {
+					if atomic.CompareAndSwapInt32(&c, 0, 1) {
+						atomic.AddInt32(&closed, 1)
+					}
+				},
+				ErrorFn: func() error {
+					if atomic.LoadInt32(&c) == 1 {
+						return ErrClosing
+					}
+					return nil
+				},
+			}
+		}), &count, &closed
+	}
+
+	t.Run("Cleanup", func(t *testing.T) {
+		pool, count, closed := setup(10, 20*time.Millisecond, 0, 0)
+		defer pool.Close()
+		w1, w2 := acquire(pool), acquire(pool)
+		pool.Store(w1)
+		pool.Store(w2)
+		for atomic.LoadInt32(closed) != 2 {
+			time.Sleep(time.Millisecond)
+		}
+		if s := pool.Stats(); s != (PoolStats{}) {
+			t.Fatalf("unexpected stats %v", s)
+		}
+		if w := acquire(pool); w == w1 || w == w2 || atomic.LoadInt32(count) != 3 {
+			t.Fatalf("unexpected wire %v", w)
+		}
+	})
+
+	t.Run("Cleanup On Acquire", func(t *testing.T) {
+		pool, count, closed := setup(10, time.Hour, 0, 0)
+		defer pool.Close()
+		w1 := acquire(pool)
+		pool.Store(w1)
+		pool.idle[0] = time.Now().Add(-time.Hour)
+		if w := acquire(pool); w == w1 || atomic.LoadInt32(count) != 2 || atomic.LoadInt32(closed) != 1 {
+			t.Fatalf("unexpected wire %v", w)
+		}
+	})
+
+	t.Run("MaxLifetime", func(t *testing.T) {
+		pool, count, closed := setup(10, 0, 30*time.Millisecond, 0)
+		defer pool.Close()
+		w1 := acquire(pool)
+		pool.Store(w1)
+		if w := acquire(pool); w != w1 {
+			t.Fatalf("unexpected wire %v", w)
+		}
+		time.Sleep(30 * time.Millisecond)
+		pool.Store(w1)
+		if atomic.LoadInt32(closed) != 1 || w1.Error() != ErrClosing || len(pool.born) != 0 {
+			t.Fatalf("expired wire is not closed")
+		}
+		if w := acquire(pool); w == w1 || atomic.LoadInt32(count) != 2 {
+			t.Fatalf("unexpected wire %v", w)
+		}
+	})
+
+	t.Run("MinSize", func(t *testing.T) {
+		pool, count, closed := setup(3, 20*time.Millisecond, 0, 5)
+		defer pool.Close()
+		time.Sleep(30 * time.Millisecond)
+		if atomic.LoadInt32(count) != 0 { // nothing is dialed before the pool is used
+			t.Fatalf("unexpected count %v", atomic.LoadInt32(count))
+		}
+		pool.Store(acquire(pool))
+		for pool.Stats().Idle != 3 {
+			time.Sleep(time.Millisecond)
+		}
+		for atomic.LoadInt32(closed) < 3 { // the idle wires are replaced after the cleanup
+			time.Sleep(time.Millisecond)
+		}
+		for pool.Stats().Idle != 3 {
+			time.Sleep(time.Millisecond)
+		}
+		if atomic.LoadInt32(count) < 6 {
+			t.Fatalf("unexpected count %v", atomic.LoadInt32(count))
+		}
+	})
+
+	t.Run("MinSize Dial Failure", func(t *testing.T) {
+		var count int32
+		pool := newPool(3, dead, 0, 0, 3, func() wire {
+			atomic.AddInt32(&count, 1)
+			return &mockWire{ErrorFn: func() error { return errors.New("dial") }}
+		})
+		pool.used = true
+		pool.reap()
+		if c := atomic.LoadInt32(&count); c != 1 {
+			t.Fatalf("unexpected count %v", c)
+		}
+		if s := pool.Stats(); s != (PoolStats{}) || pool.size != 0 {
+			t.Fatalf("unexpected stats %v %v", s, pool.size)
+		}
+		pool.Close()
+	})
+
+	t.Run("Close", func(t *testing.T) {
+		pool, _, _ := setup(1, time.Millisecond, time.Millisecond, 1)
+		pool.Close()
+		pool.Close()
+		pool.reap()
+		if w := acquire(pool); w != dead {
+			t.Fatalf("unexpected wire %v", w)
+		}
+	})
+
+	t.Run("Interval", func(t *testing.T) {
+		for _, c := range []struct {
+			cleanup, lifetime, exp time.Duration
+		}{
+			{exp: time.Second},
+			{cleanup: time.Minute, exp: time.Second},
+			{cleanup: time.Second, lifetime: 100 * time.Millisecond, exp: 50 * time.Millisecond},
+			{cleanup: time.Nanosecond, exp: time.Millisecond},
+		} {
+			if v := reapInterval(c.cleanup, c.lifetime); v != c.exp {
+				t.Fatalf("unexpected interval %v %v", c, v)
+			}
+		}
+	})
+}
@@ -2,8 +2,11 @@ package rueidis
 
 import (
 	"bufio"
+	"bytes"
 	"errors"
+	"fmt"
 	"io"
+	"math"
 	"strconv"
 	"strings"
 )
@@ -11,6 +14,64 @@ import (
 var errChunked = errors.New("unbounded redis message")
 var errOldNull = errors.New("RESP2 null")
 
+// ProtocolError is returned when a reply declares a negative length, or a length or a nesting depth exceeding
+// ClientOption.MaxBulkLen, ClientOption.MaxAggregateLen or ClientOption.MaxNestingDepth.
+// The connection receiving such a reply can't be reused and is closed.
+type ProtocolError struct {
+	// Limit is one of "bulk length", "aggregate length" or "nesting depth".
+	Limit string
+	Value int64
+	Max   int64
+}
+
+func (e *ProtocolError) Error() string {
+	return fmt.Sprintf("received redis reply with %s %d out of limit %d", e.Limit, e.Value, e.Max)
+}
+
+const (
+	limitBulkLen      = "bulk length"
+	limitAggregateLen = "aggregate length"
+	limitNestingDepth = "nesting depth"
+)
+
+// The declared lengths larger than these are not preallocated at once, but grown along with the received data,
+// so that a broken or malicious server can't make us allocate huge memory with a few bytes.
+const (
+	maxPreallocBulk  = 1 << 20
+	maxPreallocElems = 1 << 14
+)
+
+// protoLimits bounds the replies accepted by readers. The level is the nesting depth of the aggregate being read.
+type protoLimits struct {
+	bulk  int64
+	elems int64
+	depth int
+	level int
+}
+
+var noLimits = protoLimits{bulk: math.MaxInt64, elems: math.MaxInt64, depth: math.MaxInt}
+
+func newProtoLimits(option *ClientOption) protoLimits {
+	l := noLimits
+	if option.MaxBulkLen > 0 {
+		l.bulk = int64(option.MaxBulkLen)
+	}
+	if option.MaxAggregateLen > 0 {
+		l.elems = int64(option.MaxAggregateLen)
+	}
+	if option.MaxNestingDepth > 0 {
+		l.depth = option.MaxNestingDepth
+	}
+	return l
+}
+
+func (l *protoLimits) enter() error {
+	if l.level++; l.level > l.depth {
+		return &ProtocolError{Limit: limitNestingDepth, Value: int64(l.level), Max: int64(l.depth)}
+	}
+	return nil
+}
+
 const (
 	typeBlobString     = byte('$')
 	typeSimpleString   = byte('+')
@@ -32,7 +93,7 @@ const (
 
 var typeNames = make(map[byte]string, 16)
 
-type reader func(i *bufio.Reader) (RedisMessage, error)
+type reader func(i *bufio.Reader, l protoLimits) (RedisMessage, error)
 
 var readers = [256]reader{}
 
@@ -72,27 +133,33 @@ func init() {
 	typeNames[typeEnd] = "null"
 }
 
-func readSimpleString(i *bufio.Reader) (m RedisMessage, err error) {
+func readSimpleString(i *bufio.Reader, _ protoLimits) (m RedisMessage, err error) {
 	m.string, err = readS(i)
 	return
 }
 
-func readBlobString(i *bufio.Reader) (m RedisMessage, err error) {
-	m.string, err = readB(i)
+func readBlobString(i *bufio.Reader, l protoLimits) (m RedisMessage, err error) {
+	m.string, err = readB(i, l)
 	if err == errChunked {
+		var total int64
 		sb := strings.Builder{}
 		for {
-			if _, err = i.Discard(1); err != nil { // discard the ';'
+			if err = readChunkPrefix(i); err != nil {
 				return RedisMessage{}, err
 			}
-			length, err := readI(i)
+			length, err := readChunkLength(i)
 			if err != nil {
 				return RedisMessage{}, err
 			}
 			if length == 0 {
 				return RedisMessage{string: sb.String()}, nil
 			}
-			sb.Grow(int(length))
+			if total += length; total > l.bulk {
+				return RedisMessage{}, &ProtocolError{Limit: limitBulkLen, Value: total, Max: l.bulk}
+			}
+			if length <= maxPreallocBulk {
+				sb.Grow(int(length))
+			}
 			if _, err = io.CopyN(&sb, i, length); err != nil {
 				return RedisMessage{}, err
 			}
@@ -104,12 +171,31 @@ func readBlobString(i *bufio.Reader) (m RedisMessage, err error) {
 	return
 }
 
-func readInteger(i *bufio.Reader) (m RedisMessage, err error) {
+func readChunkPrefix(i *bufio.Reader) error {
+	c, err := i.ReadByte()
+	if err != nil {
+		return err
+	}
+	if c != ';' {
+		return errors.New(unexpectedChunkByte + strconv.Itoa(int(c)))
+	}
+	return nil
+}
+
+func readChunkLength(i *bufio.Reader) (int64, error) {
+	length, err := readI(i)
+	if err == nil && length < 0 {
+		err = errors.New(unexpectedChunkLen + strconv.FormatInt(length, 10))
+	}
+	return length, err
+}
+
+func readInteger(i *bufio.Reader, _ protoLimits) (m RedisMessage, err error) {
 	m.integer, err = readI(i)
 	return
 }
 
-func readBoolean(i *bufio.Reader) (m RedisMessage, err error) {
+func readBoolean(i *bufio.Reader, _ protoLimits) (m RedisMessage, err error) {
 	b, err := i.ReadByte()
 	if err != nil {
 		return RedisMessage{}, err
@@ -121,30 +207,35 @@ func readBoolean(i *bufio.Reader) (m RedisMessage, err error) {
 	return
 }
 
-func readNull(i *bufio.Reader) (m RedisMessage, err error) {
+func readNull(i *bufio.Reader, _ protoLimits) (m RedisMessage, err error) {
 	_, err = i.Discard(2)
 	return
 }
 
-func readArray(i *bufio.Reader) (m RedisMessage, err error) {
+func readArray(i *bufio.Reader, l protoLimits) (m RedisMessage, err error) {
 	length, err := readI(i)
 	if err == nil {
 		if length == -1 {
 			return m, errOldNull
 		}
-		m.values, err = readA(i, length)
+		m.values, err = readA(i, l, length)
 	} else if err == errChunked {
-		m.values, err = readE(i)
+		m.values, err = readE(i, l)
 	}
 	return m, err
 }
 
-func readMap(i *bufio.Reader) (m RedisMessage, err error) {
+func readMap(i *bufio.Reader, l protoLimits) (m RedisMessage, err error) {
 	length, err := readI(i)
 	if err == nil {
-		m.values, err = readA(i, length*2)
+		if length > math.MaxInt64/2 { // avoid overflowing
+			length = math.MaxInt64 / 2
+		}
+		m.values, err = readA(i, l, length*2)
 	} else if err == errChunked {
-		m.values, err = readE(i)
+		if m.values, err = readE(i, l); err == nil && len(m.values)%2 != 0 {
+			err = errors.New(unexpectedOddMap)
+		}
 	}
 	return m, err
 }
@@ -192,7 +283,7 @@ func readI(i *bufio.Reader) (int64, error) {
 	}
 }
 
-func readB(i *bufio.Reader) (string, error) {
+func readB(i *bufio.Reader, l protoLimits) (string, error) {
 	length, err := readI(i)
 	if err != nil {
 		return "", err
@@ -200,9 +291,21 @@ func readB(i *bufio.Reader) (string, error) {
 	if length == -1 {
 		return "", errOldNull
 	}
-	bs := make([]byte, length)
-	if _, err = io.ReadFull(i, bs); err != nil {
-		return "", err
+	if length < 0 || length > l.bulk {
+		return "", &ProtocolError{Limit: limitBulkLen, Value: length, Max: l.bulk}
+	}
+	var bs []byte
+	if length > maxPreallocBulk {
+		b := bytes.NewBuffer(make([]byte, 0, maxPreallocBulk))
+		if _, err = io.CopyN(b, i, length); err != nil {
+			return "", err
+		}
+		bs = b.Bytes()
+	} else {
+		bs = make([]byte, length)
+		if _, err = io.ReadFull(i, bs); err != nil {
+			return "", err
+		}
 	}
 	if _, err = i.Discard(2); err != nil {
 		return "", err
@@ -210,26 +313,44 @@ func readB(i *bufio.Reader) (string, error) {
 	return BinaryString(bs), nil
 }
 
-func readE(i *bufio.Reader) ([]RedisMessage, error) {
+func readE(i *bufio.Reader, l protoLimits) ([]RedisMessage, error) {
+	if err := l.enter(); err != nil {
+		return nil, err
+	}
 	v := make([]RedisMessage, 0)
 	for {
-		n, err := readNextMessage(i)
+		n, err := readNextMessage(i, l)
 		if err != nil {
 			return nil, err
 		}
 		if n.typ == '.' {
 			return v, err
 		}
+		if int64(len(v)) == l.elems {
+			return nil, &ProtocolError{Limit: limitAggregateLen, Value: l.elems + 1, Max: l.elems}
+		}
 		v = append(v, n)
 	}
 }
 
-func readA(i *bufio.Reader, length int64) (v []RedisMessage, err error) {
-	v = make([]RedisMessage, length)
+func readA(i *bufio.Reader, l protoLimits, length int64) (v []RedisMessage, err error) {
+	if length < 0 || length > l.elems {
+		return nil, &ProtocolError{Limit: limitAggregateLen, Value: length, Max: l.elems}
+	}
+	if err = l.enter(); err != nil {
+		return nil, err
+	}
+	if length > maxPreallocElems {
+		v = make([]RedisMessage, 0, maxPreallocElems)
+	} else {
+		v = make([]RedisMessage, 0, length)
+	}
 	for n := int64(0); n < length; n++ {
-		if v[n], err = readNextMessage(i); err != nil {
+		m, err := readNextMessage(i, l)
+		if err != nil {
 			return nil, err
 		}
+		v = append(v, m)
 	}
 	return v, nil
 }
@@ -248,7 +369,7 @@ func writeS(o *bufio.Writer, id byte, str string) (err error) {
 	return err
 }
 
-func readNextMessage(i *bufio.Reader) (m RedisMessage, err error) {
+func readNextMessage(i *bufio.Reader, l protoLimits) (m RedisMessage, err error) {
 	var attrs *RedisMessage
 	var typ byte
 	for {
@@ -259,7 +380,7 @@ func readNextMessage(i *bufio.Reader) (m RedisMessage, err error) {
 		if fn == nil {
 			return RedisMessage{}, errors.New(unknownMessageType + strconv.Itoa(int(typ)))
 		}
-		if m, err = fn(i); err != nil {
+		if m, err = fn(i, l); err != nil {
 			if err == errOldNull {
 				return RedisMessage{typ: typeNull}, nil
 			}
@@ -277,6 +398,157 @@ func readNextMessage(i *bufio.Reader) (m RedisMessage, err error) {
 	}
 }
 
+// streamTo reads the next reply from i and copies its payload into w without buffering it into a RedisMessage.
+// Blob strings, verbatim strings and streamed strings are copied chunk by chunk with io.CopyN.
+// The l.bulk is not applied to the copied payload since it is never held in memory.
+// The returned clean reports whether the reply has been fully consumed from i so that the connection can be reused.
+func streamTo(i *bufio.Reader, l protoLimits, w io.Writer) (n int64, err error, clean bool) {
+next:
+	var typ byte
+	if typ, err = i.ReadByte(); err != nil {
+		return 0, err, false
+	}
+	switch typ {
+	case typeBlobString, typeVerbatimString:
+		var length int64
+		if length, err = readI(i); err != nil {
+			if err != errChunked {
+				return 0, err, false
+			}
+			for {
+				if err = readChunkPrefix(i); err != nil {
+					return n, err, false
+				}
+				if length, err = readChunkLength(i); err != nil {
+					return n, err, false
+				}
+				if length == 0 {
+					return n, nil, true
+				}
+				var nn int64
+				nn, err = io.CopyN(w, i, length)
+				if n += nn; err != nil {
+					return n, err, false
+				}
+				if _, err = i.Discard(2); err != nil {
+					return n, err, false
+				}
+			}
+		}
+		if length == -1 {
+			return 0, Nil, true
+		}
+		if length < 0 {
+			return 0, &ProtocolError{Limit: limitBulkLen, Value: length, Max: l.bulk}, false
+		}
+		if typ == typeVerbatimString { // skip the "txt:" format prefix
+			if _, err = i.Discard(4); err != nil {
+				return 0, err, false
+			}
+			length -= 4
+		}
+		if n, err = io.CopyN(w, i, length); err != nil {
+			return n, err, false
+		}
+		_, err = i.Discard(2)
+		return n, err, err == nil
+	case typeAttribute, typePush:
+		if _, err = readers[typ](i, l); err != nil {
+			return 0, err, false
+		}
+		goto next
+	}
+	fn := readers[typ]
+	if fn == nil {
+		return 0, errors.New(unknownMessageType + strconv.Itoa(int(typ))), false
+	}
+	m, err := fn(i, l)
+	if err != nil {
+		if err == errOldNull {
+			return 0, Nil, true
+		}
+		return 0, err, false
+	}
+	m.typ = typ
+	if err = m.Error(); err != nil {
+		return 0, err, true
+	}
+	var nn int
+	switch {
+	case m.IsInt64():
+		nn, err = io.WriteString(w, strconv.FormatInt(m.integer, 10))
+	case m.IsBool():
+		nn, err = io.WriteString(w, strconv.FormatBool(m.integer == 1))
+	case m.values != nil:
+		return 0, fmt.Errorf("redis message type %s is not a string", typeNames[typ]), true
+	default:
+		nn, err = io.WriteString(w, m.string)
+	}
+	return int64(nn), err, true
+}
+
+// iterTo reads the next reply from i and passes its top-level aggregate elements to fn one at a time.
+// The elements of a map are passed as keys and values alternately, and a non-aggregate reply is passed as a single element.
+// The l.elems is not applied to the top-level aggregate since its elements are never held in memory at once.
+// The returned clean reports whether the reply has been fully consumed from i so that the connection can be reused.
+func iterTo(i *bufio.Reader, l protoLimits, fn func(elem RedisMessage) error) (err error, clean bool) {
+next:
+	var typ byte
+	if typ, err = i.ReadByte(); err != nil {
+		return err, false
+	}
+	switch typ {
+	case typeAttribute, typePush:
+		if _, err = readers[typ](i, l); err != nil {
+			return err, false
+		}
+		goto next
+	case typeArray, typeSet, typeMap:
+		var length int64
+		length, err = readI(i)
+		chunked := err == errChunked
+		if err != nil && !chunked {
+			return err, false
+		}
+		if length == -1 {
+			return Nil, true
+		}
+		if length < 0 {
+			return &ProtocolError{Limit: limitAggregateLen, Value: length, Max: l.elems}, false
+		}
+		if typ == typeMap {
+			length *= 2
+		}
+		if err = l.enter(); err != nil {
+			return err, false
+		}
+		for n := int64(0); chunked || n < length; n++ {
+			var m RedisMessage
+			if m, err = readNextMessage(i, l); err != nil {
+				return err, false
+			}
+			if chunked && m.typ == typeEnd {
+				break
+			}
+			if err = fn(m); err != nil {
+				return err, !chunked && n == length-1
+			}
+		}
+		return nil, true
+	}
+	if err = i.UnreadByte(); err != nil {
+		return err, false
+	}
+	m, err := readNextMessage(i, l)
+	if err != nil {
+		return err, false
+	}
+	if err = m.Error(); err != nil {
+		return err, true
+	}
+	return fn(m), true
+}
+
 func writeCmd(o *bufio.Writer, cmd []string) (err error) {
 	err = writeS(o, '*', strconv.Itoa(len(cmd)))
 	for _, m := range cmd {
@@ -286,7 +558,10 @@ func writeCmd(o *bufio.Writer, cmd []string) (err error) {
 }
 
 const (
-	unexpectedNoCRLF   = "received unexpected simple string message ending without CRLF"
-	unexpectedNumByte  = "received unexpected number byte: "
-	unknownMessageType = "received unknown message type: "
+	unexpectedNoCRLF    = "received unexpected simple string message ending without CRLF"
+	unexpectedNumByte   = "received unexpected number byte: "
+	unknownMessageType  = "received unknown message type: "
+	unexpectedChunkByte = "received unexpected streamed string chunk byte: "
+	unexpectedChunkLen  = "received unexpected streamed string chunk length: "
+	unexpectedOddMap    = "received streamed map with odd number of elements"
 )
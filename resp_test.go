@@ -3,6 +3,7 @@ package rueidis
 import (
 	"bufio"
 	"bytes"
+	"errors"
 	"io"
 	"math/rand"
 	"reflect"
@@ -108,7 +109,7 @@ func TestReadNextMessage(t *testing.T) {
 		for k, g := range generators {
 			b.WriteByte(k)
 			b.WriteString(g(rand.Int63(), rand.Float64(), random(k == '+' || k == '-' || k == '(')))
-			msg, err := readNextMessage(r)
+			msg, err := readNextMessage(r, noLimits)
 			if err != nil {
 				t.Fatalf("unexpected error %v", err)
 			}
@@ -132,7 +133,7 @@ func TestWriteCmdAndRead(t *testing.T) {
 			t.Fatalf("unexpected err %v", err)
 		}
 		_ = o.Flush()
-		if m, err := readNextMessage(bufio.NewReader(b)); err != nil {
+		if m, err := readNextMessage(bufio.NewReader(b), noLimits); err != nil {
 			t.Fatalf("unexpected err %v", err)
 		} else if m.typ != '*' {
 			t.Fatalf("unexpected m.typ: expected *, got %v", m.typ)
@@ -167,7 +168,7 @@ func TestReadI(t *testing.T) {
 func TestReadBoolean(t *testing.T) {
 	data := "#t\r\n"
 	for i := 1; i <= len(data); i++ {
-		m, err := readNextMessage(bufio.NewReader(io.LimitReader(strings.NewReader(data), int64(i))))
+		m, err := readNextMessage(bufio.NewReader(io.LimitReader(strings.NewReader(data), int64(i))), noLimits)
 		if i < len(data) {
 			if err == nil {
 				t.Fatalf("unexpected no error: %v", i)
@@ -189,7 +190,7 @@ func TestReadBoolean(t *testing.T) {
 func TestReadString(t *testing.T) {
 	data := "+Hello word\r\n"
 	for i := 1; i <= len(data); i++ {
-		m, err := readNextMessage(bufio.NewReader(io.LimitReader(strings.NewReader(data), int64(i))))
+		m, err := readNextMessage(bufio.NewReader(io.LimitReader(strings.NewReader(data), int64(i))), noLimits)
 		if i < len(data) {
 			if err == nil {
 				t.Fatalf("unexpected no error: %v", i)
@@ -210,7 +211,7 @@ func TestReadString(t *testing.T) {
 
 func TestReadStringCRLFErr(t *testing.T) {
 	data := "+\n"
-	if _, err := readNextMessage(bufio.NewReader(strings.NewReader(data))); err.Error() != unexpectedNoCRLF {
+	if _, err := readNextMessage(bufio.NewReader(strings.NewReader(data)), noLimits); err.Error() != unexpectedNoCRLF {
 		t.Fatalf("unexpected err %v", err)
 	}
 }
@@ -218,7 +219,7 @@ func TestReadStringCRLFErr(t *testing.T) {
 func TestReadChunkedString(t *testing.T) {
 	data := "$?\r\n;4\r\nHell\r\n;5\r\no wor\r\n;1\r\nd\r\n;0\r\n"
 	for i := 1; i <= len(data); i++ {
-		m, err := readNextMessage(bufio.NewReader(io.LimitReader(strings.NewReader(data), int64(i))))
+		m, err := readNextMessage(bufio.NewReader(io.LimitReader(strings.NewReader(data), int64(i))), noLimits)
 		if i < len(data) {
 			if err == nil {
 				t.Fatalf("unexpected no error: %v", i)
@@ -241,7 +242,7 @@ func TestReadChunkedArray(t *testing.T) {
 	data := "*?\r\n:1\r\n:2\r\n:3\r\n.\r\n"
 
 	for i := 1; i <= len(data); i++ {
-		m, err := readNextMessage(bufio.NewReader(io.LimitReader(strings.NewReader(data), int64(i))))
+		m, err := readNextMessage(bufio.NewReader(io.LimitReader(strings.NewReader(data), int64(i))), noLimits)
 		if i < len(data) {
 			if err == nil {
 				t.Fatalf("unexpected no error: %v", i)
@@ -269,7 +270,7 @@ func TestReadChunkedMap(t *testing.T) {
 	data := "%?\r\n:1\r\n:2\r\n:3\r\n:4\r\n.\r\n"
 
 	for i := 1; i <= len(data); i++ {
-		m, err := readNextMessage(bufio.NewReader(io.LimitReader(strings.NewReader(data), int64(i))))
+		m, err := readNextMessage(bufio.NewReader(io.LimitReader(strings.NewReader(data), int64(i))), noLimits)
 		if i < len(data) {
 			if err == nil {
 				t.Fatalf("unexpected no error: %v", i)
@@ -293,12 +294,254 @@ func TestReadChunkedMap(t *testing.T) {
 	}
 }
 
+func TestReadChunkedSet(t *testing.T) {
+	data := "~?\r\n+a\r\n+b\r\n.\r\n"
+	m, err := readNextMessage(bufio.NewReader(strings.NewReader(data)), noLimits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(m, RedisMessage{typ: '~', values: []RedisMessage{{typ: '+', string: "a"}, {typ: '+', string: "b"}}}) {
+		t.Fatalf("unexpected msg %v", m)
+	}
+}
+
+func TestReadChunkedNested(t *testing.T) {
+	data := "*?\r\n$?\r\n;2\r\nab\r\n;1\r\nc\r\n;0\r\n%?\r\n+k\r\n*?\r\n.\r\n.\r\n:1\r\n.\r\n"
+	m, err := readNextMessage(bufio.NewReader(strings.NewReader(data)), noLimits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(m, RedisMessage{typ: '*', values: []RedisMessage{
+		{typ: '$', string: "abc"},
+		{typ: '%', values: []RedisMessage{{typ: '+', string: "k"}, {typ: '*', values: []RedisMessage{}}}},
+		{typ: ':', integer: 1},
+	}}) {
+		t.Fatalf("unexpected msg %v", m)
+	}
+}
+
+func TestReadChunkedErr(t *testing.T) {
+	for _, c := range []struct {
+		data string
+		err  string
+	}{
+		{data: "$?\r\n:4\r\nHell\r\n;0\r\n", err: unexpectedChunkByte + "58"},
+		{data: "$?\r\n;-4\r\nHell\r\n;0\r\n", err: unexpectedChunkLen + "-4"},
+		{data: "%?\r\n:1\r\n:2\r\n:3\r\n.\r\n", err: unexpectedOddMap},
+	} {
+		if _, err := readNextMessage(bufio.NewReader(strings.NewReader(c.data)), noLimits); err == nil || err.Error() != c.err {
+			t.Fatalf("unexpected err for %q: %v", c.data, err)
+		}
+	}
+}
+
+func FuzzReadChunkedString(f *testing.F) {
+	f.Add([]byte("Hello World"), uint8(4))
+	f.Add([]byte{}, uint8(1))
+	f.Add([]byte("\r\n;0\r\n"), uint8(2))
+	f.Fuzz(func(t *testing.T, data []byte, size uint8) {
+		if size == 0 {
+			size = 1
+		}
+		sb := strings.Builder{}
+		sb.WriteString("$?\r\n")
+		for i := 0; i < len(data); i += int(size) {
+			chunk := data[i:]
+			if len(chunk) > int(size) {
+				chunk = chunk[:size]
+			}
+			sb.WriteString(";" + strconv.Itoa(len(chunk)) + "\r\n")
+			sb.Write(chunk)
+			sb.WriteString("\r\n")
+		}
+		sb.WriteString(";0\r\n")
+		m, err := readNextMessage(bufio.NewReader(strings.NewReader(sb.String())), noLimits)
+		if err != nil {
+			t.Fatalf("unexpected err %v", err)
+		}
+		if m.typ != '$' || m.string != string(data) {
+			t.Fatalf("unexpected msg %v", m)
+		}
+	})
+}
+
+func FuzzReadChunkedAggregate(f *testing.F) {
+	f.Add([]byte{1, 2, 3, 4}, byte('*'))
+	f.Add([]byte{}, byte('~'))
+	f.Add([]byte{5, 6}, byte('%'))
+	f.Fuzz(func(t *testing.T, data []byte, typ byte) {
+		switch typ {
+		case '*', '~', '%':
+		default:
+			typ = '*'
+		}
+		if typ == '%' && len(data)%2 != 0 {
+			data = data[1:]
+		}
+		sb := strings.Builder{}
+		sb.WriteString(string(typ) + "?\r\n")
+		for _, b := range data {
+			sb.WriteString(":" + strconv.Itoa(int(b)) + "\r\n")
+		}
+		sb.WriteString(".\r\n")
+		m, err := readNextMessage(bufio.NewReader(strings.NewReader(sb.String())), noLimits)
+		if err != nil {
+			t.Fatalf("unexpected err %v", err)
+		}
+		if m.typ != typ || len(m.values) != len(data) {
+			t.Fatalf("unexpected msg %v", m)
+		}
+		for i, v := range m.values {
+			if v.typ != ':' || v.integer != int64(data[i]) {
+				t.Fatalf("unexpected msg values %v", m.values)
+			}
+		}
+	})
+}
+
+func TestReadLimits(t *testing.T) {
+	l := protoLimits{bulk: 3, elems: 2, depth: 2}
+	for _, c := range []struct {
+		data  string
+		limit string
+		value int64
+	}{
+		{data: "$4\r\nabcd\r\n", limit: limitBulkLen, value: 4},
+		{data: "$-2\r\n", limit: limitBulkLen, value: -2},
+		{data: "=8\r\ntxt:abcd\r\n", limit: limitBulkLen, value: 8},
+		{data: "$?\r\n;2\r\nab\r\n;2\r\ncd\r\n;0\r\n", limit: limitBulkLen, value: 4},
+		{data: "*3\r\n:1\r\n:2\r\n:3\r\n", limit: limitAggregateLen, value: 3},
+		{data: "*-2\r\n", limit: limitAggregateLen, value: -2},
+		{data: "*9223372036854775807\r\n", limit: limitAggregateLen, value: 9223372036854775807},
+		{data: "%2\r\n:1\r\n:2\r\n:3\r\n:4\r\n", limit: limitAggregateLen, value: 4},
+		{data: "%4611686018427387904\r\n", limit: limitAggregateLen, value: 9223372036854775806},
+		{data: "~?\r\n:1\r\n:2\r\n:3\r\n.\r\n", limit: limitAggregateLen, value: 3},
+		{data: "*1\r\n*1\r\n*1\r\n:1\r\n", limit: limitNestingDepth, value: 3},
+		{data: "*1\r\n*?\r\n*?\r\n.\r\n.\r\n", limit: limitNestingDepth, value: 3},
+		{data: "*1\r\n|1\r\n+a\r\n*1\r\n:1\r\n:1\r\n", limit: limitNestingDepth, value: 3},
+	} {
+		_, err := readNextMessage(bufio.NewReader(strings.NewReader(c.data)), l)
+		var pe *ProtocolError
+		if !errors.As(err, &pe) || pe.Limit != c.limit || pe.Value != c.value {
+			t.Fatalf("unexpected err %v for %q", err, c.data)
+		}
+	}
+	for _, data := range []string{
+		"$3\r\nabc\r\n",
+		"*2\r\n*2\r\n:1\r\n:2\r\n$3\r\nabc\r\n",
+		"%1\r\n+a\r\n*?\r\n:1\r\n:2\r\n.\r\n",
+		"$?\r\n;2\r\nab\r\n;1\r\nc\r\n;0\r\n",
+	} {
+		if _, err := readNextMessage(bufio.NewReader(strings.NewReader(data)), l); err != nil {
+			t.Fatalf("unexpected err %v for %q", err, data)
+		}
+	}
+	for _, data := range []string{
+		"$9223372036854775807\r\nabc",
+		"*9223372036854775807\r\n:1\r\n",
+		"%4611686018427387904\r\n:1\r\n",
+		"$?\r\n;9223372036854775807\r\nabc",
+	} {
+		if _, err := readNextMessage(bufio.NewReader(strings.NewReader(data)), noLimits); err != io.EOF {
+			t.Fatalf("unexpected err %v for %q", err, data)
+		}
+	}
+	if err := (&ProtocolError{Limit: limitBulkLen, Value: 4, Max: 3}).Error(); err != "received redis reply with bulk length 4 out of limit 3" {
+		t.Fatalf("unexpected err message %v", err)
+	}
+}
+
+func TestNewProtoLimits(t *testing.T) {
+	if l := newProtoLimits(&ClientOption{}); l != noLimits {
+		t.Fatalf("unexpected limits %v", l)
+	}
+	if l := newProtoLimits(&ClientOption{MaxBulkLen: 1, MaxAggregateLen: 2, MaxNestingDepth: 3}); l != (protoLimits{bulk: 1, elems: 2, depth: 3}) {
+		t.Fatalf("unexpected limits %v", l)
+	}
+}
+
+func TestStreamAndIterLimits(t *testing.T) {
+	l := protoLimits{bulk: 1, elems: 1, depth: 1}
+	buf := bytes.NewBuffer(nil)
+	if n, err, clean := streamTo(bufio.NewReader(strings.NewReader("$3\r\nabc\r\n")), l, buf); err != nil || !clean || n != 3 {
+		t.Fatalf("unexpected stream result %v %v %v", n, err, clean)
+	}
+	var pe *ProtocolError
+	if _, err, clean := streamTo(bufio.NewReader(strings.NewReader(">2\r\n+a\r\n+b\r\n$3\r\nabc\r\n")), l, buf); clean || !errors.As(err, &pe) {
+		t.Fatalf("unexpected stream result %v %v", err, clean)
+	}
+	if _, err, clean := streamTo(bufio.NewReader(strings.NewReader("$-2\r\n")), noLimits, buf); clean || !errors.As(err, &pe) || pe.Value != -2 {
+		t.Fatalf("unexpected stream result %v %v", err, clean)
+	}
+	count := 0
+	if err, clean := iterTo(bufio.NewReader(strings.NewReader("*2\r\n:1\r\n:2\r\n")), l, func(elem RedisMessage) error {
+		count++
+		return nil
+	}); err != nil || !clean || count != 2 {
+		t.Fatalf("unexpected iter result %v %v %v", err, clean, count)
+	}
+	if err, clean := iterTo(bufio.NewReader(strings.NewReader("*1\r\n*1\r\n:1\r\n")), l, func(elem RedisMessage) error {
+		return nil
+	}); clean || !errors.As(err, &pe) || pe.Limit != limitNestingDepth {
+		t.Fatalf("unexpected iter result %v %v", err, clean)
+	}
+	if err, clean := iterTo(bufio.NewReader(strings.NewReader("*-2\r\n")), noLimits, func(elem RedisMessage) error {
+		return nil
+	}); clean || !errors.As(err, &pe) || pe.Limit != limitAggregateLen || pe.Value != -2 {
+		t.Fatalf("unexpected iter result %v %v", err, clean)
+	}
+}
+
+func FuzzReadLimits(f *testing.F) {
+	f.Add([]byte("*2\r\n$3\r\nabc\r\n%1\r\n:1\r\n:2\r\n"), uint8(2), uint8(2), uint8(2))
+	f.Add([]byte("$?\r\n;2\r\nab\r\n;0\r\n"), uint8(1), uint8(0), uint8(0))
+	f.Add([]byte("*1\r\n*1\r\n*1\r\n_\r\n"), uint8(0), uint8(0), uint8(2))
+	f.Fuzz(func(t *testing.T, data []byte, bulk, elems, depth uint8) {
+		l := noLimits
+		if bulk != 0 {
+			l.bulk = int64(bulk)
+		}
+		if elems != 0 {
+			l.elems = int64(elems)
+		}
+		if depth != 0 {
+			l.depth = int(depth)
+		}
+		m, err := readNextMessage(bufio.NewReader(bytes.NewReader(data)), l)
+		var pe *ProtocolError
+		if errors.As(err, &pe) {
+			if pe.Value >= 0 && pe.Value <= pe.Max {
+				t.Fatalf("unexpected protocol err %v", pe)
+			}
+			return
+		}
+		if err != nil {
+			return
+		}
+		var check func(m RedisMessage, level int)
+		check = func(m RedisMessage, level int) {
+			if int64(len(m.string)) > l.bulk && m.typ != '+' && m.typ != '-' && m.typ != ',' && m.typ != '(' {
+				t.Fatalf("bulk length exceeded %v", m)
+			}
+			if m.values != nil {
+				if level++; level > l.depth || int64(len(m.values)) > l.elems {
+					t.Fatalf("aggregate limits exceeded %v", m)
+				}
+			}
+			for _, v := range m.values {
+				check(v, level)
+			}
+		}
+		check(m, 0)
+	})
+}
+
 // https://github.com/redis/redis-specifications/blob/master/protocol/RESP3.md#attribute-type
 func TestReadAttr(t *testing.T) {
 	data := "|1\r\n+key-popularity\r\n%2\r\n$1\r\na\r\n,0.1923\r\n$1\r\nb\r\n,0.0012\r\n*2\r\n:2039123\r\n:9543892\r\n"
 
 	for i := 1; i <= len(data); i++ {
-		m, err := readNextMessage(bufio.NewReader(io.LimitReader(strings.NewReader(data), int64(i))))
+		m, err := readNextMessage(bufio.NewReader(io.LimitReader(strings.NewReader(data), int64(i))), noLimits)
 		if i < len(data) {
 			if err == nil {
 				t.Fatalf("unexpected no error: %v", i)
@@ -331,10 +574,131 @@ func TestReadAttr(t *testing.T) {
 	}
 }
 
+type errWriter struct{ err error }
+
+func (w errWriter) Write(p []byte) (int, error) {
+	return 0, w.err
+}
+
+func TestStreamTo(t *testing.T) {
+	for _, c := range []struct {
+		data string
+		want string
+		err  error
+	}{
+		{data: "$11\r\nHello World\r\n", want: "Hello World"},
+		{data: "$0\r\n\r\n", want: ""},
+		{data: "=15\r\ntxt:Hello World\r\n", want: "Hello World"},
+		{data: "$?\r\n;4\r\nHell\r\n;5\r\no wor\r\n;1\r\nd\r\n;0\r\n", want: "Hello word"},
+		{data: "|1\r\n+key-popularity\r\n%1\r\n$1\r\na\r\n,0.1923\r\n$5\r\nHello\r\n", want: "Hello"},
+		{data: ">2\r\n$10\r\ninvalidate\r\n*1\r\n$1\r\na\r\n$5\r\nHello\r\n", want: "Hello"},
+		{data: "+OK\r\n", want: "OK"},
+		{data: ":123\r\n", want: "123"},
+		{data: "#t\r\n", want: "true"},
+		{data: ",1.5\r\n", want: "1.5"},
+		{data: "$-1\r\n", err: Nil},
+		{data: "_\r\n", err: Nil},
+		{data: "-ERR bad\r\n", err: &RedisError{typ: '-', string: "bad"}},
+	} {
+		buf := bytes.NewBuffer(nil)
+		n, err, clean := streamTo(bufio.NewReader(strings.NewReader(c.data)), noLimits, buf)
+		if !clean {
+			t.Fatalf("unexpected unclean stream for %q: %v", c.data, err)
+		}
+		if !reflect.DeepEqual(err, c.err) {
+			t.Fatalf("unexpected err for %q: %v", c.data, err)
+		}
+		if buf.String() != c.want || n != int64(len(c.want)) {
+			t.Fatalf("unexpected output for %q: %q %v", c.data, buf.String(), n)
+		}
+	}
+}
+
+func TestStreamToNonString(t *testing.T) {
+	_, err, clean := streamTo(bufio.NewReader(strings.NewReader("*1\r\n:1\r\n")), noLimits, io.Discard)
+	if !clean || err == nil || err.Error() != "redis message type array is not a string" {
+		t.Fatalf("unexpected err %v %v", err, clean)
+	}
+}
+
+func TestStreamToUnclean(t *testing.T) {
+	data := "$11\r\nHello World\r\n"
+	for i := 0; i < len(data); i++ {
+		if _, err, clean := streamTo(bufio.NewReader(io.LimitReader(strings.NewReader(data), int64(i))), noLimits, io.Discard); clean || err == nil {
+			t.Fatalf("unexpected clean stream at %d: %v", i, err)
+		}
+	}
+	e := errors.New("any")
+	if _, err, clean := streamTo(bufio.NewReader(strings.NewReader(data)), noLimits, errWriter{err: e}); clean || err != e {
+		t.Fatalf("unexpected err %v %v", err, clean)
+	}
+}
+
+func TestIterTo(t *testing.T) {
+	for _, c := range []struct {
+		data string
+		want []RedisMessage
+		err  error
+	}{
+		{data: "*2\r\n$1\r\na\r\n:1\r\n", want: []RedisMessage{{typ: '$', string: "a"}, {typ: ':', integer: 1}}},
+		{data: "*0\r\n", want: nil},
+		{data: "~1\r\n+a\r\n", want: []RedisMessage{{typ: '+', string: "a"}}},
+		{data: "%1\r\n+k\r\n*1\r\n+v\r\n", want: []RedisMessage{{typ: '+', string: "k"}, {typ: '*', values: []RedisMessage{{typ: '+', string: "v"}}}}},
+		{data: "*?\r\n:1\r\n:2\r\n.\r\n", want: []RedisMessage{{typ: ':', integer: 1}, {typ: ':', integer: 2}}},
+		{data: "|1\r\n+a\r\n+b\r\n>2\r\n+c\r\n+d\r\n*1\r\n+e\r\n", want: []RedisMessage{{typ: '+', string: "e"}}},
+		{data: "+OK\r\n", want: []RedisMessage{{typ: '+', string: "OK"}}},
+		{data: "*-1\r\n", err: Nil},
+		{data: "-ERR bad\r\n", err: &RedisError{typ: '-', string: "bad"}},
+	} {
+		var got []RedisMessage
+		err, clean := iterTo(bufio.NewReader(strings.NewReader(c.data)), noLimits, func(elem RedisMessage) error {
+			got = append(got, elem)
+			return nil
+		})
+		if !clean {
+			t.Fatalf("unexpected unclean iteration for %q: %v", c.data, err)
+		}
+		if !reflect.DeepEqual(err, c.err) {
+			t.Fatalf("unexpected err for %q: %v", c.data, err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("unexpected elements for %q: %v", c.data, got)
+		}
+	}
+}
+
+func TestIterToUnclean(t *testing.T) {
+	data := "*2\r\n$1\r\na\r\n:1\r\n"
+	for i := 0; i < len(data); i++ {
+		if err, clean := iterTo(bufio.NewReader(io.LimitReader(strings.NewReader(data), int64(i))), noLimits, func(elem RedisMessage) error {
+			return nil
+		}); clean || err == nil {
+			t.Fatalf("unexpected clean iteration at %d: %v", i, err)
+		}
+	}
+	e := errors.New("any")
+	count := 0
+	stop := func(at int) func(elem RedisMessage) error {
+		count = 0
+		return func(elem RedisMessage) error {
+			if count++; count == at {
+				return e
+			}
+			return nil
+		}
+	}
+	if err, clean := iterTo(bufio.NewReader(strings.NewReader(data)), noLimits, stop(1)); clean || err != e {
+		t.Fatalf("unexpected err %v %v", err, clean)
+	}
+	if err, clean := iterTo(bufio.NewReader(strings.NewReader(data)), noLimits, stop(2)); !clean || err != e {
+		t.Fatalf("unexpected err %v %v", err, clean)
+	}
+}
+
 func TestReadRESP2NullString(t *testing.T) {
 	data := "$-1\r\n"
 	for i := 1; i <= len(data); i++ {
-		m, err := readNextMessage(bufio.NewReader(io.LimitReader(strings.NewReader(data), int64(i))))
+		m, err := readNextMessage(bufio.NewReader(io.LimitReader(strings.NewReader(data), int64(i))), noLimits)
 		if i < len(data) {
 			if err == nil {
 				t.Fatalf("unexpected no error: %v", i)
@@ -353,7 +717,7 @@ func TestReadRESP2NullString(t *testing.T) {
 func TestReadRESP2NullStringInArray(t *testing.T) {
 	data := "*3\r\n$5\r\nhello\r\n$-1\r\n$5\r\nworld\r\n"
 	for i := 1; i <= len(data); i++ {
-		m, err := readNextMessage(bufio.NewReader(io.LimitReader(strings.NewReader(data), int64(i))))
+		m, err := readNextMessage(bufio.NewReader(io.LimitReader(strings.NewReader(data), int64(i))), noLimits)
 		if i < len(data) {
 			if err == nil {
 				t.Fatalf("unexpected no error: %v", i)
@@ -379,7 +743,7 @@ func TestReadRESP2NullStringInArray(t *testing.T) {
 func TestReadRESP2NullArray(t *testing.T) {
 	data := "*-1\r\n"
 	for i := 1; i <= len(data); i++ {
-		m, err := readNextMessage(bufio.NewReader(io.LimitReader(strings.NewReader(data), int64(i))))
+		m, err := readNextMessage(bufio.NewReader(io.LimitReader(strings.NewReader(data), int64(i))), noLimits)
 		if i < len(data) {
 			if err == nil {
 				t.Fatalf("unexpected no error: %v", i)
@@ -396,7 +760,7 @@ func TestReadRESP2NullArray(t *testing.T) {
 }
 
 func TestWriteBReadB(t *testing.T) {
-	TWriterAndReader(t, writeB, readB, false)
+	TWriterAndReader(t, writeB, func(i *bufio.Reader) (string, error) { return readB(i, noLimits) }, false)
 }
 
 func TestWriteSReadS(t *testing.T) {
@@ -428,7 +792,7 @@ func TWriterAndReader(t *testing.T, writer func(*bufio.Writer, byte, string) err
 
 func TestRand(t *testing.T) {
 	read := func(in *bufio.Reader) (m RedisMessage, err error) {
-		m, err = readNextMessage(in)
+		m, err = readNextMessage(in, noLimits)
 		return
 	}
 	for i := 0; i < iteration; i++ {
@@ -446,10 +810,10 @@ func TestRand(t *testing.T) {
 }
 
 func TestChunkedStringRand(t *testing.T) {
-	chunkedPrefix := "$?\n;"
+	chunkedPrefix := "$?\r\n;"
 
 	read := func(in *bufio.Reader) (m RedisMessage, err error) {
-		m, err = readNextMessage(in)
+		m, err = readNextMessage(in, noLimits)
 		return
 	}
 
@@ -457,6 +821,8 @@ func TestChunkedStringRand(t *testing.T) {
 		if _, err := read(bufio.NewReader(strings.NewReader(chunkedPrefix + random(false)))); err != nil &&
 			err != io.EOF &&
 			err != errChunked &&
+			!strings.HasPrefix(err.Error(), unexpectedChunkByte) &&
+			!strings.HasPrefix(err.Error(), unexpectedChunkLen) &&
 			!strings.HasPrefix(err.Error(), unexpectedNoCRLF) &&
 			!strings.HasPrefix(err.Error(), unexpectedNumByte) &&
 			!strings.HasPrefix(err.Error(), unknownMessageType) {
@@ -0,0 +1,94 @@
+package rueidis
+
+import (
+	"context"
+	"math/rand"
+	"runtime"
+	"time"
+)
+
+// RetryPolicy decides whether a failed command should be retried and how long to wait before the next attempt.
+// It is only consulted for the commands that are safe to retry, which are the read-only commands and
+// the commands marked by Completed.Idempotent, and never if
+// ClientOption.DisableRetry is set. Redirections in a redis cluster (MOVED and ASK) are not counted as attempts.
+type RetryPolicy interface {
+	// Retry is called with the number of attempts already made, starting from 1, the error of the last attempt
+	// and the command. It returns whether to retry the command and how long to wait before retrying it.
+	Retry(attempts int, err error, cmd Completed) (retry bool, wait time.Duration)
+}
+
+// ExponentialBackoffRetryPolicy is the default RetryPolicy. It retries up to MaxAttempts attempts
+// and waits Base * 2^(attempts-1), capped at Max, between attempts with a random jitter of up to half the wait.
+// A zero Base retries immediately, a zero Max does not cap the wait and a zero MaxAttempts retries without limit.
+type ExponentialBackoffRetryPolicy struct {
+	Base        time.Duration
+	Max         time.Duration
+	MaxAttempts int
+}
+
+// Retry implements RetryPolicy
+func (p *ExponentialBackoffRetryPolicy) Retry(attempts int, _ error, _ Completed) (bool, time.Duration) {
+	if p.MaxAttempts > 0 && attempts >= p.MaxAttempts {
+		return false, 0
+	}
+	if p.Base <= 0 {
+		return true, 0
+	}
+	wait := p.Base
+	for i := 1; i < attempts && (p.Max <= 0 || wait < p.Max) && wait < wait<<1; i++ {
+		wait <<= 1
+	}
+	if p.Max > 0 && wait > p.Max {
+		wait = p.Max
+	}
+	return true, wait - time.Duration(rand.Int63n(int64(wait/2)+1))
+}
+
+type retryHandler struct {
+	policy RetryPolicy
+}
+
+func newRetryHandler(policy RetryPolicy) retryHandler {
+	if policy == nil {
+		policy = &ExponentialBackoffRetryPolicy{
+			Base:        DefaultRetryBackoffBase,
+			Max:         DefaultRetryBackoffMax,
+			MaxAttempts: DefaultRetryMaxAttempts,
+		}
+	}
+	return retryHandler{policy: policy}
+}
+
+// RetryDelay returns the wait before the next attempt, or -1 if the cmd should not be retried.
+func (r retryHandler) RetryDelay(attempts int, err error, cmd Completed) time.Duration {
+	if retry, wait := r.policy.Retry(attempts, err, cmd); retry {
+		if wait < 0 {
+			wait = 0
+		}
+		return wait
+	}
+	return -1
+}
+
+// WaitForRetry blocks for the delay or until the ctx is done.
+func (r retryHandler) WaitForRetry(ctx context.Context, delay time.Duration) {
+	if delay <= 0 {
+		runtime.Gosched()
+		return
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
+
+// WaitOrSkipRetry waits for the next attempt and returns true, or returns false if the cmd should not be retried.
+func (r retryHandler) WaitOrSkipRetry(ctx context.Context, attempts int, err error, cmd Completed) bool {
+	if delay := r.RetryDelay(attempts, err, cmd); delay >= 0 {
+		r.WaitForRetry(ctx, delay)
+		return ctx.Err() == nil
+	}
+	return false
+}
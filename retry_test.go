@@ -0,0 +1,93 @@
+package rueidis
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type retryPolicyFn func(attempts int, err error, cmd Completed) (bool, time.Duration)
+
+func (fn retryPolicyFn) Retry(attempts int, err error, cmd Completed) (bool, time.Duration) {
+	return fn(attempts, err, cmd)
+}
+
+func TestExponentialBackoffRetryPolicy(t *testing.T) {
+	p := &ExponentialBackoffRetryPolicy{Base: 10 * time.Millisecond, Max: 50 * time.Millisecond, MaxAttempts: 5}
+	for attempts, expected := range []time.Duration{10, 20, 40, 50} {
+		for i := 0; i < 100; i++ {
+			expected := expected * time.Millisecond
+			if retry, wait := p.Retry(attempts+1, nil, Completed{}); !retry || wait > expected || wait < expected/2 {
+				t.Fatalf("unexpected %v %v for attempts %d", retry, wait, attempts+1)
+			}
+		}
+	}
+	if retry, _ := p.Retry(5, nil, Completed{}); retry {
+		t.Fatalf("should not retry after MaxAttempts")
+	}
+	if retry, wait := p.Retry(100, nil, Completed{}); retry || wait != 0 {
+		t.Fatalf("should not retry after MaxAttempts")
+	}
+	p = &ExponentialBackoffRetryPolicy{Base: time.Millisecond}
+	if retry, wait := p.Retry(100, nil, Completed{}); !retry || wait <= 0 {
+		t.Fatalf("unexpected %v %v", retry, wait)
+	}
+	p = &ExponentialBackoffRetryPolicy{}
+	if retry, wait := p.Retry(100, nil, Completed{}); !retry || wait != 0 {
+		t.Fatalf("unexpected %v %v", retry, wait)
+	}
+}
+
+func TestRetryHandler(t *testing.T) {
+	t.Run("default policy", func(t *testing.T) {
+		h := newRetryHandler(nil)
+		if d := h.RetryDelay(1, errors.New("any"), Completed{}); d < DefaultRetryBackoffBase/2 || d > DefaultRetryBackoffBase {
+			t.Fatalf("unexpected delay %v", d)
+		}
+		if d := h.RetryDelay(DefaultRetryMaxAttempts-1, errors.New("any"), Completed{}); d < 0 {
+			t.Fatalf("unexpected delay %v", d)
+		}
+		if d := h.RetryDelay(DefaultRetryMaxAttempts, errors.New("any"), Completed{}); d != -1 {
+			t.Fatalf("unexpected delay %v", d)
+		}
+	})
+	t.Run("negative wait", func(t *testing.T) {
+		h := newRetryHandler(retryPolicyFn(func(attempts int, err error, cmd Completed) (bool, time.Duration) {
+			return true, -1
+		}))
+		if d := h.RetryDelay(1, nil, Completed{}); d != 0 {
+			t.Fatalf("unexpected delay %v", d)
+		}
+	})
+	t.Run("wait", func(t *testing.T) {
+		h := newRetryHandler(retryPolicyFn(func(attempts int, err error, cmd Completed) (bool, time.Duration) {
+			return true, 10 * time.Millisecond
+		}))
+		start := time.Now()
+		if !h.WaitOrSkipRetry(context.Background(), 1, nil, Completed{}) {
+			t.Fatalf("should retry")
+		}
+		if time.Since(start) < 10*time.Millisecond {
+			t.Fatalf("should wait")
+		}
+	})
+	t.Run("skip", func(t *testing.T) {
+		h := newRetryHandler(retryPolicyFn(func(attempts int, err error, cmd Completed) (bool, time.Duration) {
+			return false, 0
+		}))
+		if h.WaitOrSkipRetry(context.Background(), 1, nil, Completed{}) {
+			t.Fatalf("should not retry")
+		}
+	})
+	t.Run("ctx done", func(t *testing.T) {
+		h := newRetryHandler(retryPolicyFn(func(attempts int, err error, cmd Completed) (bool, time.Duration) {
+			return true, time.Hour
+		}))
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		if h.WaitOrSkipRetry(ctx, 1, nil, Completed{}) {
+			t.Fatalf("should not retry")
+		}
+	})
+}
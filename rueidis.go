@@ -5,6 +5,7 @@ import (
 	"context"
 	"crypto/tls"
 	"errors"
+	"fmt"
 	"math/rand"
 	"net"
 	"strings"
@@ -28,6 +29,14 @@ const (
 	DefaultReadBuffer = 1 << 19
 	// DefaultWriteBuffer is the default value of bufio.NewWriterSize for each connection, which is 0.5MiB
 	DefaultWriteBuffer = 1 << 19
+	// DefaultRetryBackoffBase is the default wait before the first retry of ClientOption.RetryPolicy
+	DefaultRetryBackoffBase = 10 * time.Millisecond
+	// DefaultRetryBackoffMax is the default maximum wait between retries of ClientOption.RetryPolicy
+	DefaultRetryBackoffMax = 1 * time.Second
+	// DefaultRetryMaxAttempts is the default maximum attempts of a command made by ClientOption.RetryPolicy
+	DefaultRetryMaxAttempts = 10
+	// DefaultCircuitBreakerCooldown is the default value of CircuitBreakerOption.Cooldown
+	DefaultCircuitBreakerCooldown = 1 * time.Second
 )
 
 var (
@@ -41,8 +50,36 @@ var (
 	ErrRESP2PubSubMixed = errors.New("rueidis does not support SUBSCRIBE/PSUBSCRIBE/SSUBSCRIBE mixed with other commands in RESP2")
 	// ErrDoCacheAborted means redis abort EXEC request or connection closed
 	ErrDoCacheAborted = errors.New("failed to fetch the cache because EXEC was aborted by redis or connection closed")
+	// ErrCircuitOpen means the circuit breaker of the redis node is open, see ClientOption.CircuitBreaker
+	ErrCircuitOpen = errors.New("rueidis circuit breaker is open")
+	// ErrPoolExhausted means the ctx is done before a connection is acquired from the exhausted blocking connection pool.
+	// It is always wrapped with the ctx.Err(), so errors.Is can be used to check either of them.
+	ErrPoolExhausted = errors.New("rueidis blocking connection pool is exhausted")
 )
 
+// CloseError is returned by Client.CloseWithContext when its ctx is done before all pending calls finished.
+// The pending calls are failed with ErrClosing and Abandoned is the number of them.
+type CloseError struct {
+	// Err is the ctx.Err() of the Client.CloseWithContext.
+	Err       error
+	Abandoned int
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("rueidis client closed with %d pending calls abandoned: %v", e.Abandoned, e.Err)
+}
+
+func (e *CloseError) Unwrap() error {
+	return e.Err
+}
+
+func newCloseError(ctx context.Context, abandoned int) error {
+	if abandoned == 0 {
+		return nil
+	}
+	return &CloseError{Err: ctx.Err(), Abandoned: abandoned}
+}
+
 // ClientOption should be passed to NewClient to construct a Client
 type ClientOption struct {
 	// TCP & TLS
@@ -56,6 +93,12 @@ type ClientOption struct {
 	Dialer    net.Dialer
 	TLSConfig *tls.Config
 
+	// TLSServerNameFn returns the ServerName used to verify the certificate of the node at dst.
+	// It is called for every connection when TLSConfig is set, including the cluster nodes and the masters discovered by sentinels.
+	// Returning an empty string keeps the ServerName of TLSConfig.
+	// Without it, the cluster client uses the hostnames announced by the cluster if there are any.
+	TLSServerNameFn func(dst string) string
+
 	// DialFn allows for a custom function to be used to create net.Conn connections
 	DialFn func(string, *net.Dialer, *tls.Config) (conn net.Conn, err error)
 
@@ -66,6 +109,10 @@ type ClientOption struct {
 	// Note that this function must be fast, otherwise other redis messages will be blocked.
 	OnInvalidations func([]RedisMessage)
 
+	// OnPush is a callback function for the RESP3 push messages not handled by rueidis, with the address of the node and the kind of the push.
+	// Invalidations and pubsub messages are not passed to it. Note that this function must be fast, otherwise other redis messages will be blocked.
+	OnPush func(addr string, kind string, values []RedisMessage)
+
 	// Sentinel options, including MasterSet and Auth options
 	Sentinel SentinelOption
 
@@ -74,14 +121,25 @@ type ClientOption struct {
 	Password   string
 	ClientName string
 
+	// AuthCredentialsFn allows for setting the AUTH username and password dynamically on each connection attempt
+	// to support rotating credentials, such as short-lived tokens. It takes precedence over Username and Password.
+	// It is not used for connecting sentinels.
+	AuthCredentialsFn func(ctx context.Context) (username, password string, err error)
+	// AuthCredentialsRefreshInterval when greater than zero makes every pipelined connection call the AuthCredentialsFn
+	// and re-AUTH with the returned credentials every interval. It should be shorter than the lifetime of the credentials.
+	// A connection is closed and replaced if its re-AUTH is rejected. Connections of the blocking pool, which are also
+	// used by dedicated clients, are not re-authenticated. Use BlockingPoolMaxLifetime to recycle them with fresh credentials.
+	AuthCredentialsRefreshInterval time.Duration
+
 	// ClientSetInfo will assign various info attributes to the current connection
 	ClientSetInfo []string
 
 	// InitAddress point to redis nodes.
-	// Rueidis will connect to them one by one and issue CLUSTER SLOT command to initialize the cluster client until success.
+	// Rueidis will connect to them one by one and issue CLUSTER SLOTS (or CLUSTER SHARDS on Redis 7+) command to initialize the cluster client until success.
 	// If len(InitAddress) == 1 and the address is not running in cluster mode, rueidis will fall back to the single client mode.
 	// If ClientOption.Sentinel.MasterSet is set, then InitAddress will be used to connect sentinels
 	// You can bypass this behaviour by using ClientOption.ForceSingleClient.
+	// An address that is an absolute path to a unix socket, for example /tmp/redis.sock, is dialed with the unix network.
 	InitAddress []string
 
 	// ClientTrackingOptions will be appended to CLIENT TRACKING ON command when the connection is established.
@@ -105,14 +163,54 @@ type ClientOption struct {
 	// WriteBufferEachConn is the size of the bufio.NewWriterSize for each connection, default to DefaultWriteBuffer (0.5 MiB).
 	WriteBufferEachConn int
 
+	// MaxBulkLen limits the length of a blob string reply, MaxAggregateLen limits the number of elements
+	// of an aggregate reply, and MaxNestingDepth limits how deep aggregate replies can be nested.
+	// A reply breaching them is failed with a *ProtocolError and its connection is closed,
+	// protecting the client from running out of memory or stack due to a broken or malicious server.
+	// The default is zero which means no limit. Client.DoStream and Client.DoIter are not limited by
+	// MaxBulkLen and MaxAggregateLen respectively, since they don't hold the whole reply in memory.
+	MaxBulkLen      int
+	MaxAggregateLen int
+	MaxNestingDepth int
+
 	// BlockingPoolSize is the size of the connection pool shared by blocking commands (ex BLPOP, XREAD with BLOCK).
 	// The default is DefaultPoolSize.
 	BlockingPoolSize int
+	// BlockingPoolCleanup is how long a connection can stay idle in the blocking connection pool before it is closed.
+	// It should be shorter than the redis server side `timeout` config to avoid using connections closed by the server.
+	// The default is zero which means idle connections are kept forever.
+	BlockingPoolCleanup time.Duration
+	// BlockingPoolMaxLifetime is how long a connection in the blocking connection pool can be reused since it is dialed.
+	// An expired connection is closed when it is idle. The default is zero which means no limit.
+	BlockingPoolMaxLifetime time.Duration
+	// BlockingPoolMinSize is the number of idle connections the blocking connection pool tries to keep,
+	// which are dialed in the background and replace the ones closed by BlockingPoolCleanup or BlockingPoolMaxLifetime.
+	// The pool starts filling only after its first use, and it stops dialing while dials to the node are failing.
+	// The default is zero.
+	BlockingPoolMinSize int
 
 	// PipelineMultiplex determines how many tcp connections used to pipeline commands to one redis instance.
 	// The default for single and sentinel clients is 2, which means 4 connections (2^2).
 	// For cluster client, PipelineMultiplex doesn't have any effect.
 	PipelineMultiplex int
+	// PipelineLeastLoaded makes commands pipelined to the connection with the fewest in-flight commands among the
+	// PipelineMultiplex connections, instead of the connection decided by the slot of their keys. This keeps a slow reply
+	// from delaying the other commands queued behind it. DoCache, DoMultiCache and pubsub commands are not affected,
+	// because the client side cache and the subscriptions are bound to each connection.
+	PipelineLeastLoaded bool
+	// PriorityLane adds one more pipelined connection to each redis node as a priority lane, which has its own ring buffer
+	// and flush loop. The commands marked by Completed.HighPriority() are sent through the lane, so that they don't wait
+	// behind large DoMulti payloads in other connections. A DoMulti is sent through the lane if any of its commands is marked.
+	// Commands not marked and pubsub commands are never sent through the lane. Marks are ignored if PriorityLane is false.
+	PriorityLane bool
+	// MaxPipelineBatch limits how many commands of a DoMulti are written to a pipelined connection at once.
+	// A larger DoMulti is split into chunks of at most MaxPipelineBatch commands on the same connection, and the next chunk
+	// is written while the replies of the previous one are read, so that at most two chunks are in flight at a time and
+	// commands from other callers can be interleaved between chunks. A MULTI/EXEC block is never split.
+	// The results are still in the original order. It doesn't apply to DoMultiCache, dedicated clients and
+	// DoMulti calls containing a blocking command, which are sent through the blocking pool as a whole.
+	// The default 0 means no limit.
+	MaxPipelineBatch int
 
 	// ConnWriteTimeout is applied net.Conn.SetWriteDeadline and periodic PING to redis
 	// Since the Dialer.KeepAlive will not be triggered if there is data in the outgoing buffer,
@@ -120,6 +218,19 @@ type ClientOption struct {
 	// This default is ClientOption.Dialer.KeepAlive * (9+1), where 9 is the default of tcp_keepalive_probes on Linux.
 	ConnWriteTimeout time.Duration
 
+	// DefaultCommandTimeout when greater than zero is applied to each command whose ctx has no deadline,
+	// so that a slow redis fails the command with context.DeadlineExceeded instead of hanging the caller.
+	// A timed out command is failed locally without breaking the connection, and its late response is discarded.
+	// It can be overridden by Completed.WithTimeout or Cacheable.WithTimeout of each command.
+	// Blocking commands with a server side timeout, such as BLPOP and XREAD with BLOCK, are timed out after their
+	// server side timeout plus one second regardless of the DefaultCommandTimeout.
+	// A command timed out by it is not retried.
+	DefaultCommandTimeout time.Duration
+
+	// CircuitBreaker when its Threshold is greater than zero enables a circuit breaker for each redis node,
+	// which fails commands fast with ErrCircuitOpen instead of redialing an unhealthy node on every command.
+	CircuitBreaker CircuitBreakerOption
+
 	// MaxFlushDelay when greater than zero pauses pipeline write loop for some time (not larger than MaxFlushDelay)
 	// after each flushing of data to the connection. This gives pipeline a chance to collect more commands to send
 	// to Redis. Adding this delay increases latency, reduces throughput – but in most cases may significantly reduce
@@ -129,12 +240,26 @@ type ClientOption struct {
 	// produce notable CPU usage reduction under load. Ref: https://github.com/redis/rueidis/issues/156
 	MaxFlushDelay time.Duration
 
+	// ClusterAddressMapper, if set, is applied to every node address announced by the cluster, including
+	// the CLUSTER SLOTS and CLUSTER SHARDS results and the MOVED and ASK redirection targets.
+	// It is useful when the announced addresses are not reachable directly, for example behind NAT or in containers.
+	// The returned address is used to dial the node and as the key of Client.Nodes().
+	ClusterAddressMapper func(announced string) string
+
+	// ClusterRefreshInterval when greater than zero makes the cluster client refresh its slot map in the background
+	// every interval, plus a small random jitter. Otherwise, the slot map is only refreshed on redirections and connection errors.
+	// The slot map and the connections are only replaced when the topology actually changes.
+	ClusterRefreshInterval time.Duration
+
 	// ShuffleInit is a handy flag that shuffles the InitAddress after passing to the NewClient() if it is true
 	ShuffleInit bool
 	// ClientNoTouch controls whether commands alter LRU/LFU stats
 	ClientNoTouch bool
-	// DisableRetry disables retrying read-only commands under network errors
+	// DisableRetry disables retrying read-only and idempotent commands under network errors
 	DisableRetry bool
+	// RetryPolicy decides whether and when to retry the read-only and idempotent commands under network errors.
+	// The default is an ExponentialBackoffRetryPolicy with DefaultRetryBackoffBase, DefaultRetryBackoffMax and DefaultRetryMaxAttempts.
+	RetryPolicy RetryPolicy
 	// DisableCache falls back Client.DoCache/Client.DoMultiCache to Client.Do/Client.DoMulti
 	DisableCache bool
 	// AlwaysPipelining makes rueidis.Client always pipeline redis commands even if they are not issued concurrently.
@@ -149,6 +274,14 @@ type ClientOption struct {
 	// currently, it is only implemented for sentinel client
 	ReplicaOnly bool
 
+	// ReadFromReplicas makes the cluster client send read-only commands, including DoCache and DoMultiCache,
+	// to the replicas chosen by ReplicaSelector. Other commands are still sent to the primaries.
+	// Slots without replicas are served by their primaries. It only takes effect in cluster client.
+	ReadFromReplicas bool
+	// ReplicaSelector chooses a replica for each slot when the slot map is refreshed.
+	// The default is RandomReplicaSelector. NewRoundRobinReplicaSelector can be used to spread slots evenly.
+	ReplicaSelector ReplicaSelector
+
 	// ClientNoEvict sets the client eviction mode for the current connection.
 	// When turned on and client eviction is configured,
 	// the current connection will be excluded from the client eviction process
@@ -200,6 +333,24 @@ type Client interface {
 	// DoMultiCache is similar to DoCache, but works with multiple cacheable commands across different slots.
 	// It will first group commands by slots and will send only cache missed commands to redis.
 	DoMultiCache(ctx context.Context, multi ...CacheableTTL) (resp []RedisResult)
+	// DoStream sends a command to redis through a dedicated connection coming from the pool.
+	// The returned RedisResultStream holds the connection until its WriteTo is called and copies the response
+	// from the connection directly to an io.Writer, which is suitable for huge blob string responses.
+	//  s := client.DoStream(ctx, client.B().Get().Key("k").Build())
+	//  _, err := s.WriteTo(w)
+	// Note that DoStream does not retry and does not follow cluster redirections.
+	// The cmd parameter is recycled after passing into DoStream() and should not be reused.
+	DoStream(ctx context.Context, cmd Completed) RedisResultStream
+	// DoIter is similar to DoStream, but it decodes the top-level elements of an aggregate response one at a time
+	// and passes them to fn, so that huge collections can be processed without loading the whole response into memory.
+	// The elements of a map response are passed as keys and values alternately.
+	//  err := client.DoIter(ctx, client.B().Hgetall().Key("k").Build(), func(elem RedisMessage) error {
+	//      return nil
+	//  })
+	// If fn returns an error, DoIter stops and returns the error, and the connection will be closed
+	// if there are remaining elements not yet read.
+	// The cmd parameter is recycled after passing into DoIter() and should not be reused.
+	DoIter(ctx context.Context, cmd Completed, fn func(elem RedisMessage) error) error
 
 	// Receive accepts SUBSCRIBE, SSUBSCRIBE, PSUBSCRIBE command and a message handler.
 	// Receive will block and then return value only when the following cases:
@@ -219,13 +370,35 @@ type Client interface {
 	// and requires user to invoke cancel() manually to put connection back to the pool.
 	Dedicate() (client DedicatedClient, cancel func())
 
+	// DedicatedCtx is the same as Dedicated, but it stops waiting for a connection from the exhausted blocking connection pool
+	// when the ctx is done, and returns an error wrapping both ErrPoolExhausted and the ctx.Err() without calling the fn.
+	// The ctx is only used for acquiring the connection. For the cluster client, the acquisition is deferred to the first
+	// command to the DedicatedClient, because the slot is unknown before it. The fn is therefore always called, and
+	// the commands of the DedicatedClient fail with that error instead until a connection is acquired.
+	DedicatedCtx(ctx context.Context, fn func(DedicatedClient) error) (err error)
+
+	// DedicateCtx is the same as Dedicate, but it stops waiting for a connection like the DedicatedCtx does.
+	// The cluster client never returns an error here since its acquisition is deferred to the first command, see DedicatedCtx.
+	DedicateCtx(ctx context.Context) (client DedicatedClient, cancel func(), err error)
+
 	// Nodes returns each redis node this client known as rueidis.Client. This is useful if you want to
 	// send commands to some specific redis nodes in the cluster.
 	Nodes() map[string]Client
 
+	// Stats returns a snapshot of the counters of the connections to each redis node this client known,
+	// including the pipelined connections, the connection pools, and the client side caches.
+	// It is cheap enough to be called on every metrics scrape.
+	Stats() ClientStats
+
 	// Close will make further calls to the client be rejected with ErrClosing,
 	// and Close will wait until all pending calls finished.
 	Close()
+
+	// CloseWithContext is the same as Close, but it only waits for the pending calls to finish until the ctx is done.
+	// After that, the connections are closed forcibly, the remaining pending calls are failed with ErrClosing,
+	// and a *CloseError reporting the number of them is returned.
+	// Connections acquired from the blocking connection pool, for example by DedicatedClient, are still closed once they are released.
+	CloseWithContext(ctx context.Context) error
 }
 
 // DedicatedClient is obtained from Client.Dedicated() and it will be bound to single redis connection and
@@ -294,12 +467,14 @@ func NewClient(option ClientOption) (client Client, err error) {
 			option.InitAddress[i], option.InitAddress[j] = option.InitAddress[j], option.InitAddress[i]
 		})
 	}
+	makeConn := makeConnFn(option.CircuitBreaker)
 	if option.Sentinel.MasterSet != "" {
 		option.PipelineMultiplex = singleClientMultiplex(option.PipelineMultiplex)
 		return newSentinelClient(&option, makeConn)
 	}
 	pmbk := option.PipelineMultiplex
 	option.PipelineMultiplex = 0 // PipelineMultiplex is meaningless for cluster client
+	option.ReplicaOnly = false   // ReplicaOnly is only implemented for sentinel client
 
 	if option.ForceSingleClient {
 		option.PipelineMultiplex = singleClientMultiplex(pmbk)
@@ -327,20 +502,52 @@ func singleClientMultiplex(multiplex int) int {
 	return multiplex
 }
 
-func makeConn(dst string, opt *ClientOption) conn {
-	return makeMux(dst, opt, dial)
+// makeConnFn returns the connFn of a client, which makes the conns to the same address share the same circuit breaker.
+func makeConnFn(option CircuitBreakerOption) connFn {
+	cbs := newBreakers(option)
+	return func(dst string, opt *ClientOption) conn {
+		m := makeMux(dst, tlsOption(dst, opt), dial)
+		m.cb = cbs.get(dst)
+		return m
+	}
+}
+
+// tlsOption derives a ClientOption with a tls.Config dedicated to dst if the TLSServerNameFn gives it a different ServerName.
+func tlsOption(dst string, opt *ClientOption) *ClientOption {
+	if opt.TLSConfig == nil || opt.TLSServerNameFn == nil {
+		return opt
+	}
+	name := opt.TLSServerNameFn(dst)
+	if name == "" || name == opt.TLSConfig.ServerName {
+		return opt
+	}
+	o := *opt
+	o.TLSConfig = opt.TLSConfig.Clone()
+	o.TLSConfig.ServerName = name
+	return &o
 }
 
 func dial(dst string, opt *ClientOption) (conn net.Conn, err error) {
 	if opt.DialFn != nil {
 		return opt.DialFn(dst, &opt.Dialer, opt.TLSConfig)
 	}
+	network := "tcp"
+	if isUnixAddr(dst) {
+		network = "unix"
+	}
 	if opt.TLSConfig != nil {
-		conn, err = tls.DialWithDialer(&opt.Dialer, "tcp", dst, opt.TLSConfig)
+		conn, err = tls.DialWithDialer(&opt.Dialer, network, dst, opt.TLSConfig)
 	} else {
-		conn, err = opt.Dialer.Dial("tcp", dst)
+		conn, err = opt.Dialer.Dial(network, dst)
 	}
 	return conn, err
 }
 
+// isUnixAddr reports whether dst is a unix socket path instead of a host:port address.
+// Only absolute paths, which are also what ParseURL produces from unix:// URLs, are treated as unix sockets,
+// because a hostname can end with anything, including .sock.
+func isUnixAddr(dst string) bool {
+	return strings.HasPrefix(dst, "/")
+}
+
 const redisErrMsgCommandNotAllow = "command is not allowed"
@@ -14,6 +14,7 @@ import (
 	"math/big"
 	"net"
 	"os"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -301,6 +302,80 @@ func TestCustomDialFnIsCalled(t *testing.T) {
 	}
 }
 
+func TestTLSServerNameFn(t *testing.T) {
+	defer ShouldNotLeaked(SetupLeakDetection())
+	config := &tls.Config{ServerName: "default"}
+	var names []string
+	option := ClientOption{
+		InitAddress: []string{"127.0.0.1:0"},
+		TLSConfig:   config,
+		TLSServerNameFn: func(dst string) string {
+			return "node-" + dst
+		},
+		DialFn: func(s string, dialer *net.Dialer, config *tls.Config) (conn net.Conn, err error) {
+			names = append(names, config.ServerName)
+			return nil, errors.New("dial error")
+		},
+	}
+	if _, err := NewClient(option); err == nil {
+		t.Fatalf("expected dial error")
+	}
+	if len(names) == 0 || names[0] != "node-127.0.0.1:0" {
+		t.Fatalf("unexpected server names %v", names)
+	}
+	if config.ServerName != "default" {
+		t.Fatalf("TLSConfig should not be modified")
+	}
+	option.TLSServerNameFn = func(dst string) string { return "" }
+	if o := tlsOption("127.0.0.1:0", &option); o != &option {
+		t.Fatalf("ClientOption should be reused if no server name is provided")
+	}
+}
+
+func TestDefaultCommandTimeoutNotRetried(t *testing.T) {
+	defer ShouldNotLeaked(SetupLeakDetection())
+	var gets int32
+	client, err := NewClient(ClientOption{
+		InitAddress:           []string{"127.0.0.1:0"},
+		DisableCache:          true,
+		PipelineMultiplex:     -1,
+		DefaultCommandTimeout: 50 * time.Millisecond,
+		ForceSingleClient:     true,
+		DialFn: func(s string, dialer *net.Dialer, config *tls.Config) (conn net.Conn, err error) {
+			n1, n2 := net.Pipe()
+			go func() {
+				mock := &redisMock{t: t, buf: bufio.NewReader(n2), conn: n2}
+				mock.Expect("HELLO", "3").Reply(RedisMessage{typ: '%', values: []RedisMessage{{typ: '+', string: "proto"}, {typ: ':', integer: 3}}})
+				for { // never reply
+					m, err := mock.ReadMessage()
+					if err != nil || m.values[0].string == "QUIT" {
+						break
+					}
+					if m.values[0].string == "GET" {
+						atomic.AddInt32(&gets, 1)
+					}
+				}
+				n2.Close()
+			}()
+			return n1, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected err %v", err)
+	}
+	defer client.Close()
+	start := time.Now()
+	if err := client.Do(context.Background(), client.B().Get().Key("a").Build()).Error(); err != context.DeadlineExceeded {
+		t.Fatalf("unexpected err %v", err)
+	}
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Fatalf("the command timeout should not be retried, returned after %v", d)
+	}
+	if n := atomic.LoadInt32(&gets); n != 1 {
+		t.Fatalf("unexpected attempts %v", n)
+	}
+}
+
 func ExampleIsRedisNil() {
 	client, err := NewClient(ClientOption{InitAddress: []string{"127.0.0.1:6379"}})
 	if err != nil {
@@ -19,6 +19,18 @@ type Hook interface {
 	Receive(client rueidis.Client, ctx context.Context, subscribe rueidis.Completed, fn func(msg rueidis.PubSubMessage)) (err error)
 }
 
+// StreamHook can be optionally implemented by a Hook to intercept rueidis.Client.DoStream.
+// If the Hook doesn't implement it, DoStream is passed to the rueidis.Client directly.
+type StreamHook interface {
+	DoStream(client rueidis.Client, ctx context.Context, cmd rueidis.Completed) rueidis.RedisResultStream
+}
+
+// IterHook can be optionally implemented by a Hook to intercept rueidis.Client.DoIter.
+// If the Hook doesn't implement it, DoIter is passed to the rueidis.Client directly.
+type IterHook interface {
+	DoIter(client rueidis.Client, ctx context.Context, cmd rueidis.Completed, fn func(elem rueidis.RedisMessage) error) (err error)
+}
+
 // WithHook wraps rueidis.Client with Hook and allows user to intercept rueidis.Client
 func WithHook(client rueidis.Client, hook Hook) rueidis.Client {
 	return &hookclient{client: client, hook: hook}
@@ -49,6 +61,20 @@ func (c *hookclient) DoMultiCache(ctx context.Context, multi ...rueidis.Cacheabl
 	return c.hook.DoMultiCache(c.client, ctx, multi...)
 }
 
+func (c *hookclient) DoStream(ctx context.Context, cmd rueidis.Completed) rueidis.RedisResultStream {
+	if h, ok := c.hook.(StreamHook); ok {
+		return h.DoStream(c.client, ctx, cmd)
+	}
+	return c.client.DoStream(ctx, cmd)
+}
+
+func (c *hookclient) DoIter(ctx context.Context, cmd rueidis.Completed, fn func(elem rueidis.RedisMessage) error) (err error) {
+	if h, ok := c.hook.(IterHook); ok {
+		return h.DoIter(c.client, ctx, cmd, fn)
+	}
+	return c.client.DoIter(ctx, cmd, fn)
+}
+
 func (c *hookclient) Dedicated(fn func(rueidis.DedicatedClient) error) (err error) {
 	return c.client.Dedicated(func(client rueidis.DedicatedClient) error {
 		return fn(&dedicated{client: &extended{DedicatedClient: client}, hook: c.hook})
@@ -60,6 +86,20 @@ func (c *hookclient) Dedicate() (rueidis.DedicatedClient, func()) {
 	return &dedicated{client: &extended{DedicatedClient: client}, hook: c.hook}, cancel
 }
 
+func (c *hookclient) DedicatedCtx(ctx context.Context, fn func(rueidis.DedicatedClient) error) (err error) {
+	return c.client.DedicatedCtx(ctx, func(client rueidis.DedicatedClient) error {
+		return fn(&dedicated{client: &extended{DedicatedClient: client}, hook: c.hook})
+	})
+}
+
+func (c *hookclient) DedicateCtx(ctx context.Context) (rueidis.DedicatedClient, func(), error) {
+	client, cancel, err := c.client.DedicateCtx(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &dedicated{client: &extended{DedicatedClient: client}, hook: c.hook}, cancel, nil
+}
+
 func (c *hookclient) Receive(ctx context.Context, subscribe rueidis.Completed, fn func(msg rueidis.PubSubMessage)) (err error) {
 	return c.hook.Receive(c.client, ctx, subscribe, fn)
 }
@@ -72,10 +112,18 @@ func (c *hookclient) Nodes() map[string]rueidis.Client {
 	return nodes
 }
 
+func (c *hookclient) Stats() rueidis.ClientStats {
+	return c.client.Stats()
+}
+
 func (c *hookclient) Close() {
 	c.client.Close()
 }
 
+func (c *hookclient) CloseWithContext(ctx context.Context) error {
+	return c.client.CloseWithContext(ctx)
+}
+
 var _ rueidis.DedicatedClient = (*dedicated)(nil)
 
 type dedicated struct {
@@ -121,6 +169,14 @@ func (e *extended) DoMultiCache(ctx context.Context, multi ...rueidis.CacheableT
 	panic("DoMultiCache() is not allowed with rueidis.DedicatedClient")
 }
 
+func (e *extended) DoStream(ctx context.Context, cmd rueidis.Completed) rueidis.RedisResultStream {
+	panic("DoStream() is not allowed with rueidis.DedicatedClient")
+}
+
+func (e *extended) DoIter(ctx context.Context, cmd rueidis.Completed, fn func(elem rueidis.RedisMessage) error) (err error) {
+	panic("DoIter() is not allowed with rueidis.DedicatedClient")
+}
+
 func (e *extended) Dedicated(fn func(rueidis.DedicatedClient) error) (err error) {
 	panic("Dedicated() is not allowed with rueidis.DedicatedClient")
 }
@@ -129,6 +185,22 @@ func (e *extended) Dedicate() (client rueidis.DedicatedClient, cancel func()) {
 	panic("Dedicate() is not allowed with rueidis.DedicatedClient")
 }
 
+func (e *extended) DedicatedCtx(ctx context.Context, fn func(rueidis.DedicatedClient) error) (err error) {
+	panic("DedicatedCtx() is not allowed with rueidis.DedicatedClient")
+}
+
+func (e *extended) DedicateCtx(ctx context.Context) (client rueidis.DedicatedClient, cancel func(), err error) {
+	panic("DedicateCtx() is not allowed with rueidis.DedicatedClient")
+}
+
 func (e *extended) Nodes() map[string]rueidis.Client {
 	panic("Nodes() is not allowed with rueidis.DedicatedClient")
 }
+
+func (e *extended) Stats() rueidis.ClientStats {
+	panic("Stats() is not allowed with rueidis.DedicatedClient")
+}
+
+func (e *extended) CloseWithContext(ctx context.Context) error {
+	panic("CloseWithContext() is not allowed with rueidis.DedicatedClient")
+}
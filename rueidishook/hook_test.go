@@ -3,6 +3,7 @@ package rueidishook
 import (
 	"context"
 	"errors"
+	"reflect"
 	"testing"
 	"time"
 
@@ -33,6 +34,14 @@ func (h *hook) Receive(client rueidis.Client, ctx context.Context, subscribe rue
 	return client.Receive(ctx, subscribe, fn)
 }
 
+func (h *hook) DoStream(client rueidis.Client, ctx context.Context, cmd rueidis.Completed) rueidis.RedisResultStream {
+	return client.DoStream(ctx, cmd)
+}
+
+func (h *hook) DoIter(client rueidis.Client, ctx context.Context, cmd rueidis.Completed, fn func(elem rueidis.RedisMessage) error) (err error) {
+	return client.DoIter(ctx, cmd, fn)
+}
+
 type wronghook struct {
 	DoFn func(client rueidis.Client)
 }
@@ -104,12 +113,45 @@ func testHooked(t *testing.T, hooked rueidis.Client, mocked *mock.Client) {
 			t.Fatalf("unexpected err %v", err)
 		}
 	}
+	{
+		mocked.EXPECT().DoStream(ctx, mock.Match("GET", "e")).Return(rueidis.RedisResultStream{})
+		if s := hooked.DoStream(ctx, hooked.B().Get().Key("e").Build()); s.Error() != nil || s.HasNext() {
+			t.Fatalf("unexpected stream %v", s)
+		}
+	}
+	{
+		mocked.EXPECT().DoIter(ctx, mock.Match("HGETALL", "f"), gomock.Any()).DoAndReturn(func(ctx context.Context, cmd any, fn func(elem rueidis.RedisMessage) error) error {
+			return fn(mock.RedisString("f"))
+		})
+		if err := hooked.DoIter(ctx, hooked.B().Hgetall().Key("f").Build(), func(elem rueidis.RedisMessage) error {
+			v, err := elem.ToString()
+			if err == nil {
+				err = errors.New(v)
+			}
+			return err
+		}); err == nil || err.Error() != "f" {
+			t.Fatalf("unexpected err %v", err)
+		}
+	}
 	{
 		mocked.EXPECT().Nodes().Return(map[string]rueidis.Client{"addr": mocked})
 		if nodes := hooked.Nodes(); nodes["addr"].(*hookclient).client != mocked {
 			t.Fatalf("unexpected val %v", nodes)
 		}
 	}
+	{
+		stats := rueidis.ClientStats{Nodes: map[string]rueidis.NodeStats{"addr": {Dials: 1}}}
+		mocked.EXPECT().Stats().Return(stats)
+		if v := hooked.Stats(); !reflect.DeepEqual(v, stats) {
+			t.Fatalf("unexpected val %v", v)
+		}
+	}
+	{
+		mocked.EXPECT().CloseWithContext(context.Background()).Return(context.Canceled)
+		if err := hooked.CloseWithContext(context.Background()); err != context.Canceled {
+			t.Fatalf("unexpected err %v", err)
+		}
+	}
 	{
 		ch := make(chan struct{})
 		mocked.EXPECT().Close().Do(func() { close(ch) })
@@ -189,6 +231,53 @@ func TestWithHook(t *testing.T) {
 			t.Fatalf("unexpected err %v", err)
 		}
 	}
+	{
+		dc := mock.NewDedicatedClient(ctrl)
+		mocked.EXPECT().DedicateCtx(context.Background()).Return(dc, func() {}, nil)
+		c, _, err := hooked.DedicateCtx(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected err %v", err)
+		}
+		testHookedDedicated(t, c, dc)
+		mocked.EXPECT().DedicateCtx(context.Background()).Return(nil, nil, context.Canceled)
+		if c, _, err := hooked.DedicateCtx(context.Background()); c != nil || err != context.Canceled {
+			t.Fatalf("unexpected dedicate %v %v", c, err)
+		}
+	}
+	{
+		dc := mock.NewDedicatedClient(ctrl)
+		cb := func(c rueidis.DedicatedClient) error {
+			testHookedDedicated(t, c, dc)
+			return errors.New("any")
+		}
+		mocked.EXPECT().DedicatedCtx(context.Background(), gomock.Any()).DoAndReturn(func(ctx context.Context, fn func(c rueidis.DedicatedClient) error) error {
+			return fn(dc)
+		})
+		if err := hooked.DedicatedCtx(context.Background(), cb); err.Error() != "any" {
+			t.Fatalf("unexpected err %v", err)
+		}
+	}
+}
+
+func TestWithHookWithoutOptionalHooks(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mocked := mock.NewClient(ctrl)
+	hooked := WithHook(mocked, &wronghook{})
+	ctx := context.Background()
+	{
+		mocked.EXPECT().DoStream(ctx, mock.Match("GET", "e")).Return(rueidis.RedisResultStream{})
+		if s := hooked.DoStream(ctx, hooked.B().Get().Key("e").Build()); s.Error() != nil || s.HasNext() {
+			t.Fatalf("unexpected stream %v", s)
+		}
+	}
+	{
+		mocked.EXPECT().DoIter(ctx, mock.Match("HGETALL", "f"), gomock.Any()).Return(errors.New("any"))
+		if err := hooked.DoIter(ctx, hooked.B().Hgetall().Key("f").Build(), nil); err == nil || err.Error() != "any" {
+			t.Fatalf("unexpected err %v", err)
+		}
+	}
 }
 
 func TestForbiddenMethodForDedicatedClient(t *testing.T) {
@@ -236,11 +325,41 @@ func TestForbiddenMethodForDedicatedClient(t *testing.T) {
 				client.Dedicate()
 			},
 			msg: "Dedicate() is not allowed with rueidis.DedicatedClient",
+		}, {
+			fn: func(client rueidis.Client) {
+				client.DedicatedCtx(context.Background(), func(client rueidis.DedicatedClient) error { return nil })
+			},
+			msg: "DedicatedCtx() is not allowed with rueidis.DedicatedClient",
+		}, {
+			fn: func(client rueidis.Client) {
+				client.DedicateCtx(context.Background())
+			},
+			msg: "DedicateCtx() is not allowed with rueidis.DedicatedClient",
+		}, {
+			fn: func(client rueidis.Client) {
+				client.DoStream(context.Background(), client.B().Get().Key("").Build())
+			},
+			msg: "DoStream() is not allowed with rueidis.DedicatedClient",
+		}, {
+			fn: func(client rueidis.Client) {
+				client.DoIter(context.Background(), client.B().Get().Key("").Build(), nil)
+			},
+			msg: "DoIter() is not allowed with rueidis.DedicatedClient",
 		}, {
 			fn: func(client rueidis.Client) {
 				client.Nodes()
 			},
 			msg: "Nodes() is not allowed with rueidis.DedicatedClient",
+		}, {
+			fn: func(client rueidis.Client) {
+				client.Stats()
+			},
+			msg: "Stats() is not allowed with rueidis.DedicatedClient",
+		}, {
+			fn: func(client rueidis.Client) {
+				client.CloseWithContext(context.Background())
+			},
+			msg: "CloseWithContext() is not allowed with rueidis.DedicatedClient",
 		},
 	} {
 		shouldpanic(c.fn, c.msg)
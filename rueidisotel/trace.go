@@ -137,6 +137,20 @@ func (o *otelclient) DoMultiCache(ctx context.Context, multi ...rueidis.Cacheabl
 	return
 }
 
+func (o *otelclient) DoStream(ctx context.Context, cmd rueidis.Completed) (resp rueidis.RedisResultStream) {
+	ctx, span := o.start(ctx, first(cmd.Commands()), sum(cmd.Commands()), o.tAttrs)
+	resp = o.client.DoStream(ctx, cmd)
+	resp.OnComplete(func(err error) { o.end(span, err) }) // the span covers reading the response by WriteTo
+	return
+}
+
+func (o *otelclient) DoIter(ctx context.Context, cmd rueidis.Completed, fn func(elem rueidis.RedisMessage) error) (err error) {
+	ctx, span := o.start(ctx, first(cmd.Commands()), sum(cmd.Commands()), o.tAttrs)
+	err = o.client.DoIter(ctx, cmd, fn)
+	o.end(span, err)
+	return
+}
+
 func (o *otelclient) Dedicated(fn func(rueidis.DedicatedClient) error) (err error) {
 	return o.client.Dedicated(func(client rueidis.DedicatedClient) error {
 		return fn(&dedicated{
@@ -164,6 +178,36 @@ func (o *otelclient) Dedicate() (rueidis.DedicatedClient, func()) {
 	}, cancel
 }
 
+func (o *otelclient) DedicatedCtx(ctx context.Context, fn func(rueidis.DedicatedClient) error) (err error) {
+	return o.client.DedicatedCtx(ctx, func(client rueidis.DedicatedClient) error {
+		return fn(&dedicated{
+			client:  client,
+			mAttrs:  o.mAttrs,
+			tAttrs:  o.tAttrs,
+			tracer:  o.tracer,
+			meter:   o.meter,
+			cscMiss: o.cscMiss,
+			cscHits: o.cscHits,
+		})
+	})
+}
+
+func (o *otelclient) DedicateCtx(ctx context.Context) (rueidis.DedicatedClient, func(), error) {
+	client, cancel, err := o.client.DedicateCtx(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &dedicated{
+		client:  client,
+		mAttrs:  o.mAttrs,
+		tAttrs:  o.tAttrs,
+		tracer:  o.tracer,
+		meter:   o.meter,
+		cscMiss: o.cscMiss,
+		cscHits: o.cscHits,
+	}, cancel, nil
+}
+
 func (o *otelclient) Receive(ctx context.Context, subscribe rueidis.Completed, fn func(msg rueidis.PubSubMessage)) (err error) {
 	ctx, span := o.start(ctx, first(subscribe.Commands()), sum(subscribe.Commands()), o.tAttrs)
 	err = o.client.Receive(ctx, subscribe, fn)
@@ -189,10 +233,18 @@ func (o *otelclient) Nodes() map[string]rueidis.Client {
 	return nodes
 }
 
+func (o *otelclient) Stats() rueidis.ClientStats {
+	return o.client.Stats()
+}
+
 func (o *otelclient) Close() {
 	o.client.Close()
 }
 
+func (o *otelclient) CloseWithContext(ctx context.Context) error {
+	return o.client.CloseWithContext(ctx)
+}
+
 var _ rueidis.DedicatedClient = (*dedicated)(nil)
 
 type dedicated struct {
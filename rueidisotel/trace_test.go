@@ -2,6 +2,7 @@ package rueidisotel
 
 import (
 	"context"
+	"io"
 	"testing"
 	"time"
 
@@ -94,6 +95,20 @@ func testWithClient(t *testing.T, client rueidis.Client, exp *tracetest.InMemory
 	client.DoMulti(ctx, client.B().Set().Key("key").Value("val").Build(), client.B().Set().Key("key").Value("val").Build())
 	validateTrace(t, exp, "SET SET", codes.Ok)
 
+	s := client.DoStream(ctx, client.B().Get().Key("key").Build())
+	if n := len(exp.GetSpans()); n != 0 {
+		t.Fatalf("unexpected span ended before WriteTo %v", n)
+	}
+	if _, err := s.WriteTo(io.Discard); err != nil {
+		t.Fatalf("unexpected err %v", err)
+	}
+	validateTrace(t, exp, "GET", codes.Ok)
+
+	if err := client.DoIter(ctx, client.B().Mget().Key("key").Build(), func(elem rueidis.RedisMessage) error { return nil }); err != nil {
+		t.Fatalf("unexpected err %v", err)
+	}
+	validateTrace(t, exp, "MGET", codes.Ok)
+
 	// first DoCache
 	client.DoCache(ctx, client.B().Get().Key("key").Cache(), time.Minute)
 	validateTrace(t, exp, "GET", codes.Ok)
@@ -210,6 +225,10 @@ func testWithClient(t *testing.T, client rueidis.Client, exp *tracetest.InMemory
 	client.Do(ctx, cmds.NewCompleted([]string{"unknown", "command"}))
 	validateTrace(t, exp, "unknown", codes.Error)
 
+	if stats := client.Stats(); len(stats.Nodes) == 0 {
+		t.Fatalf("unexpected stats %v", stats)
+	}
+
 	nodes := client.Nodes()
 	if len(nodes) == 0 {
 		t.Fatalf("unexpected nodes count %v", len(nodes))
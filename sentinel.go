@@ -18,13 +18,14 @@ import (
 
 func newSentinelClient(opt *ClientOption, connFn connFn) (client *sentinelClient, err error) {
 	client = &sentinelClient{
-		cmd:       cmds.NewBuilder(cmds.NoSlot),
-		mOpt:      opt,
-		sOpt:      newSentinelOpt(opt),
-		connFn:    connFn,
-		sentinels: list.New(),
-		retry:     !opt.DisableRetry,
-		replica:   opt.ReplicaOnly,
+		cmd:          cmds.NewBuilder(cmds.NoSlot),
+		mOpt:         opt,
+		sOpt:         newSentinelOpt(opt),
+		connFn:       connFn,
+		sentinels:    list.New(),
+		retry:        !opt.DisableRetry,
+		retryHandler: newRetryHandler(opt.RetryPolicy),
+		replica:      opt.ReplicaOnly,
 	}
 
 	for _, sentinel := range opt.InitAddress {
@@ -40,20 +41,21 @@ func newSentinelClient(opt *ClientOption, connFn connFn) (client *sentinelClient
 }
 
 type sentinelClient struct {
-	mConn     atomic.Value
-	sConn     conn
-	mOpt      *ClientOption
-	sOpt      *ClientOption
-	connFn    connFn
-	sentinels *list.List
-	mAddr     string
-	sAddr     string
-	sc        call
-	mu        sync.Mutex
-	stop      uint32
-	cmd       cmds.Builder
-	retry     bool
-	replica   bool
+	mConn        atomic.Value
+	sConn        conn
+	mOpt         *ClientOption
+	sOpt         *ClientOption
+	connFn       connFn
+	sentinels    *list.List
+	mAddr        string
+	sAddr        string
+	sc           call
+	mu           sync.Mutex
+	stop         uint32
+	cmd          cmds.Builder
+	retry        bool
+	retryHandler retryHandler
+	replica      bool
 }
 
 func (c *sentinelClient) B() cmds.Builder {
@@ -61,9 +63,12 @@ func (c *sentinelClient) B() cmds.Builder {
 }
 
 func (c *sentinelClient) Do(ctx context.Context, cmd Completed) (resp RedisResult) {
+	attempts := 1
 retry:
 	resp = c.mConn.Load().(conn).Do(ctx, cmd)
-	if c.retry && cmd.IsReadOnly() && c.isRetryable(resp.NonRedisError(), ctx) {
+	if c.retry && retrySafe(cmd) && c.isRetryable(resp.NonRedisError(), ctx) &&
+		c.retryHandler.WaitOrSkipRetry(ctx, attempts, resp.Error(), cmd) {
+		attempts++
 		goto retry
 	}
 	if resp.NonRedisError() == nil { // not recycle cmds if error, since cmds may be used later in pipe. consider recycle them by pipe
@@ -76,13 +81,18 @@ func (c *sentinelClient) DoMulti(ctx context.Context, multi ...Completed) []Redi
 	if len(multi) == 0 {
 		return nil
 	}
+	attempts := 1
 retry:
 	resps := c.mConn.Load().(conn).DoMulti(ctx, multi...)
-	if c.retry && allReadOnly(multi) {
-		for _, resp := range resps.s {
+	if c.retry && allRetrySafe(multi) {
+		for i, resp := range resps.s {
 			if c.isRetryable(resp.NonRedisError(), ctx) {
-				resultsp.Put(resps)
-				goto retry
+				if c.retryHandler.WaitOrSkipRetry(ctx, attempts, resp.Error(), multi[i]) {
+					resultsp.Put(resps)
+					attempts++
+					goto retry
+				}
+				break
 			}
 		}
 	}
@@ -95,9 +105,12 @@ retry:
 }
 
 func (c *sentinelClient) DoCache(ctx context.Context, cmd Cacheable, ttl time.Duration) (resp RedisResult) {
+	attempts := 1
 retry:
 	resp = c.mConn.Load().(conn).DoCache(ctx, cmd, ttl)
-	if c.retry && c.isRetryable(resp.NonRedisError(), ctx) {
+	if c.retry && c.isRetryable(resp.NonRedisError(), ctx) &&
+		c.retryHandler.WaitOrSkipRetry(ctx, attempts, resp.Error(), Completed(cmd)) {
+		attempts++
 		goto retry
 	}
 	if err := resp.NonRedisError(); err == nil || err == ErrDoCacheAborted {
@@ -110,13 +123,18 @@ func (c *sentinelClient) DoMultiCache(ctx context.Context, multi ...CacheableTTL
 	if len(multi) == 0 {
 		return nil
 	}
+	attempts := 1
 retry:
 	resps := c.mConn.Load().(conn).DoMultiCache(ctx, multi...)
 	if c.retry {
-		for _, resp := range resps.s {
+		for i, resp := range resps.s {
 			if c.isRetryable(resp.NonRedisError(), ctx) {
-				resultsp.Put(resps)
-				goto retry
+				if c.retryHandler.WaitOrSkipRetry(ctx, attempts, resp.Error(), Completed(multi[i].Cmd)) {
+					resultsp.Put(resps)
+					attempts++
+					goto retry
+				}
+				break
 			}
 		}
 	}
@@ -128,11 +146,26 @@ retry:
 	return resps.s
 }
 
+func (c *sentinelClient) DoStream(ctx context.Context, cmd Completed) RedisResultStream {
+	s := c.mConn.Load().(conn).DoStream(ctx, cmd)
+	cmds.PutCompleted(cmd)
+	return s
+}
+
+func (c *sentinelClient) DoIter(ctx context.Context, cmd Completed, fn func(elem RedisMessage) error) error {
+	err := c.mConn.Load().(conn).DoIter(ctx, cmd, fn)
+	cmds.PutCompleted(cmd)
+	return err
+}
+
 func (c *sentinelClient) Receive(ctx context.Context, subscribe Completed, fn func(msg PubSubMessage)) (err error) {
+	attempts := 1
 retry:
 	err = c.mConn.Load().(conn).Receive(ctx, subscribe, fn)
 	if c.retry {
-		if _, ok := err.(*RedisError); !ok && c.isRetryable(err, ctx) {
+		if _, ok := err.(*RedisError); !ok && c.isRetryable(err, ctx) &&
+			c.retryHandler.WaitOrSkipRetry(ctx, attempts, err, subscribe) {
+			attempts++
 			goto retry
 		}
 	}
@@ -143,24 +176,44 @@ retry:
 }
 
 func (c *sentinelClient) Dedicated(fn func(DedicatedClient) error) (err error) {
+	return c.DedicatedCtx(context.Background(), fn)
+}
+
+func (c *sentinelClient) DedicatedCtx(ctx context.Context, fn func(DedicatedClient) error) (err error) {
 	master := c.mConn.Load().(conn)
-	wire := master.Acquire()
-	dsc := &dedicatedSingleClient{cmd: c.cmd, conn: master, wire: wire, retry: c.retry}
+	wire, err := master.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	dsc := &dedicatedSingleClient{cmd: c.cmd, conn: master, wire: wire, retry: c.retry, retryHandler: c.retryHandler}
 	err = fn(dsc)
 	dsc.release()
 	return err
 }
 
 func (c *sentinelClient) Dedicate() (DedicatedClient, func()) {
+	client, cancel, _ := c.DedicateCtx(context.Background())
+	return client, cancel
+}
+
+func (c *sentinelClient) DedicateCtx(ctx context.Context) (DedicatedClient, func(), error) {
 	master := c.mConn.Load().(conn)
-	wire := master.Acquire()
-	dsc := &dedicatedSingleClient{cmd: c.cmd, conn: master, wire: wire, retry: c.retry}
-	return dsc, dsc.release
+	wire, err := master.Acquire(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	dsc := &dedicatedSingleClient{cmd: c.cmd, conn: master, wire: wire, retry: c.retry, retryHandler: c.retryHandler}
+	return dsc, dsc.release, nil
 }
 
 func (c *sentinelClient) Nodes() map[string]Client {
 	conn := c.mConn.Load().(conn)
-	return map[string]Client{conn.Addr(): newSingleClientWithConn(conn, c.cmd, c.retry)}
+	return map[string]Client{conn.Addr(): newSingleClientWithConn(conn, c.cmd, c.retry, c.retryHandler)}
+}
+
+func (c *sentinelClient) Stats() ClientStats {
+	conn := c.mConn.Load().(conn)
+	return ClientStats{Nodes: map[string]NodeStats{conn.Addr(): conn.NodeStats()}}
 }
 
 func (c *sentinelClient) Close() {
@@ -175,8 +228,21 @@ func (c *sentinelClient) Close() {
 	c.mu.Unlock()
 }
 
+func (c *sentinelClient) CloseWithContext(ctx context.Context) (err error) {
+	atomic.StoreUint32(&c.stop, 1)
+	c.mu.Lock()
+	if c.sConn != nil {
+		c.sConn.Close()
+	}
+	if master := c.mConn.Load(); master != nil {
+		err = newCloseError(ctx, master.(conn).CloseWithContext(ctx))
+	}
+	c.mu.Unlock()
+	return err
+}
+
 func (c *sentinelClient) isRetryable(err error, ctx context.Context) (should bool) {
-	return err != nil && atomic.LoadUint32(&c.stop) == 0 && ctx.Err() == nil
+	return err != nil && atomic.LoadUint32(&c.stop) == 0 && ctx.Err() == nil && retryableErr(err)
 }
 
 func (c *sentinelClient) addSentinel(addr string) {
@@ -426,6 +492,7 @@ func newSentinelOpt(opt *ClientOption) *ClientOption {
 	o := *opt
 	o.Username = o.Sentinel.Username
 	o.Password = o.Sentinel.Password
+	o.AuthCredentialsFn = nil
 	o.ClientName = o.Sentinel.ClientName
 	o.Dialer = o.Sentinel.Dialer
 	o.TLSConfig = o.Sentinel.TLSConfig
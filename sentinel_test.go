@@ -757,6 +757,53 @@ func TestSentinelClientDelegate(t *testing.T) {
 		}
 	})
 
+	t.Run("Delegate DoStream", func(t *testing.T) {
+		c := client.B().Get().Key("Do").Build()
+		e := errors.New("stream")
+		m.DoStreamFn = func(cmd Completed) RedisResultStream {
+			if !reflect.DeepEqual(cmd.Commands(), c.Commands()) {
+				t.Fatalf("unexpected command %v", cmd)
+			}
+			return RedisResultStream{e: e}
+		}
+		if s := client.DoStream(context.Background(), c); s.Error() != e {
+			t.Fatalf("unexpected response %v", s.Error())
+		}
+	})
+
+	t.Run("Delegate DoIter", func(t *testing.T) {
+		c := client.B().Hgetall().Key("Do").Build()
+		m.DoIterFn = func(cmd Completed, fn func(elem RedisMessage) error) error {
+			if !reflect.DeepEqual(cmd.Commands(), c.Commands()) {
+				t.Fatalf("unexpected command %v", cmd)
+			}
+			return fn(RedisMessage{typ: '+', string: "Do"})
+		}
+		if err := client.DoIter(context.Background(), c, func(elem RedisMessage) error {
+			if v, _ := elem.ToString(); v != "Do" {
+				t.Fatalf("unexpected element %v", elem)
+			}
+			return nil
+		}); err != nil {
+			t.Fatalf("unexpected response %v", err)
+		}
+	})
+
+	t.Run("Delegate Stats", func(t *testing.T) {
+		m.NodeStatsFn = func() NodeStats {
+			return NodeStats{Dials: 1}
+		}
+		stats := client.Stats()
+		if len(stats.Nodes) != 1 {
+			t.Fatalf("unexpected stats %v", stats)
+		}
+		for _, s := range stats.Nodes {
+			if s.Dials != 1 {
+				t.Fatalf("unexpected stats %v", stats)
+			}
+		}
+	})
+
 	t.Run("Delegate Receive", func(t *testing.T) {
 		c := client.B().Subscribe().Channel("ch").Build()
 		hdl := func(message PubSubMessage) {}
@@ -791,6 +838,20 @@ func TestSentinelClientDelegate(t *testing.T) {
 		}
 	})
 
+	t.Run("Delegate CloseWithContext", func(t *testing.T) {
+		m.CloseWithCtxFn = func(ctx context.Context) int { return 1 }
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		var ce *CloseError
+		if err := client.CloseWithContext(ctx); !errors.As(err, &ce) || ce.Abandoned != 1 || !errors.Is(err, context.Canceled) {
+			t.Fatalf("unexpected err %v", err)
+		}
+		m.CloseWithCtxFn = nil
+		if err := client.CloseWithContext(context.Background()); err != nil {
+			t.Fatalf("unexpected err %v", err)
+		}
+	})
+
 	t.Run("Dedicated Err", func(t *testing.T) {
 		v := errors.New("fn err")
 		if err := client.Dedicated(func(client DedicatedClient) error {
@@ -894,6 +955,52 @@ func TestSentinelClientDelegate(t *testing.T) {
 			t.Fatalf("Dedicated desn't put back the wire")
 		}
 	})
+
+	t.Run("DedicatedCtx Delegate", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		w := &mockWire{}
+		m.AcquireCtxFn = func(c context.Context) (wire, error) {
+			if c != ctx {
+				t.Fatalf("unexpected ctx %v", c)
+			}
+			return w, nil
+		}
+		defer func() { m.AcquireCtxFn = nil }()
+		stored := 0
+		m.StoreFn = func(ww wire) { stored++ }
+		if err := client.DedicatedCtx(ctx, func(c DedicatedClient) error {
+			return c.Do(ctx, c.B().Get().Key("a").Build()).Error()
+		}); err != nil {
+			t.Fatalf("unexpected err %v", err)
+		}
+		c, release, err := client.DedicateCtx(ctx)
+		if err != nil || c == nil {
+			t.Fatalf("unexpected dedicate %v %v", c, err)
+		}
+		c.Do(ctx, c.B().Get().Key("a").Build())
+		release()
+		if stored != 2 {
+			t.Fatalf("unexpected stored count %v", stored)
+		}
+
+		e := errors.New("exhausted")
+		m.AcquireCtxFn = func(c context.Context) (wire, error) { return nil, e }
+		if err := client.DedicatedCtx(ctx, func(c DedicatedClient) error {
+			return c.Do(ctx, c.B().Get().Key("a").Build()).Error()
+		}); err != e {
+			t.Fatalf("unexpected err %v", err)
+		}
+		if c, release, err := client.DedicateCtx(ctx); err == nil {
+			err = c.Do(ctx, c.B().Get().Key("a").Build()).Error()
+			release()
+			if err != e {
+				t.Fatalf("unexpected err %v", err)
+			}
+		} else if c != nil || release != nil || err != e {
+			t.Fatalf("unexpected dedicate %v %v", c, err)
+		}
+	})
 }
 
 //gocyclo:ignore
@@ -968,7 +1075,10 @@ func TestSentinelClientDelegateRetry(t *testing.T) {
 				return nil
 			},
 		}
-		client, err := newSentinelClient(&ClientOption{InitAddress: []string{":0"}}, func(dst string, opt *ClientOption) conn {
+		client, err := newSentinelClient(&ClientOption{
+			InitAddress: []string{":0"},
+			RetryPolicy: &ExponentialBackoffRetryPolicy{}, // retry immediately until the failover
+		}, func(dst string, opt *ClientOption) conn {
 			if dst == ":0" {
 				return s0
 			}
@@ -0,0 +1,87 @@
+package rueidis
+
+import (
+	"io"
+	"sync/atomic"
+)
+
+// ClientStats is a snapshot of the counters of a Client, keyed by the address of each redis node.
+// Taking a snapshot only loads some atomic counters and briefly takes a few locks,
+// so it is cheap enough to be collected on every metrics scrape.
+type ClientStats struct {
+	Nodes map[string]NodeStats
+}
+
+// NodeStats is a snapshot of the counters of the connections to a single redis node.
+type NodeStats struct {
+	// Conns are the pipelined connections currently established. Connections in the pools are not included.
+	Conns []ConnStats
+	// Pool is the connection pool used by blocking commands and DedicatedClient.
+	Pool PoolStats
+	// StreamPool is the connection pool used by Client.DoStream and Client.DoIter.
+	StreamPool PoolStats
+	// Dials is the number of connections dialed successfully, including the reconnections.
+	Dials uint64
+	// DialErrors is the number of failed dials.
+	DialErrors uint64
+	// Circuit is the state of the circuit breaker of the node, which is always CircuitClosed if it is not enabled.
+	Circuit CircuitState
+}
+
+// ConnStats is a snapshot of the counters of a single connection.
+type ConnStats struct {
+	// Cache is the client side cache bound to the connection.
+	Cache CacheStats
+	// Commands is the number of commands written to the connection.
+	Commands uint64
+	// Flushes is the number of flushes of the write buffer.
+	Flushes uint64
+	// BytesRead is the number of bytes read from the connection.
+	BytesRead uint64
+	// BytesWritten is the number of bytes written to the connection.
+	BytesWritten uint64
+	// Queued is the number of commands waiting for their responses.
+	Queued int64
+}
+
+// CacheStats is a snapshot of the counters of a client side cache.
+// The Evictions and the Size are only available with the default CacheStore.
+type CacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	// Size is the approximate bytes used by the cached entries.
+	Size int64
+}
+
+// PoolStats is a snapshot of the usage of a connection pool.
+type PoolStats struct {
+	// Acquired is the number of connections currently acquired from the pool.
+	Acquired int
+	// Idle is the number of connections idle in the pool.
+	Idle int
+	// Waiting is the number of callers waiting for a connection.
+	Waiting int
+}
+
+type countReader struct {
+	r io.Reader
+	n *atomic.Uint64
+}
+
+func (c countReader) Read(p []byte) (n int, err error) {
+	n, err = c.r.Read(p)
+	c.n.Add(uint64(n))
+	return
+}
+
+type countWriter struct {
+	w io.Writer
+	n *atomic.Uint64
+}
+
+func (c countWriter) Write(p []byte) (n int, err error) {
+	n, err = c.w.Write(p)
+	c.n.Add(uint64(n))
+	return
+}
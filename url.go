@@ -0,0 +1,108 @@
+package rueidis
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseURL parses a redis URL into ClientOption.
+// https://github.com/redis/redis-specifications/blob/master/uri/redis.txt
+// Example:
+//
+// redis://<user>:<password>@<host>:<port>/<db_number>
+// redis://<user>:<password>@<host>:<port>?addr=<host2>:<port2>&addr=<host3>:<port3>
+// rediss://<user>:<password>@<host>:<port>?master_set=<master_set>
+// unix://<user>:<password>@</path/to/redis.sock>?db=<db_number>
+//
+// The supported query parameters are db, addr, dial_timeout, write_timeout, protocol, client_name and master_set.
+// The dial_timeout and write_timeout accept either a time.ParseDuration string or a number of seconds.
+func ParseURL(str string) (opt ClientOption, err error) {
+	u, err := url.Parse(str)
+	if err != nil {
+		return opt, err
+	}
+	switch u.Scheme {
+	case "unix":
+		if u.Path == "" {
+			return opt, fmt.Errorf("redis: missing socket path in URL: %s", str)
+		}
+		opt.InitAddress = []string{u.Path}
+	case "rediss":
+		opt.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+		fallthrough
+	case "redis":
+		host, port := u.Hostname(), u.Port()
+		if host == "" {
+			host = "localhost"
+		}
+		if port == "" {
+			port = "6379"
+		}
+		opt.InitAddress = []string{net.JoinHostPort(host, port)}
+		if ps := strings.Split(u.Path, "/"); len(ps) == 2 && ps[1] != "" {
+			if opt.SelectDB, err = strconv.Atoi(ps[1]); err != nil {
+				return opt, fmt.Errorf("redis: invalid database number: %q", ps[1])
+			}
+		} else if len(ps) > 2 {
+			return opt, fmt.Errorf("redis: invalid URL path: %s", u.Path)
+		}
+	default:
+		return opt, fmt.Errorf("redis: invalid URL scheme: %s", u.Scheme)
+	}
+	if u.User != nil {
+		opt.Username = u.User.Username()
+		opt.Password, _ = u.User.Password()
+	}
+	q := u.Query()
+	if v := q.Get("db"); v != "" {
+		if opt.SelectDB, err = strconv.Atoi(v); err != nil {
+			return opt, fmt.Errorf("redis: invalid database number: %q", v)
+		}
+	}
+	if opt.Dialer.Timeout, err = parseURLDuration(q, "dial_timeout"); err != nil {
+		return opt, err
+	}
+	if opt.ConnWriteTimeout, err = parseURLDuration(q, "write_timeout"); err != nil {
+		return opt, err
+	}
+	switch v := q.Get("protocol"); v {
+	case "", "3":
+	case "2":
+		opt.AlwaysRESP2 = true
+	default:
+		return opt, fmt.Errorf("redis: invalid protocol: %q", v)
+	}
+	opt.InitAddress = append(opt.InitAddress, q["addr"]...)
+	opt.ClientName = q.Get("client_name")
+	opt.Sentinel.MasterSet = q.Get("master_set")
+	return opt, nil
+}
+
+func parseURLDuration(q url.Values, name string) (time.Duration, error) {
+	v := q.Get(name)
+	if v == "" {
+		return 0, nil
+	}
+	if s, err := strconv.Atoi(v); err == nil { // plain numbers are seconds
+		return time.Duration(s) * time.Second, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("redis: invalid %s: %q", name, v)
+	}
+	return d, nil
+}
+
+// MustParseURL is like ParseURL but panics if the URL can't be parsed.
+func MustParseURL(str string) ClientOption {
+	opt, err := ParseURL(str)
+	if err != nil {
+		panic(err)
+	}
+	return opt
+}